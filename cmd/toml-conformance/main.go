@@ -0,0 +1,304 @@
+// Toml-conformance runs the test suite of toml-test against go-toml and prints
+// how many documents of each category are handled as the specification
+// requires.
+//
+// Valid documents must be accepted and decode to the values described by the
+// JSON file next to them. Invalid documents must be rejected.
+//
+// Usage:
+//   git clone https://github.com/toml-lang/toml-test
+//   toml-conformance toml-test/tests
+//   toml-conformance -v toml-test/tests # also list the failing documents
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "toml-conformance runs the toml-test suite found in a directory:")
+		fmt.Fprintln(os.Stderr, "  toml-conformance [-v] toml-test/tests")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "The directory must contain the valid and invalid directories of the suite.")
+		fmt.Fprintln(os.Stderr, "The exit code is 1 when a document is not handled as expected.")
+		flag.PrintDefaults()
+	}
+	verbose := flag.Bool("v", false, "list the failing documents")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	os.Exit(processMain(flag.Arg(0), *verbose, os.Stdout, os.Stderr))
+}
+
+type category struct {
+	name     string
+	pass     int
+	failures []string
+}
+
+func processMain(dir string, verbose bool, output io.Writer, errorOutput io.Writer) int {
+	categories := map[string]*category{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".toml" {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if parts[0] != "valid" && parts[0] != "invalid" {
+			return nil
+		}
+		name := parts[0]
+		if len(parts) > 2 {
+			name += "/" + parts[1]
+		}
+		c, ok := categories[name]
+		if !ok {
+			c = &category{name: name}
+			categories[name] = c
+		}
+
+		if parts[0] == "valid" {
+			err = checkValid(path)
+		} else {
+			err = checkInvalid(path)
+		}
+		if err != nil {
+			c.failures = append(c.failures, fmt.Sprintf("%s: %s", rel, err))
+		} else {
+			c.pass++
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(errorOutput, err)
+		return 2
+	}
+	if len(categories) == 0 {
+		fmt.Fprintf(errorOutput, "no valid or invalid documents found in %s\n", dir)
+		return 2
+	}
+
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "category\tpass\tfail")
+	var pass, fail int
+	for _, name := range names {
+		c := categories[name]
+		fmt.Fprintf(w, "%s\t%d\t%d\n", c.name, c.pass, len(c.failures))
+		pass += c.pass
+		fail += len(c.failures)
+	}
+	fmt.Fprintf(w, "total\t%d\t%d\n", pass, fail)
+	w.Flush()
+
+	if verbose {
+		for _, name := range names {
+			for _, failure := range categories[name].failures {
+				fmt.Fprintln(output, failure)
+			}
+		}
+	}
+	if fail > 0 {
+		return 1
+	}
+	return 0
+}
+
+func checkInvalid(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err := toml.LoadBytes(b); err == nil {
+		return fmt.Errorf("accepted")
+	}
+	return nil
+}
+
+func checkValid(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tree, err := toml.LoadBytes(b)
+	if err != nil {
+		return err
+	}
+	ref, err := ioutil.ReadFile(strings.TrimSuffix(path, ".toml") + ".json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var expected interface{}
+	if err := json.Unmarshal(ref, &expected); err != nil {
+		return fmt.Errorf("invalid reference JSON: %s", err)
+	}
+	got := translate(tree)
+	if !equalTyped(expected, got) {
+		gotJSON, _ := json.Marshal(got)
+		return fmt.Errorf("decoded to %s", gotJSON)
+	}
+	return nil
+}
+
+// translate converts a value of a tree to the JSON representation used by
+// toml-test, where values are tagged with their type.
+func translate(v interface{}) interface{} {
+	switch node := v.(type) {
+	case *toml.Tree:
+		typed := map[string]interface{}{}
+		for _, k := range node.Keys() {
+			typed[k] = translate(node.GetPath([]string{k}))
+		}
+		return typed
+	case []*toml.Tree:
+		typed := make([]interface{}, len(node))
+		for i, item := range node {
+			typed[i] = translate(item)
+		}
+		return typed
+	case []interface{}:
+		typed := make([]interface{}, len(node))
+		for i, item := range node {
+			typed[i] = translate(item)
+		}
+		return typed
+	case string:
+		return tag("string", node)
+	case int64:
+		return tag("integer", strconv.FormatInt(node, 10))
+	case float64:
+		return tag("float", strconv.FormatFloat(node, 'g', -1, 64))
+	case bool:
+		return tag("bool", strconv.FormatBool(node))
+	case time.Time:
+		return tag("datetime", node.Format(time.RFC3339Nano))
+	case toml.LocalDateTime:
+		return tag("datetime-local", node.String())
+	case toml.LocalDate:
+		return tag("date-local", node.String())
+	case toml.LocalTime:
+		return tag("time-local", node.String())
+	default:
+		return tag(fmt.Sprintf("%T", v), fmt.Sprint(v))
+	}
+}
+
+func tag(typeName string, value string) map[string]interface{} {
+	return map[string]interface{}{"type": typeName, "value": value}
+}
+
+// equalTyped compares a value of a reference JSON file of toml-test to a
+// translated value, comparing numbers and dates by value rather than by
+// representation.
+func equalTyped(expected, got interface{}) bool {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok || len(e) != len(g) {
+			return false
+		}
+		if isTag(e) && isTag(g) {
+			return equalValues(e["type"].(string), e["value"].(string), g["type"].(string), g["value"].(string))
+		}
+		for k, ev := range e {
+			gv, ok := g[k]
+			if !ok || !equalTyped(ev, gv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(e) != len(g) {
+			return false
+		}
+		for i := range e {
+			if !equalTyped(e[i], g[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isTag(m map[string]interface{}) bool {
+	if len(m) != 2 {
+		return false
+	}
+	_, isType := m["type"].(string)
+	_, isValue := m["value"].(string)
+	return isType && isValue
+}
+
+func equalValues(expectedType, expected, gotType, got string) bool {
+	// older versions of toml-test use the same type for all date-times
+	if expectedType == "datetime" && strings.HasSuffix(gotType, "-local") {
+		gotType = "datetime"
+	}
+	if expectedType != gotType {
+		return false
+	}
+	switch expectedType {
+	case "float":
+		e, err1 := strconv.ParseFloat(expected, 64)
+		g, err2 := strconv.ParseFloat(got, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		return e == g || math.IsNaN(e) && math.IsNaN(g)
+	case "integer":
+		e, err1 := strconv.ParseInt(expected, 10, 64)
+		g, err2 := strconv.ParseInt(got, 10, 64)
+		return err1 == nil && err2 == nil && e == g
+	case "datetime":
+		e, err1 := time.Parse(time.RFC3339Nano, expected)
+		g, err2 := time.Parse(time.RFC3339Nano, got)
+		if err1 != nil || err2 != nil {
+			return expected == got
+		}
+		return e.Equal(g)
+	case "datetime-local":
+		e, err1 := toml.ParseLocalDateTime(expected)
+		g, err2 := toml.ParseLocalDateTime(got)
+		return err1 == nil && err2 == nil && e == g
+	case "date-local":
+		e, err1 := toml.ParseLocalDate(expected)
+		g, err2 := toml.ParseLocalDate(got)
+		return err1 == nil && err2 == nil && e == g
+	case "time-local":
+		e, err1 := toml.ParseLocalTime(expected)
+		g, err2 := toml.ParseLocalTime(got)
+		return err1 == nil && err2 == nil && e == g
+	default:
+		return expected == got
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSuite(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "toml-conformance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestProcessMain(t *testing.T) {
+	dir := writeSuite(t, map[string]string{
+		"valid/float/exponent.toml": "a = 1e6",
+		"valid/float/exponent.json": `{"a": {"type": "float", "value": "1000000.0"}}`,
+		"valid/datetime/local.toml": "a = 1979-05-27T07:32:00",
+		"valid/datetime/local.json": `{"a": {"type": "datetime-local", "value": "1979-05-27T07:32:00"}}`,
+		"valid/array/mixed.toml":    "a = [1, 2]",
+		"valid/array/mixed.json":    `{"a": [{"type": "integer", "value": "1"}, {"type": "integer", "value": "3"}]}`,
+		"invalid/key/empty.toml":    "= 1",
+		"invalid/key/dotted.toml":   "a = 1",
+	})
+	defer os.RemoveAll(dir)
+
+	var output, errorOutput bytes.Buffer
+	code := processMain(dir, true, &output, &errorOutput)
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	expected := `category        pass  fail
+invalid/key     1     1
+valid/array     0     1
+valid/datetime  1     0
+valid/float     1     0
+total           3     2
+`
+	if !strings.HasPrefix(output.String(), expected) {
+		t.Errorf("unexpected report:\n%s\nexpected:\n%s", output.String(), expected)
+	}
+	for _, failure := range []string{"invalid/key/dotted.toml: accepted", "valid/array/mixed.toml: decoded to"} {
+		if !strings.Contains(output.String(), filepath.FromSlash(failure)) {
+			t.Errorf("missing failure %q in:\n%s", failure, output.String())
+		}
+	}
+	if errorOutput.Len() > 0 {
+		t.Errorf("unexpected error output: %s", errorOutput.String())
+	}
+}
+
+func TestProcessMainEmptyDirectory(t *testing.T) {
+	dir := writeSuite(t, nil)
+	defer os.RemoveAll(dir)
+
+	var output, errorOutput bytes.Buffer
+	if code := processMain(dir, false, &output, &errorOutput); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(errorOutput.String(), "no valid or invalid documents") {
+		t.Errorf("unexpected error output: %s", errorOutput.String())
+	}
+}
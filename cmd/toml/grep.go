@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+type grepMatch struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Key    string `json:"key"`
+	Header string `json:"header"`
+	Text   string `json:"text"`
+}
+
+// runGrep prints the lines matching the pattern of cmd, and reports whether
+// none matched.
+func runGrep(cmd *command, input io.Reader, output io.Writer) (bool, error) {
+	pattern, err := regexp.Compile(cmd.query)
+	if err != nil {
+		return false, fmt.Errorf("invalid pattern %q: %s", cmd.query, err)
+	}
+	matches := []grepMatch{}
+	for _, file := range append([]string{cmd.file}, cmd.args...) {
+		var data []byte
+		if file == "-" {
+			data, err = ioutil.ReadAll(input)
+		} else {
+			data, err = ioutil.ReadFile(file)
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, m := range toml.Grep(data, pattern) {
+			matches = append(matches, grepMatch{file, m.Line, strings.Join(m.Key, "."), m.Header, m.Text})
+		}
+	}
+
+	if cmd.format == "json" {
+		return len(matches) == 0, writeJSON(output, matches)
+	}
+	for _, m := range matches {
+		context := ""
+		if m.Key != "" {
+			context = " " + m.Key + ":"
+		}
+		if _, err := fmt.Fprintf(output, "%s:%d:%s %s\n", m.File, m.Line, context, m.Text); err != nil {
+			return false, err
+		}
+	}
+	return len(matches) == 0, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const grepDocument = `# servers
+[[servers]]
+host = "a.example.com"
+aliases = [
+  "www.example.com",
+]
+
+[[servers]]
+host = "b.example.com"
+`
+
+func TestGrep(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"example"}, 0, "-:3: servers.0.host: host = \"a.example.com\"\n-:5: servers.0.aliases:   \"www.example.com\",\n-:9: servers.1.host: host = \"b.example.com\"\n"},
+		{[]string{"^#"}, 0, "-:1: # servers\n"},
+		{[]string{"-format", "json", "www"}, 0, "[\n  {\n    \"file\": \"-\",\n    \"line\": 5,\n    \"key\": \"servers.0.aliases\",\n    \"header\": \"[[servers]]\",\n    \"text\": \"  \\\"www.example.com\\\",\"\n  }\n]\n"},
+		{[]string{"missing"}, 1, ""},
+	} {
+		output := new(bytes.Buffer)
+		errorOutput := new(bytes.Buffer)
+		args := append(append([]string{"grep"}, test.args...), "-")
+		if code := processMain(args, strings.NewReader(grepDocument), output, errorOutput); code != test.code {
+			t.Errorf("%v: unexpected exit code %d: %s", args, code, errorOutput.String())
+		}
+		if output.String() != test.expected {
+			t.Errorf("%v: incorrect output:\n%q\nexpected:\n%q", test.args, output.String(), test.expected)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Severities of the lint rules. Off disables a rule.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+	severityOff     = "off"
+)
+
+// lintRules are the rules of the lint command, with their default severity.
+var lintRules = map[string]string{
+	"key-case":         severityWarning, // keys are not snake_case or kebab-case
+	"empty-table":      severityInfo,    // tables without any value
+	"mixed-array":      severityWarning, // arrays with values of different types
+	"duplicate-tables": severityInfo,    // tables with the same content
+	"string-type":      severityWarning, // strings holding a boolean or a number
+}
+
+// defaultLintConfig is the configuration file read when none is given.
+const defaultLintConfig = ".tomllint.toml"
+
+// lintConfig is the configuration of the lint command:
+//
+//   [rules]
+//   key-case = "error"
+//   empty-table = "off"
+//
+//   [[ignore]]
+//   path = "legacy/*.toml"
+//   keys = "plugins.*"
+//   rules = ["key-case"]
+//
+//   [string-type]
+//   keys = ["*.port", "*.enabled"]
+type lintConfig struct {
+	Rules      map[string]string `toml:"rules"`
+	Ignore     []lintIgnore      `toml:"ignore"`
+	StringType lintStringType    `toml:"string-type"`
+}
+
+// lintIgnore disables rules for the files matching Path and the keys
+// matching Keys, as patterns of filepath.Match. Empty fields match
+// everything.
+type lintIgnore struct {
+	Path  string   `toml:"path"`
+	Keys  string   `toml:"keys"`
+	Rules []string `toml:"rules"`
+}
+
+// lintStringType restricts the string-type rule to the keys matching one of
+// Keys, as patterns of filepath.Match. All keys are checked when empty.
+type lintStringType struct {
+	Keys []string `toml:"keys"`
+}
+
+type lintFinding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Key      string `json:"key"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func loadLintConfig(file string) (*lintConfig, error) {
+	config := &lintConfig{}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) && file == defaultLintConfig {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := toml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	for rule, severity := range config.Rules {
+		if _, ok := lintRules[rule]; !ok {
+			return nil, fmt.Errorf("%s: unknown rule %q", file, rule)
+		}
+		switch severity {
+		case severityError, severityWarning, severityInfo, severityOff:
+		default:
+			return nil, fmt.Errorf("%s: invalid severity %q of rule %s", file, severity, rule)
+		}
+	}
+	for _, pattern := range config.StringType.Keys {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: invalid key pattern %q", file, pattern)
+		}
+	}
+	return config, nil
+}
+
+func (c *lintConfig) severity(rule string) string {
+	if severity, ok := c.Rules[rule]; ok {
+		return severity
+	}
+	return lintRules[rule]
+}
+
+// checksStringType reports whether the string-type rule applies to key.
+func (c *lintConfig) checksStringType(key string) bool {
+	for _, pattern := range c.StringType.Keys {
+		if ok, _ := filepath.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return len(c.StringType.Keys) == 0
+}
+
+func (c *lintConfig) ignored(finding lintFinding) bool {
+	for _, ignore := range c.Ignore {
+		if ignore.Path != "" {
+			if ok, _ := filepath.Match(ignore.Path, finding.File); !ok {
+				continue
+			}
+		}
+		if ignore.Keys != "" {
+			if ok, _ := filepath.Match(ignore.Keys, finding.Key); !ok {
+				continue
+			}
+		}
+		if len(ignore.Rules) == 0 {
+			return true
+		}
+		for _, rule := range ignore.Rules {
+			if rule == finding.Rule {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lint returns the findings of the rules in tree, loaded from file.
+func lint(file string, tree *toml.Tree, config *lintConfig) []lintFinding {
+	var findings []lintFinding
+	report := func(rule string, path []string, pos toml.Position, format string, args ...interface{}) {
+		finding := lintFinding{
+			File:     file,
+			Line:     pos.Line,
+			Column:   pos.Col,
+			Key:      strings.Join(path, "."),
+			Rule:     rule,
+			Severity: config.severity(rule),
+			Message:  fmt.Sprintf(format, args...),
+		}
+		if finding.Severity != severityOff && !config.ignored(finding) {
+			findings = append(findings, finding)
+		}
+	}
+
+	var walk func(path []string, t *toml.Tree)
+	walk = func(path []string, t *toml.Tree) {
+		if len(path) > 0 && len(t.Keys()) == 0 {
+			report("empty-table", path, t.Position(), "table %s is empty", strings.Join(path, "."))
+		}
+		for _, k := range t.Keys() {
+			keyPath := append(append([]string{}, path...), k)
+			pos := t.GetPositionPath([]string{k})
+			if !conventionalKey(k) {
+				report("key-case", keyPath, pos, "key %q is neither snake_case nor kebab-case", k)
+			}
+			switch value := t.GetPath([]string{k}).(type) {
+			case string:
+				key := strings.Join(keyPath, ".")
+				if kind := literalType(value); kind != "" && config.checksStringType(key) {
+					report("string-type", keyPath, pos, "%s is the string %q instead of a %s", key, value, kind)
+				}
+			case *toml.Tree:
+				walk(keyPath, value)
+			case []*toml.Tree:
+				for _, item := range value {
+					walk(keyPath, item)
+				}
+			case []interface{}:
+				if len(value) < 2 {
+					break
+				}
+				for _, item := range value[1:] {
+					if reflect.TypeOf(item) != reflect.TypeOf(value[0]) {
+						report("mixed-array", keyPath, pos, "array %s mixes %s and %s", strings.Join(keyPath, "."), typeName(value[0]), typeName(item))
+						break
+					}
+				}
+			}
+		}
+	}
+	walk(nil, tree)
+
+	for _, group := range tree.DuplicateTables() {
+		sort.SliceStable(group, func(i, j int) bool {
+			return tree.GetPosition(group[i]).Line < tree.GetPosition(group[j]).Line
+		})
+		for _, key := range group[1:] {
+			report("duplicate-tables", strings.Split(key, "."), tree.GetPosition(key), "table %s has the same content as %s", key, group[0])
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+	return findings
+}
+
+// conventionalKey reports whether k is made of lower case letters, digits,
+// and underscores or dashes.
+func conventionalKey(k string) bool {
+	if strings.Contains(k, "_") && strings.Contains(k, "-") {
+		return false
+	}
+	for _, r := range k {
+		if !('a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// literalType returns "boolean" or "number" when s is written as a TOML
+// boolean or number, such as true or 8080, and "" otherwise.
+func literalType(s string) string {
+	value, err := toml.ParseValue([]byte(s))
+	if err != nil {
+		return ""
+	}
+	switch value := value.(type) {
+	case bool:
+		return "boolean"
+	case int64:
+		return "number"
+	case float64:
+		if !math.IsInf(value, 0) && !math.IsNaN(value) {
+			return "number"
+		}
+	}
+	return ""
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "strings"
+	case int64:
+		return "integers"
+	case float64:
+		return "floats"
+	case bool:
+		return "booleans"
+	case []interface{}:
+		return "arrays"
+	case *toml.Tree:
+		return "tables"
+	}
+	return "date-times"
+}
+
+func runLint(cmd *command, input io.Reader, output io.Writer) (bool, error) {
+	config, err := loadLintConfig(cmd.config)
+	if err != nil {
+		return false, err
+	}
+	findings := []lintFinding{}
+	for _, file := range append([]string{cmd.file}, cmd.args...) {
+		var tree *toml.Tree
+		if file == "-" {
+			tree, err = toml.LoadReader(input)
+		} else {
+			tree, err = toml.LoadFile(file)
+		}
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", file, err)
+		}
+		findings = append(findings, lint(file, tree, config)...)
+	}
+
+	switch cmd.format {
+	case "json":
+		err = writeJSON(output, findings)
+	case "sarif":
+		err = writeJSON(output, sarifLog(findings))
+	default:
+		for _, f := range findings {
+			if _, err = fmt.Fprintf(output, "%s:%d:%d: %s: %s (%s)\n", f.File, f.Line, f.Column, f.Severity, f.Message, f.Rule); err != nil {
+				break
+			}
+		}
+	}
+
+	failed := false
+	for _, f := range findings {
+		failed = failed || f.Severity == severityError
+	}
+	return failed, err
+}
+
+func writeJSON(output io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// sarifLog returns the findings in the Static Analysis Results Interchange
+// Format, version 2.1.0, read by code scanning services.
+func sarifLog(findings []lintFinding) interface{} {
+	type object = map[string]interface{}
+
+	var names []string
+	for rule := range lintRules {
+		names = append(names, rule)
+	}
+	sort.Strings(names)
+	rules := []object{}
+	for _, rule := range names {
+		rules = append(rules, object{"id": rule})
+	}
+
+	levels := map[string]string{severityError: "error", severityWarning: "warning", severityInfo: "note"}
+	results := []object{}
+	for _, f := range findings {
+		results = append(results, object{
+			"ruleId":  f.Rule,
+			"level":   levels[f.Severity],
+			"message": object{"text": f.Message},
+			"locations": []object{{
+				"physicalLocation": object{
+					"artifactLocation": object{"uri": filepath.ToSlash(f.File)},
+					"region":           object{"startLine": f.Line, "startColumn": f.Column},
+				},
+			}},
+		})
+	}
+
+	return object{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []object{{
+			"tool":    object{"driver": object{"name": "toml lint", "rules": rules}},
+			"results": results,
+		}},
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const lintDocument = `serverName = "api"
+ports = [80, "443"]
+
+[empty]
+
+[primary]
+host = "a"
+
+[backup]
+host = "a"
+`
+
+func runLintTest(t *testing.T, args []string, config string) (int, string) {
+	return runLintDocumentTest(t, lintDocument, args, config)
+}
+
+func runLintDocumentTest(t *testing.T, document string, args []string, config string) (int, string) {
+	dir, err := ioutil.TempDir("", "tomllint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, ".tomllint.toml")
+	if err := ioutil.WriteFile(configFile, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := new(bytes.Buffer)
+	errorOutput := new(bytes.Buffer)
+	args = append([]string{"lint", "-config", configFile}, args...)
+	code := processMain(append(args, "-"), strings.NewReader(document), output, errorOutput)
+	if errorOutput.Len() > 0 {
+		t.Log(errorOutput.String())
+	}
+	return code, output.String()
+}
+
+func TestLint(t *testing.T) {
+	code, output := runLintTest(t, nil, "")
+	expected := `-:1:1: warning: key "serverName" is neither snake_case nor kebab-case (key-case)
+-:2:1: warning: array ports mixes integers and strings (mixed-array)
+-:4:1: info: table empty is empty (empty-table)
+-:9:1: info: table backup has the same content as primary (duplicate-tables)
+`
+	if output != expected {
+		t.Errorf("incorrect output:\n%s\nexpected:\n%s", output, expected)
+	}
+	if code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+}
+
+func TestLintShortArrays(t *testing.T) {
+	output := new(bytes.Buffer)
+	errorOutput := new(bytes.Buffer)
+	code := processMain([]string{"lint", "-"}, strings.NewReader("none = []\none = [1]\n"), output, errorOutput)
+	if code != 0 || output.Len() > 0 || errorOutput.Len() > 0 {
+		t.Errorf("unexpected result %d:\n%s%s", code, output.String(), errorOutput.String())
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	config := `
+[rules]
+key-case = "error"
+empty-table = "off"
+
+[[ignore]]
+keys = "backup"
+`
+	code, output := runLintTest(t, nil, config)
+	expected := `-:1:1: error: key "serverName" is neither snake_case nor kebab-case (key-case)
+-:2:1: warning: array ports mixes integers and strings (mixed-array)
+`
+	if output != expected {
+		t.Errorf("incorrect output:\n%s\nexpected:\n%s", output, expected)
+	}
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+
+	if code, _ := runLintTest(t, nil, "[rules]\nkey-case = \"fatal\""); code != 1 {
+		t.Errorf("expected an invalid severity to fail, got %d", code)
+	}
+}
+
+func TestLintFormats(t *testing.T) {
+	_, output := runLintTest(t, []string{"-format", "json"}, "")
+	var findings []lintFinding
+	if err := json.Unmarshal([]byte(output), &findings); err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 4 || findings[0].Rule != "key-case" || findings[0].Key != "serverName" {
+		t.Errorf("unexpected findings: %+v", findings)
+	}
+
+	_, output = runLintTest(t, []string{"-format", "sarif"}, "")
+	var log struct {
+		Version string
+		Runs    []struct {
+			Results []struct {
+				RuleID string
+				Level  string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(output), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 4 || log.Runs[0].Results[2].Level != "note" {
+		t.Errorf("unexpected SARIF log: %s", output)
+	}
+}
+
+func TestLintStringType(t *testing.T) {
+	document := `name = "api"
+version = "2"
+
+[server]
+port = "8080"
+enabled = "true"
+ratio = "0.5"
+mode = "inf"
+`
+	_, output := runLintDocumentTest(t, document, nil, "")
+	expected := `-:2:1: warning: version is the string "2" instead of a number (string-type)
+-:5:1: warning: server.port is the string "8080" instead of a number (string-type)
+-:6:1: warning: server.enabled is the string "true" instead of a boolean (string-type)
+-:7:1: warning: server.ratio is the string "0.5" instead of a number (string-type)
+`
+	if output != expected {
+		t.Errorf("incorrect output:\n%s\nexpected:\n%s", output, expected)
+	}
+
+	_, output = runLintDocumentTest(t, document, nil, "[string-type]\nkeys = [\"server.port\", \"*.enabled\"]")
+	expected = `-:5:1: warning: server.port is the string "8080" instead of a number (string-type)
+-:6:1: warning: server.enabled is the string "true" instead of a boolean (string-type)
+`
+	if output != expected {
+		t.Errorf("incorrect output:\n%s\nexpected:\n%s", output, expected)
+	}
+
+	if code, _ := runLintDocumentTest(t, document, nil, "[string-type]\nkeys = [\"[\"]"); code != 1 {
+		t.Errorf("expected an invalid pattern to fail, got %d", code)
+	}
+}
@@ -0,0 +1,529 @@
+// Toml reads and edits values of TOML documents from the command line.
+//
+// Usage:
+//   toml get config.toml server.port
+//   toml set -i --int config.toml server.port 8080
+//   toml set -i --raw config.toml server.hosts '["a", "b"]'
+//   toml unset -i config.toml server.debug
+//   toml append -i config.toml server.users bob
+//   toml merge config.toml overrides.toml > merged.toml
+//   toml lint -format sarif config.toml > lint.sarif
+//   toml query -format json '$.servers.host' config.toml
+//   toml verify -schema config.schema.json config.toml
+//   toml grep localhost config.toml
+//
+// Documents are read from the given file, or from STDIN when the file is -,
+// and written to STDOUT, unless -i is given to update the file in place. Keys
+// are dotted keys, quoted as in a document when they contain dots.
+//
+// Set, append and unset only replace the line of the value when it is
+// written on a single line, keeping the rest of the document as is. Other
+// changes, such as new keys, values spanning several lines and merges,
+// rewrite the whole document from its tree, which only keeps the comments
+// written after values and indents the keys of tables.
+//
+// The lint command reports keys that are neither snake_case nor kebab-case,
+// empty tables, arrays mixing types, duplicate tables and strings holding
+// booleans or numbers, such as enabled = "true". The severity of each rule,
+// the keys whose strings are checked, and the files and keys to ignore, are
+// read from .tomllint.toml, see lintConfig. The exit code is 1 when a finding
+// has the error severity.
+//
+// The query command prints the values matching a query of the
+// github.com/pelletier/go-toml/query package, one per line, as a JSON array
+// or as the results array of a TOML document.
+//
+// The verify command validates documents against a JSON Schema, see
+// jsonSchema for the supported keywords, and prints the violations with
+// their position. The exit code is 1 when a document is not valid.
+//
+// The grep command prints the lines matching a regular expression with the
+// key they belong to, such as servers.0.host, or as JSON objects with the
+// header of their table too. As for grep, the exit code is 1 when no line
+// matches.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+func main() {
+	os.Exit(processMain(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func usage(errorOutput io.Writer) {
+	fmt.Fprintln(errorOutput, "toml reads and edits values of TOML documents:")
+	fmt.Fprintln(errorOutput, "  toml get FILE KEY")
+	fmt.Fprintln(errorOutput, "  toml set [-i] [--int|--float|--bool|--raw] FILE KEY VALUE")
+	fmt.Fprintln(errorOutput, "  toml unset [-i] FILE KEY")
+	fmt.Fprintln(errorOutput, "  toml append [-i] [--int|--float|--bool|--raw] FILE KEY VALUE")
+	fmt.Fprintln(errorOutput, "  toml merge [-i] FILE OTHER...")
+	fmt.Fprintln(errorOutput, "  toml lint [-config .tomllint.toml] [-format text|json|sarif] FILE...")
+	fmt.Fprintln(errorOutput, "  toml query [-format raw|json|toml] QUERY FILE...")
+	fmt.Fprintln(errorOutput, "  toml verify -schema SCHEMA.json FILE...")
+	fmt.Fprintln(errorOutput, "  toml grep [-format text|json] PATTERN FILE...")
+	fmt.Fprintln(errorOutput, "")
+	fmt.Fprintln(errorOutput, "FILE is read from STDIN when it is -. Values are strings unless a type flag")
+	fmt.Fprintln(errorOutput, "is given; --raw values are written as in a document, such as [1, 2].")
+	fmt.Fprintln(errorOutput, "Merging replaces the values of FILE by the ones of OTHER, except tables, which")
+	fmt.Fprintln(errorOutput, "are merged. Values written on one line are replaced in place; other changes")
+	fmt.Fprintln(errorOutput, "rewrite the document, which only keeps the comments written after values.")
+}
+
+// command is a parsed command line.
+type command struct {
+	name    string
+	inPlace bool
+	kind    string // int, float, bool, raw or empty for strings
+	file    string
+	args    []string
+	config  string // lint configuration file
+	format  string // lint, query and grep output format
+	query   string // query or grep pattern
+	schema  string // JSON Schema file of verify
+}
+
+func parseCommand(args []string, errorOutput io.Writer) (*command, error) {
+	if len(args) == 0 {
+		return nil, errors.New("missing command")
+	}
+	cmd := &command{name: args[0]}
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.SetOutput(errorOutput)
+	var nargs int
+	switch cmd.name {
+	case "get":
+		nargs = 2
+	case "unset":
+		nargs = 2
+		flags.BoolVar(&cmd.inPlace, "i", false, "update the file in place")
+	case "set", "append":
+		nargs = 3
+		flags.BoolVar(&cmd.inPlace, "i", false, "update the file in place")
+	case "merge":
+		nargs = -2
+		flags.BoolVar(&cmd.inPlace, "i", false, "update the file in place")
+	case "lint":
+		nargs = -1
+		flags.StringVar(&cmd.config, "config", defaultLintConfig, "configuration of the rules")
+		flags.StringVar(&cmd.format, "format", "text", "output format: text, json or sarif")
+	case "verify":
+		nargs = -1
+		flags.StringVar(&cmd.schema, "schema", "", "JSON Schema of the documents")
+	case "query":
+		nargs = -2
+		flags.StringVar(&cmd.format, "format", "raw", "output format: raw, json or toml")
+	case "grep":
+		nargs = -2
+		flags.StringVar(&cmd.format, "format", "text", "output format: text or json")
+	default:
+		return nil, fmt.Errorf("unknown command %q", cmd.name)
+	}
+	kinds := map[string]*bool{}
+	if nargs == 3 {
+		for _, kind := range []string{"int", "float", "bool", "raw"} {
+			kinds[kind] = flags.Bool(kind, false, "parse the value as "+kind)
+		}
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+	for kind, set := range kinds {
+		if !*set {
+			continue
+		}
+		if cmd.kind != "" {
+			return nil, errors.New("only one of --int, --float, --bool and --raw can be given")
+		}
+		cmd.kind = kind
+	}
+	if nargs > 0 && flags.NArg() != nargs || nargs < 0 && flags.NArg() < -nargs {
+		return nil, fmt.Errorf("wrong number of arguments for %s", cmd.name)
+	}
+	args = flags.Args()
+	if cmd.name == "query" || cmd.name == "grep" {
+		cmd.query, args = args[0], args[1:]
+	}
+	cmd.file = args[0]
+	cmd.args = args[1:]
+	if cmd.inPlace && cmd.file == "-" {
+		return nil, errors.New("-i needs a file")
+	}
+	if cmd.name == "verify" && cmd.schema == "" {
+		return nil, errors.New("missing -schema")
+	}
+	formats := map[string][]string{
+		"lint":  {"text", "json", "sarif"},
+		"query": {"raw", "json", "toml"},
+		"grep":  {"text", "json"},
+	}
+	if valid, ok := formats[cmd.name]; ok && !contains(valid, cmd.format) {
+		return nil, fmt.Errorf("unknown format %q", cmd.format)
+	}
+	return cmd, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func processMain(args []string, input io.Reader, output io.Writer, errorOutput io.Writer) int {
+	cmd, err := parseCommand(args, errorOutput)
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(errorOutput, err)
+		}
+		usage(errorOutput)
+		return 2
+	}
+	if cmd.name == "query" {
+		if err := runQuery(cmd, input, output); err != nil {
+			fmt.Fprintln(errorOutput, err)
+			return 1
+		}
+		return 0
+	}
+	if cmd.name == "lint" || cmd.name == "verify" || cmd.name == "grep" {
+		runCheck := runLint
+		switch cmd.name {
+		case "verify":
+			runCheck = runVerify
+		case "grep":
+			runCheck = runGrep
+		}
+		failed, err := runCheck(cmd, input, output)
+		if err != nil {
+			fmt.Fprintln(errorOutput, err)
+		}
+		if failed || err != nil {
+			return 1
+		}
+		return 0
+	}
+	if err := run(cmd, input, output); err != nil {
+		fmt.Fprintln(errorOutput, err)
+		return 1
+	}
+	return 0
+}
+
+func run(cmd *command, input io.Reader, output io.Writer) error {
+	var document []byte
+	var err error
+	if cmd.file == "-" {
+		document, err = ioutil.ReadAll(input)
+	} else {
+		document, err = ioutil.ReadFile(cmd.file)
+	}
+	if err != nil {
+		return err
+	}
+	tree, err := toml.LoadBytes(document)
+	if err != nil {
+		return err
+	}
+	// new values are written after the existing ones
+	end := bytes.Count(document, []byte("\n")) + 2
+
+	if cmd.name == "get" {
+		return get(tree, cmd.args[0], output)
+	}
+	lines := strings.Split(string(document), "\n")
+	var line *valueLine
+	if cmd.name != "merge" {
+		line = findValueLine(lines, tree, cmd.args[0])
+	}
+	switch cmd.name {
+	case "set", "append":
+		err = set(tree, cmd.name == "append", cmd.args[0], cmd.kind, cmd.args[1], end)
+	case "unset":
+		err = unset(tree, cmd.args[0])
+	case "merge":
+		for _, file := range cmd.args {
+			other, errl := toml.LoadFile(file)
+			if errl != nil {
+				return errl
+			}
+			end = merge(tree, other, end)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if spliced, ok := line.replace(lines, tree); ok {
+		b.WriteString(strings.Join(spliced, "\n"))
+	} else if err := toml.NewEncoder(&b).Order(toml.OrderPreserve).Encode(tree); err != nil {
+		return err
+	}
+	if cmd.inPlace {
+		return ioutil.WriteFile(cmd.file, b.Bytes(), 0644)
+	}
+	_, err = output.Write(b.Bytes())
+	return err
+}
+
+// splitKey returns the parts of a dotted key, parsed as in a document.
+func splitKey(key string) ([]string, error) {
+	tree, err := toml.Load(key + " = 0")
+	if err != nil || len(tree.Keys()) != 1 {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	var path []string
+	for {
+		k := tree.Keys()[0]
+		path = append(path, k)
+		sub, ok := tree.GetPath([]string{k}).(*toml.Tree)
+		if !ok {
+			return path, nil
+		}
+		tree = sub
+	}
+}
+
+func get(tree *toml.Tree, key string, output io.Writer) error {
+	path, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	value := tree.GetPath(path)
+	if value == nil {
+		return fmt.Errorf("no such key: %s", key)
+	}
+	return printValue(output, value)
+}
+
+// printValue prints strings as is, tables as documents and other values as
+// in a document.
+func printValue(output io.Writer, value interface{}) error {
+	var err error
+	switch value := value.(type) {
+	case string:
+		_, err = fmt.Fprintln(output, value)
+	case *toml.Tree:
+		err = toml.NewEncoder(output).Order(toml.OrderPreserve).Encode(value)
+	default:
+		var repr string
+		repr, err = toml.ValueStringRepresentation(value, "", "", toml.OrderPreserve, false)
+		if err == nil {
+			_, err = fmt.Fprintln(output, repr)
+		}
+	}
+	return err
+}
+
+func parseValue(kind, value string) (interface{}, error) {
+	switch kind {
+	case "int":
+		return strconv.ParseInt(value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	case "raw":
+		tree, err := toml.Load("value = " + value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s: %s", value, err)
+		}
+		return tree.Get("value"), nil
+	}
+	return value, nil
+}
+
+func set(tree *toml.Tree, appending bool, key, kind, raw string, end int) error {
+	path, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	value, err := parseValue(kind, raw)
+	if err != nil {
+		return err
+	}
+	if appending {
+		switch array := tree.GetPath(path).(type) {
+		case nil:
+			if table, ok := value.(*toml.Tree); ok {
+				value = []*toml.Tree{table}
+			} else {
+				value = []interface{}{value}
+			}
+		case []interface{}:
+			value = append(array, value)
+		case []*toml.Tree:
+			table, ok := value.(*toml.Tree)
+			if !ok {
+				return fmt.Errorf("%s is an array of tables, not of %T", key, value)
+			}
+			value = append(array, table)
+		default:
+			return fmt.Errorf("%s is not an array", key)
+		}
+	}
+	setKeepingPosition(tree, path, value, end)
+	return nil
+}
+
+// setKeepingPosition sets the value at path where the previous value was, or
+// at line end when there was none.
+func setKeepingPosition(tree *toml.Tree, path []string, value interface{}, end int) {
+	pos := tree.GetPositionPath(path)
+	tree.SetPath(path, value)
+	if pos.Line != 0 {
+		tree.SetPositionPath(path, pos)
+		return
+	}
+	for i := 1; i <= len(path); i++ {
+		if i == len(path) || tree.GetPositionPath(path[:i]).Line == 0 {
+			tree.SetPositionPath(path[:i], toml.Position{Line: end, Col: 1})
+		}
+	}
+}
+
+func unset(tree *toml.Tree, key string) error {
+	path, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	if !tree.HasPath(path) {
+		return fmt.Errorf("no such key: %s", key)
+	}
+	return tree.DeletePath(path)
+}
+
+// valueLine is a line of a document holding a single key/value pair, such as
+// port = 80 # default port.
+type valueLine struct {
+	path   []string
+	index  int    // of the line in the document
+	key    string // before the value, indentation and spaces included
+	suffix string // after the value, comment included
+}
+
+// findValueLine returns the line of the value at key, or nil when the value
+// is missing or spans several lines.
+func findValueLine(lines []string, tree *toml.Tree, key string) *valueLine {
+	path, err := splitKey(key)
+	if err != nil {
+		return nil
+	}
+	pos := tree.GetPositionPath(path)
+	if pos.Line == 0 || pos.Line > len(lines) {
+		return nil
+	}
+	// columns count runes
+	line := []rune(lines[pos.Line-1])
+	if pos.Col < 1 || pos.Col > len(line) {
+		return nil
+	}
+	indent, rest := string(line[:pos.Col-1]), string(line[pos.Col-1:])
+	if strings.TrimSpace(indent) != "" {
+		return nil
+	}
+	for end := 1; end <= len(rest); end++ {
+		suffix := strings.TrimSpace(rest[end:])
+		if suffix != "" && suffix[0] != '#' {
+			continue
+		}
+		pair, err := toml.Load(rest[:end])
+		if err != nil || !isPair(pair, path[len(path)-1]) {
+			continue
+		}
+		for eq := 0; eq < end; eq++ {
+			if rest[eq] != '=' {
+				continue
+			}
+			if _, err := toml.Load(rest[:eq] + "= 0"); err != nil {
+				continue
+			}
+			value := rest[eq+1 : end]
+			spaces := len(value) - len(strings.TrimLeft(value, " \t"))
+			return &valueLine{
+				path:   path,
+				index:  pos.Line - 1,
+				key:    indent + rest[:eq+1+spaces],
+				suffix: rest[end:],
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// isPair returns whether tree holds a single key/value pair, the key ending
+// with last.
+func isPair(tree *toml.Tree, last string) bool {
+	for {
+		keys := tree.Keys()
+		if len(keys) != 1 {
+			return false
+		}
+		sub, ok := tree.Get(keys[0]).(*toml.Tree)
+		if !ok {
+			return keys[0] == last
+		}
+		tree = sub
+	}
+}
+
+// replace returns the lines with the new value of the line, without the line
+// when the value was unset, or false when there is no line or the value needs
+// several lines or tables.
+func (l *valueLine) replace(lines []string, tree *toml.Tree) ([]string, bool) {
+	if l == nil {
+		return nil, false
+	}
+	value := tree.GetPath(l.path)
+	switch value.(type) {
+	case nil:
+		return append(lines[:l.index:l.index], lines[l.index+1:]...), true
+	case *toml.Tree, []*toml.Tree:
+		return nil, false
+	}
+	repr, err := toml.ValueStringRepresentation(value, "", "", toml.OrderPreserve, false)
+	if err != nil || strings.ContainsAny(repr, "\r\n") {
+		return nil, false
+	}
+	lines[l.index] = l.key + repr + l.suffix
+	return lines, true
+}
+
+// merge sets the values of other in tree, merging tables, and returns the
+// line after the values added.
+func merge(tree, other *toml.Tree, end int) int {
+	keys := other.Keys()
+	sort.Slice(keys, func(i, j int) bool {
+		return other.GetPositionPath([]string{keys[i]}).Line < other.GetPositionPath([]string{keys[j]}).Line
+	})
+	for _, k := range keys {
+		path := []string{k}
+		value := other.GetPath(path)
+		if table, ok := value.(*toml.Tree); ok {
+			if dest, ok := tree.GetPath(path).(*toml.Tree); ok {
+				end = merge(dest, table, end)
+				continue
+			}
+		}
+		if !tree.HasPath(path) {
+			end++
+		}
+		setKeepingPosition(tree, path, value, end)
+	}
+	return end
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const document = `title = "example"
+
+[server]
+host = "localhost"
+port = 8080
+users = ["alice"]
+
+[database]
+enabled = true
+`
+
+func expectProcessMainResults(t *testing.T, args []string, exitCode int, expectedOutput string) {
+	outputBuffer := new(bytes.Buffer)
+	errorBuffer := new(bytes.Buffer)
+
+	returnCode := processMain(args, strings.NewReader(document), outputBuffer, errorBuffer)
+
+	if output := outputBuffer.String(); output != expectedOutput {
+		t.Errorf("%v: incorrect output:\n%s\nexpected:\n%s", args, output, expectedOutput)
+	}
+	if returnCode != exitCode {
+		t.Errorf("%v: incorrect return code %d, expected %d: %s", args, returnCode, exitCode, errorBuffer.String())
+	}
+}
+
+func TestGet(t *testing.T) {
+	expectProcessMainResults(t, []string{"get", "-", "title"}, 0, "example\n")
+	expectProcessMainResults(t, []string{"get", "-", "server.port"}, 0, "8080\n")
+	expectProcessMainResults(t, []string{"get", "-", "server.users"}, 0, "[\"alice\"]\n")
+	expectProcessMainResults(t, []string{"get", "-", "database"}, 0, "enabled = true\n")
+	expectProcessMainResults(t, []string{"get", "-", "server.missing"}, 1, "")
+}
+
+func TestSet(t *testing.T) {
+	expectProcessMainResults(t, []string{"set", "--int", "-", "server.port", "9090"}, 0, strings.Replace(document, "8080", "9090", 1))
+	expectProcessMainResults(t, []string{"set", "--raw", "-", "server.tls.ports", "[443, 8443]"}, 0, `title = "example"
+
+[server]
+  host = "localhost"
+  port = 8080
+  users = ["alice"]
+
+  [server.tls]
+    ports = [443, 8443]
+
+[database]
+  enabled = true
+`)
+	expectProcessMainResults(t, []string{"set", "--int", "-", "server.port", "high"}, 1, "")
+	expectProcessMainResults(t, []string{"set", "--int", "--bool", "-", "server.port", "1"}, 2, "")
+}
+
+func TestUnsetAndAppend(t *testing.T) {
+	expectProcessMainResults(t, []string{"unset", "-", "server.users"}, 0, strings.Replace(document, "users = [\"alice\"]\n", "", 1))
+	expectProcessMainResults(t, []string{"unset", "-", "server.missing"}, 1, "")
+	expectProcessMainResults(t, []string{"append", "-", "server.users", "bob"}, 0, strings.Replace(document, `["alice"]`, `["alice", "bob"]`, 1))
+	expectProcessMainResults(t, []string{"append", "-", "server.host", "bob"}, 1, "")
+}
+
+func TestSetInPlaceKeepsComments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.toml")
+	original := `# web server
+[server]
+host = "a" # host name
+  "a=b" = 'c # d' # quoted
+port.http   =  80 # default port
+hosts = [
+  "a",
+  "b",
+]
+`
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"set", "-i", "--int", file, "server.port.http", "8080"}, strings.Replace(original, "=  80 #", "=  8080 #", 1)},
+		{[]string{"set", "-i", file, `server."a=b"`, "e"}, strings.Replace(original, `'c # d'`, `"e"`, 1)},
+		{[]string{"unset", "-i", file, "server.host"}, strings.Replace(original, "host = \"a\" # host name\n", "", 1)},
+		// arrays on several lines need the document to be rewritten
+		{[]string{"append", "-i", file, "server.hosts", "c"}, `
+[server]
+  host = "a" # host name
+  "a=b" = "c # d" # quoted
+  hosts = ["a", "b", "c"]
+
+  [server.port]
+    http = 80 # default port
+`},
+	}
+	for _, test := range tests {
+		if err := ioutil.WriteFile(file, []byte(original), 0644); err != nil {
+			t.Fatal(err)
+		}
+		expectProcessMainResults(t, test.args, 0, "")
+		updated, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(updated) != test.expected {
+			t.Errorf("%v: incorrect file:\n%s\nexpected:\n%s", test.args, updated, test.expected)
+		}
+	}
+}
+
+func TestMergeInPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.toml")
+	overrides := filepath.Join(dir, "overrides.toml")
+	if err := ioutil.WriteFile(file, []byte(document), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(overrides, []byte("[server]\nport = 80\n\n[cache]\nsize = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expectProcessMainResults(t, []string{"merge", "-i", file, overrides}, 0, "")
+	merged, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `title = "example"
+
+[server]
+  host = "localhost"
+  port = 80
+  users = ["alice"]
+
+[database]
+  enabled = true
+
+[cache]
+  size = 10
+`
+	if string(merged) != expected {
+		t.Errorf("incorrect merged file:\n%s\nexpected:\n%s", merged, expected)
+	}
+}
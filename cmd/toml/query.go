@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pelletier/go-toml/query"
+)
+
+func runQuery(cmd *command, input io.Reader, output io.Writer) error {
+	q, err := query.Compile(cmd.query)
+	if err != nil {
+		return fmt.Errorf("invalid query %q: %s", cmd.query, err)
+	}
+	var values []interface{}
+	for _, file := range append([]string{cmd.file}, cmd.args...) {
+		var tree *toml.Tree
+		if file == "-" {
+			tree, err = toml.LoadReader(input)
+		} else {
+			tree, err = toml.LoadFile(file)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		values = append(values, documentOrder(q.Execute(tree))...)
+	}
+
+	switch cmd.format {
+	case "json":
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			items[i] = jsonValue(value)
+		}
+		return writeJSON(output, items)
+	case "toml":
+		results, err := toml.TreeFromMap(map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+		results.Set("results", resultsArray(values))
+		return toml.NewEncoder(output).Order(toml.OrderPreserve).Encode(results)
+	}
+	for _, value := range values {
+		if err := printValue(output, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// documentOrder returns the values of result sorted by position.
+func documentOrder(result *query.Result) []interface{} {
+	values := result.Values()
+	positions := result.Positions()
+	indexes := make([]int, len(values))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := positions[indexes[i]], positions[indexes[j]]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	sorted := make([]interface{}, len(values))
+	for i, index := range indexes {
+		sorted[i] = values[index]
+	}
+	return sorted
+}
+
+// jsonValue returns value with its tables converted to maps.
+func jsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case *toml.Tree:
+		return value.ToMap()
+	case []*toml.Tree:
+		items := make([]interface{}, len(value))
+		for i, tree := range value {
+			items[i] = tree.ToMap()
+		}
+		return items
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = jsonValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// resultsArray returns the values as an array of tables when they all are
+// tables, and as an array of values otherwise.
+func resultsArray(values []interface{}) interface{} {
+	tables := make([]*toml.Tree, len(values))
+	for i, value := range values {
+		table, ok := value.(*toml.Tree)
+		if !ok {
+			return values
+		}
+		tables[i] = table
+	}
+	if len(tables) == 0 {
+		return values
+	}
+	return tables
+}
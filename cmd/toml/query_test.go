@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const queryDocument = `[[servers]]
+host = "a.example.com"
+port = 80
+
+[[servers]]
+host = "b.example.com"
+port = 8080
+`
+
+func runQueryTest(t *testing.T, args ...string) string {
+	output := new(bytes.Buffer)
+	errorOutput := new(bytes.Buffer)
+	args = append(append([]string{"query"}, args...), "-")
+	if code := processMain(args, strings.NewReader(queryDocument), output, errorOutput); code != 0 {
+		t.Errorf("%v: unexpected exit code %d: %s", args, code, errorOutput.String())
+	}
+	return output.String()
+}
+
+func TestQuery(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"$.servers.host"}, "a.example.com\nb.example.com\n"},
+		{[]string{"-format", "json", "$.servers.port"}, "[\n  80,\n  8080\n]\n"},
+		{[]string{"-format", "json", "$.servers[1]"}, "[\n  {\n    \"host\": \"b.example.com\",\n    \"port\": 8080\n  }\n]\n"},
+		{[]string{"-format", "toml", "$.servers.port"}, "results = [80, 8080]\n"},
+		{[]string{"-format", "toml", "$.servers[0]"}, "\n[[results]]\n  host = \"a.example.com\"\n  port = 80\n"},
+		{[]string{"$.missing"}, ""},
+	} {
+		if output := runQueryTest(t, test.args...); output != test.expected {
+			t.Errorf("%v: incorrect output:\n%q\nexpected:\n%q", test.args, output, test.expected)
+		}
+	}
+
+	errorOutput := new(bytes.Buffer)
+	if code := processMain([]string{"query", "$.[", "-"}, strings.NewReader(queryDocument), new(bytes.Buffer), errorOutput); code != 1 {
+		t.Errorf("expected an invalid query to fail, got %d", code)
+	}
+}
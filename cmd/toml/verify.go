@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pelletier/go-toml"
+)
+
+// jsonSchema is the subset of JSON Schema supported by the verify command:
+// type, enum, properties, required, additionalProperties, items, minimum,
+// maximum, minLength, maxLength, pattern, minItems and maxItems. Other
+// keywords are ignored. Date-times are strings.
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+
+	pattern *regexp.Regexp
+}
+
+// schemaTypes is the type keyword, a name or a list of names.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*t = schemaTypes{name}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// additionalProperties is the additionalProperties keyword, false or a
+// schema of the properties.
+type additionalProperties struct {
+	forbidden bool
+	schema    *jsonSchema
+}
+
+func (a *additionalProperties) UnmarshalJSON(b []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(b, &allowed); err == nil {
+		a.forbidden = !allowed
+		return nil
+	}
+	return json.Unmarshal(b, &a.schema)
+}
+
+func loadSchema(file string) (*jsonSchema, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	schema := &jsonSchema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	if err := schema.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return schema, nil
+}
+
+// compile compiles the patterns of s and its sub-schemas.
+func (s *jsonSchema) compile() error {
+	if s == nil {
+		return nil
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = pattern
+	}
+	for _, property := range s.Properties {
+		if err := property.compile(); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalProperties != nil {
+		if err := s.AdditionalProperties.schema.compile(); err != nil {
+			return err
+		}
+	}
+	return s.Items.compile()
+}
+
+type violation struct {
+	pos     toml.Position
+	key     string
+	message string
+}
+
+// verifier collects the violations of a document.
+type verifier struct {
+	violations []violation
+}
+
+func (v *verifier) report(path []string, pos toml.Position, format string, args ...interface{}) {
+	key := strings.Join(path, ".")
+	if key == "" {
+		key = "(root)"
+	}
+	v.violations = append(v.violations, violation{pos, key, fmt.Sprintf(format, args...)})
+}
+
+// schemaType returns the JSON Schema type of a value of a tree.
+func schemaType(value interface{}) string {
+	switch value.(type) {
+	case *toml.Tree:
+		return "object"
+	case []*toml.Tree, []interface{}:
+		return "array"
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "string"
+}
+
+func (v *verifier) verify(s *jsonSchema, value interface{}, path []string, pos toml.Position) {
+	typ := schemaType(value)
+	if len(s.Type) > 0 {
+		matches := false
+		for _, t := range s.Type {
+			matches = matches || t == typ || t == "number" && typ == "integer"
+		}
+		if !matches {
+			v.report(path, pos, "expected %s, not %s", strings.Join(s.Type, " or "), typ)
+			return
+		}
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			found = found || fmt.Sprint(allowed) == fmt.Sprint(value)
+		}
+		if !found {
+			v.report(path, pos, "%v is not one of %v", value, s.Enum)
+		}
+	}
+
+	switch value := value.(type) {
+	case *toml.Tree:
+		v.verifyTable(s, value, path)
+	case []*toml.Tree:
+		v.verifyLength(s, len(value), path, pos)
+		for i, item := range value {
+			if s.Items != nil {
+				v.verify(s.Items, item, append(path, fmt.Sprint(i)), item.Position())
+			}
+		}
+	case []interface{}:
+		v.verifyLength(s, len(value), path, pos)
+		for i, item := range value {
+			if s.Items != nil {
+				v.verify(s.Items, item, append(path, fmt.Sprint(i)), pos)
+			}
+		}
+	case int64:
+		v.verifyNumber(s, float64(value), path, pos)
+	case float64:
+		v.verifyNumber(s, value, path, pos)
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.MinLength != nil && length < *s.MinLength {
+			v.report(path, pos, "%q is shorter than %d characters", value, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			v.report(path, pos, "%q is longer than %d characters", value, *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			v.report(path, pos, "%q does not match %s", value, s.Pattern)
+		}
+	}
+}
+
+func (v *verifier) verifyTable(s *jsonSchema, tree *toml.Tree, path []string) {
+	for _, name := range s.Required {
+		if !tree.HasPath([]string{name}) {
+			v.report(path, tree.Position(), "missing required key %s", name)
+		}
+	}
+	keys := tree.Keys()
+	sort.Strings(keys)
+	for _, k := range keys {
+		property, ok := s.Properties[k]
+		if !ok && s.AdditionalProperties != nil {
+			if s.AdditionalProperties.forbidden {
+				v.report(path, tree.GetPositionPath([]string{k}), "unexpected key %s", k)
+				continue
+			}
+			property = s.AdditionalProperties.schema
+		}
+		if property == nil {
+			continue
+		}
+		value := tree.GetPath([]string{k})
+		pos := tree.GetPositionPath([]string{k})
+		if tables, ok := value.([]*toml.Tree); ok && len(tables) > 0 {
+			// the position of an array of tables is the one of its last table
+			pos = tables[0].Position()
+		}
+		v.verify(property, value, append(path[:len(path):len(path)], k), pos)
+	}
+}
+
+func (v *verifier) verifyLength(s *jsonSchema, length int, path []string, pos toml.Position) {
+	if s.MinItems != nil && length < *s.MinItems {
+		v.report(path, pos, "expected at least %d items, not %d", *s.MinItems, length)
+	}
+	if s.MaxItems != nil && length > *s.MaxItems {
+		v.report(path, pos, "expected at most %d items, not %d", *s.MaxItems, length)
+	}
+}
+
+func (v *verifier) verifyNumber(s *jsonSchema, n float64, path []string, pos toml.Position) {
+	if s.Minimum != nil && n < *s.Minimum {
+		v.report(path, pos, "%v is less than %v", n, *s.Minimum)
+	}
+	if s.Maximum != nil && n > *s.Maximum {
+		v.report(path, pos, "%v is greater than %v", n, *s.Maximum)
+	}
+}
+
+func runVerify(cmd *command, input io.Reader, output io.Writer) (bool, error) {
+	schema, err := loadSchema(cmd.schema)
+	if err != nil {
+		return false, err
+	}
+	failed := false
+	for _, file := range append([]string{cmd.file}, cmd.args...) {
+		var tree *toml.Tree
+		if file == "-" {
+			tree, err = toml.LoadReader(input)
+		} else {
+			tree, err = toml.LoadFile(file)
+		}
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", file, err)
+		}
+		v := &verifier{}
+		v.verify(schema, tree, nil, tree.Position())
+		sort.SliceStable(v.violations, func(i, j int) bool {
+			a, b := v.violations[i].pos, v.violations[j].pos
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			return a.Col < b.Col
+		})
+		for _, violation := range v.violations {
+			failed = true
+			if _, err := fmt.Fprintf(output, "%s:%d:%d: %s: %s\n", file, violation.pos.Line, violation.pos.Col, violation.key, violation.message); err != nil {
+				return failed, err
+			}
+		}
+	}
+	return failed, nil
+}
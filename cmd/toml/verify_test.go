@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const verifySchema = `{
+  "type": "object",
+  "required": ["name", "server"],
+  "additionalProperties": false,
+  "properties": {
+    "name": {"type": "string", "minLength": 1},
+    "level": {"enum": ["debug", "info"]},
+    "server": {
+      "type": "object",
+      "properties": {
+        "host": {"type": "string", "pattern": "^[a-z.]+$"},
+        "port": {"type": "integer", "minimum": 1, "maximum": 65535}
+      }
+    },
+    "users": {
+      "type": "array",
+      "maxItems": 2,
+      "items": {"type": "object", "required": ["id"]}
+    }
+  }
+}`
+
+func runVerifyTest(t *testing.T, document string) (int, string) {
+	dir, err := ioutil.TempDir("", "tomlverify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	schema := filepath.Join(dir, "schema.json")
+	if err := ioutil.WriteFile(schema, []byte(verifySchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := new(bytes.Buffer)
+	errorOutput := new(bytes.Buffer)
+	code := processMain([]string{"verify", "-schema", schema, "-"}, strings.NewReader(document), output, errorOutput)
+	if errorOutput.Len() > 0 {
+		t.Log(errorOutput.String())
+	}
+	return code, output.String()
+}
+
+func TestVerify(t *testing.T) {
+	code, output := runVerifyTest(t, `name = "api"
+level = "info"
+
+[server]
+host = "example.com"
+port = 8080
+
+[[users]]
+id = 1
+`)
+	if code != 0 || output != "" {
+		t.Errorf("expected a valid document, got %d:\n%s", code, output)
+	}
+
+	code, output = runVerifyTest(t, `level = "trace"
+color = true
+
+[server]
+host = "Example.com"
+port = 80000
+
+[[users]]
+id = 1
+
+[[users]]
+name = "bob"
+
+[[users]]
+id = 3
+`)
+	expected := `-:1:1: (root): missing required key name
+-:1:1: level: trace is not one of [debug info]
+-:2:1: (root): unexpected key color
+-:5:1: server.host: "Example.com" does not match ^[a-z.]+$
+-:6:1: server.port: 80000 is greater than 65535
+-:8:1: users: expected at most 2 items, not 3
+-:11:1: users.1: missing required key id
+`
+	if code != 1 || output != expected {
+		t.Errorf("unexpected result %d:\n%s\nexpected:\n%s", code, output, expected)
+	}
+}
@@ -0,0 +1,74 @@
+// Normalization of comment text.
+
+package toml
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// CommentText returns the text of a comment, as written in a document or
+// given to SetComment, without the leading # of each line and without the
+// whitespace around each line. Lines are separated by new lines.
+func CommentText(comment string) string {
+	lines := strings.Split(strings.Replace(comment, "\r\n", "\n", -1), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "#")
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// CommentParagraphs returns the paragraphs of a comment: the text of
+// consecutive non-empty lines joined with spaces. Empty lines separate
+// paragraphs.
+func CommentParagraphs(comment string) []string {
+	var paragraphs []string
+	var current []string
+	for _, line := range strings.Split(CommentText(comment), "\n") {
+		if line == "" {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, strings.Join(current, " "))
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, strings.Join(current, " "))
+	}
+	return paragraphs
+}
+
+// WrapComment rewraps the paragraphs of a comment so that lines are at most
+// width characters long, not counting the "# " written before each line.
+// Words longer than width are kept on a line of their own. Paragraphs are
+// separated by an empty line.
+//
+// The result is meant for SetComment and the comment tag, where lines after
+// the first start with a space because the writer only adds a # to them.
+func WrapComment(comment string, width int) string {
+	var b strings.Builder
+	for i, paragraph := range CommentParagraphs(comment) {
+		if i > 0 {
+			b.WriteString("\n\n ")
+		}
+		lineLength := 0
+		for _, word := range strings.Fields(paragraph) {
+			wordLength := utf8.RuneCountInString(word)
+			if lineLength > 0 && lineLength+1+wordLength > width {
+				b.WriteString("\n ")
+				lineLength = 0
+			}
+			if lineLength > 0 {
+				b.WriteString(" ")
+				lineLength++
+			}
+			b.WriteString(word)
+			lineLength += wordLength
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package toml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommentText(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"# a comment  ", "a comment"},
+		{"plain", "plain"},
+		{"## title\r\n#   indented\n#\n# last\n", "title\nindented\n\nlast"},
+		{"\n# surrounded\n\n", "surrounded"},
+	}
+	for _, test := range tests {
+		if got := CommentText(test.in); got != test.out {
+			t.Errorf("CommentText(%q) = %q, expected %q", test.in, got, test.out)
+		}
+	}
+}
+
+func TestCommentParagraphs(t *testing.T) {
+	got := CommentParagraphs("# The address\n# to listen on.\n#\n#\n# Defaults to localhost.")
+	expected := []string{"The address to listen on.", "Defaults to localhost."}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	if got := CommentParagraphs("#\n#"); got != nil {
+		t.Errorf("expected no paragraphs, got %q", got)
+	}
+}
+
+func TestWrapComment(t *testing.T) {
+	comment := "# The address to listen on,\n# as host:port.\n#\n# See https://example.com/a-very-long-documentation-link for details."
+	expected := "The address to\n listen on, as\n host:port.\n\n See\n https://example.com/a-very-long-documentation-link\n for details."
+	if got := WrapComment(comment, 15); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	tree := newTree()
+	tree.SetWithComment("listen", WrapComment(comment, 30), false, "localhost:80")
+	out, err := tree.ToTomlString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedToml := `
+# The address to listen on, as
+# host:port.
+#
+# See
+# https://example.com/a-very-long-documentation-link
+# for details.
+listen = "localhost:80"
+`
+	if out != expectedToml {
+		t.Errorf("got:\n%s\nexpected:\n%s", out, expectedToml)
+	}
+	written := out[:strings.Index(out, "listen =")]
+	if CommentText(written) != CommentText(WrapComment(comment, 30)) {
+		t.Errorf("written comment should have the same text, got %q", CommentText(written))
+	}
+}
@@ -0,0 +1,18 @@
+#! /bin/sh
+set -eu
+
+# Check that the files of a corpus of TOML documents survive a round trip.
+#
+# The corpus is the valid documents of toml-test, which stand in for
+# real-world files: they cover the whole syntax, but not the layouts of
+# configuration files written by hand. Real-world files, such as Cargo.toml,
+# pyproject.toml or Hugo configurations, can be copied to the corpus
+# directory before running; they are checked as well.
+
+if [ ! -e corpus/toml-test ]; then
+    mkdir -p corpus
+    git clone --depth 1 https://github.com/toml-lang/toml-test corpus/toml-test
+    rm -fr corpus/toml-test/tests/invalid
+fi
+
+TOML_CORPUS="$(pwd)/corpus" go test -tags corpus -run TestCorpus -v .
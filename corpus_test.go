@@ -0,0 +1,68 @@
+// +build corpus
+
+// Round-trip checks against a corpus of real-world TOML files. Run with:
+//
+//   TOML_CORPUS=/path/to/files go test -tags corpus -run TestCorpus
+//
+// or use corpus.sh to fetch a corpus first.
+
+package toml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCorpusRoundTrip(t *testing.T) {
+	root := os.Getenv("TOML_CORPUS")
+	if root == "" {
+		t.Skip("TOML_CORPUS is not set")
+	}
+
+	var files, invalid, identical int
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".toml") {
+			return err
+		}
+		files++
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tree, err := LoadBytes(b)
+		if err != nil {
+			invalid++
+			return nil
+		}
+		rendered, err := tree.ToTomlString()
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			return nil
+		}
+		if rendered == string(b) {
+			identical++
+		}
+		reloaded, err := Load(rendered)
+		if err != nil {
+			t.Errorf("%s: rendered document does not parse: %s\n%s", path, err, rendered)
+			return nil
+		}
+		if !reflect.DeepEqual(tree.ToMap(), reloaded.ToMap()) {
+			t.Errorf("%s: content changed after round trip:\n%s", path, rendered)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := files - invalid
+	t.Logf("%d files, %d rejected by the parser", files, invalid)
+	if valid > 0 {
+		t.Logf("%d/%d (%.1f%%) rendered byte-identical", identical, valid, 100*float64(identical)/float64(valid))
+	}
+}
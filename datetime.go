@@ -0,0 +1,72 @@
+// Date-times kept with their literal.
+
+package toml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var dateTimeLiteralType = reflect.TypeOf(DateTimeLiteral{})
+
+// DateTimeLiteral holds a decoded date-time along with its TOML literal.
+//
+// TOML allows more fractional second digits than time.Time and LocalTime can
+// hold, in which case the extra digits are truncated in Value. Decoding into a
+// DateTimeLiteral keeps the original literal in Literal, and encoding writes
+// Literal back as is, so that the original precision survives a round trip.
+// Literal is empty when Value is written as in the document. Unlike
+// LocalDateTime and LocalTime, time.Time values are written without their
+// fractional seconds, except by a DateTimeLiteral.
+type DateTimeLiteral struct {
+	Value   interface{} // time.Time, LocalDateTime or LocalTime
+	Literal string
+}
+
+// MarshalTOML writes Literal if set, or the TOML representation of Value,
+// with the fractional seconds of a time.Time.
+func (d DateTimeLiteral) MarshalTOML() ([]byte, error) {
+	if d.Literal != "" {
+		return []byte(d.Literal), nil
+	}
+	if !isDateTimeValue(d.Value) {
+		return nil, fmt.Errorf("DateTimeLiteral cannot hold %T", d.Value)
+	}
+	if t, ok := d.Value.(time.Time); ok {
+		return []byte(t.Format(time.RFC3339Nano)), nil
+	}
+	s, err := tomlValueStringRepresentation(d.Value, "", "", OrderAlphabetical, false, "\n")
+	return []byte(s), err
+}
+
+// UnmarshalTOML stores a decoded date-time.
+func (d *DateTimeLiteral) UnmarshalTOML(v interface{}) error {
+	switch value := v.(type) {
+	case DateTimeLiteral:
+		*d = value
+	case time.Time, LocalDateTime, LocalTime:
+		*d = DateTimeLiteral{Value: value}
+	case nil:
+		return errors.New("DateTimeLiteral cannot be nil")
+	default:
+		return fmt.Errorf("DateTimeLiteral cannot hold %T", v)
+	}
+	return nil
+}
+
+// withLiteral returns the value at key in tval, wrapped in a DateTimeLiteral
+// when it is the type of destination.
+func withLiteral(mtype reflect.Type, tval *Tree, key string, val interface{}) interface{} {
+	for mtype.Kind() == reflect.Ptr {
+		mtype = mtype.Elem()
+	}
+	if mtype != dateTimeLiteralType {
+		return val
+	}
+	if tv, ok := tval.values[key].(*tomlValue); ok && isDateTimeValue(tv.value) {
+		return DateTimeLiteral{Value: tv.value, Literal: tv.raw}
+	}
+	return val
+}
@@ -0,0 +1,106 @@
+package toml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDateTimeRoundTripPrecision(t *testing.T) {
+	for _, input := range []string{
+		"a = 1979-05-27T00:32:00.123456789123Z\n",
+		"a = 1979-05-27T00:32:00.123456789123-07:00\n",
+		"a = 1979-05-27T00:32:00.1234567891\n",
+		"a = 00:32:00.1234567891\n",
+		"a = 1979-05-27T00:32:00.999999-07:00\n",
+	} {
+		tree, err := Load(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tree.ToTomlString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output != input {
+			t.Errorf("Bad round trip.\nExpected: %q\nGot:      %q", input, output)
+		}
+	}
+}
+
+func TestDateTimeRawLiteralDroppedOnChange(t *testing.T) {
+	tree, err := Load("a = 00:32:00.1234567891")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Values()["a"].(*PubTOMLValue).SetValue(LocalTime{Hour: 1})
+	if s := tree.String(); s != "a = 01:00:00\n" {
+		t.Errorf("changed value should be encoded, got %q", s)
+	}
+}
+
+func TestDateTimeLiteralUnmarshal(t *testing.T) {
+	type doc struct {
+		Precise DateTimeLiteral
+		Regular DateTimeLiteral
+		Pointer *DateTimeLiteral
+		Map     map[string]DateTimeLiteral
+	}
+	input := `Pointer = 07:32:00.0000000001
+Precise = 1979-05-27T00:32:00.123456789123Z
+Regular = 1979-05-27T07:32:00
+
+[Map]
+  a = 1979-05-27T00:32:00.1234567891
+`
+	var d doc
+	if err := Unmarshal([]byte(input), &d); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedTime := time.Date(1979, 5, 27, 0, 32, 0, 123456789, time.UTC)
+	if !reflect.DeepEqual(d.Precise, DateTimeLiteral{Value: expectedTime, Literal: "1979-05-27T00:32:00.123456789123Z"}) {
+		t.Errorf("unexpected Precise: %#v", d.Precise)
+	}
+	if d.Regular.Literal != "" || d.Regular.Value != (LocalDateTime{LocalDate{1979, 5, 27}, LocalTime{7, 32, 0, 0}}) {
+		t.Errorf("unexpected Regular: %#v", d.Regular)
+	}
+	if d.Pointer == nil || d.Pointer.Literal != "07:32:00.0000000001" {
+		t.Errorf("unexpected Pointer: %#v", d.Pointer)
+	}
+	if d.Map["a"].Literal != "1979-05-27T00:32:00.1234567891" {
+		t.Errorf("unexpected Map: %#v", d.Map)
+	}
+
+	output, err := Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != input {
+		t.Errorf("Bad round trip.\nExpected:\n%s\nGot:\n%s", input, output)
+	}
+}
+
+func TestDateTimeLiteralUnmarshalError(t *testing.T) {
+	var d struct{ A DateTimeLiteral }
+	err := Unmarshal([]byte(`A = "1979-05-27"`), &d)
+	if err == nil || !strings.Contains(err.Error(), "DateTimeLiteral cannot hold string") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDateTimeLiteralMarshalFraction(t *testing.T) {
+	ts := time.Date(1979, 5, 27, 0, 32, 0, 500000000, time.UTC)
+	output, err := Marshal(struct {
+		Time    time.Time
+		Literal DateTimeLiteral
+	}{ts, DateTimeLiteral{Value: ts}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Literal = 1979-05-27T00:32:00.5Z\nTime = 1979-05-27T00:32:00Z\n"
+	if string(output) != expected {
+		t.Errorf("Bad marshal.\nExpected:\n%s\nGot:\n%s", expected, output)
+	}
+}
@@ -0,0 +1,96 @@
+// Warnings about deprecated keys.
+
+package toml
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DeprecatedKey is a deprecated key found in a document by Decode.
+type DeprecatedKey struct {
+	// Key in the document, elements of arrays of tables being designated by
+	// their index (e.g. servers.0.addr).
+	Key         string
+	Position    Position
+	Replacement string // key to use instead, if any
+}
+
+func (k DeprecatedKey) String() string {
+	if k.Replacement == "" {
+		return fmt.Sprintf("%s: key %s is deprecated", k.Position, k.Key)
+	}
+	return fmt.Sprintf("%s: key %s is deprecated, use %s instead", k.Position, k.Key, k.Replacement)
+}
+
+// Deprecate declares key deprecated, with the key to use instead, if any.
+// Keys are dot-separated paths without indexes of arrays of tables, such as
+// servers.addr for the addr key of all the [[servers]] tables. Deprecated
+// tables can be declared too. Deprecated keys are decoded as usual, and
+// reported by DeprecatedKeys after Decode, to help users migrate their
+// documents:
+//
+//   d := toml.NewDecoder(r).Deprecate("server.addr", "server.host")
+//   err := d.Decode(&config)
+//   for _, k := range d.DeprecatedKeys() {
+//       log.Printf("warning: %s", k)
+//   }
+func (d *Decoder) Deprecate(key, replacement string) *Decoder {
+	if d.deprecated == nil {
+		d.deprecated = make(map[string]string)
+	}
+	d.deprecated[key] = replacement
+	return d
+}
+
+// DeprecatedKeys returns the deprecated keys of the document read by the last
+// call to Decode, in document order.
+func (d *Decoder) DeprecatedKeys() []DeprecatedKey {
+	return d.deprecatedKeys
+}
+
+// findDeprecatedKeys returns the keys of t declared deprecated.
+func findDeprecatedKeys(t *Tree, deprecated map[string]string) []DeprecatedKey {
+	var found []DeprecatedKey
+	var walk func(key, pattern []string, t *Tree)
+	walk = func(key, pattern []string, t *Tree) {
+		for k, v := range t.values {
+			key := append(key[:len(key):len(key)], k)
+			pattern := append(pattern[:len(pattern):len(pattern)], k)
+			report := func(pos Position) {
+				if replacement, ok := deprecated[strings.Join(pattern, ".")]; ok {
+					found = append(found, DeprecatedKey{strings.Join(key, "."), pos, replacement})
+				}
+			}
+			switch node := v.(type) {
+			case *tomlValue:
+				report(node.position)
+			case *Tree:
+				report(node.position)
+				walk(key, pattern, node)
+			case []*Tree:
+				if len(node) > 0 {
+					report(node[0].position)
+				}
+				for i, item := range node {
+					walk(append(key, strconv.Itoa(i)), pattern, item)
+				}
+			}
+		}
+	}
+	walk(nil, nil, t)
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i].Position, found[j].Position
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Col != b.Col {
+			return a.Col < b.Col
+		}
+		return found[i].Key < found[j].Key
+	})
+	return found
+}
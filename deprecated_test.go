@@ -0,0 +1,65 @@
+package toml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoderDeprecate(t *testing.T) {
+	input := `
+name = "api"
+
+[server]
+addr = "localhost"
+
+[[clients]]
+timeout = 1
+
+[[clients]]
+timeout = 2
+
+[legacy]
+enabled = true
+`
+	var config struct {
+		Name   string
+		Server struct {
+			Addr string
+		}
+		Clients []struct {
+			Timeout int
+		}
+	}
+	d := NewDecoder(strings.NewReader(input)).
+		Deprecate("server.addr", "server.host").
+		Deprecate("clients.timeout", "").
+		Deprecate("legacy", "").
+		Deprecate("missing", "other")
+	if err := d.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Server.Addr != "localhost" {
+		t.Errorf("deprecated keys must be decoded, got %q", config.Server.Addr)
+	}
+
+	expected := []DeprecatedKey{
+		{"server.addr", Position{5, 1}, "server.host"},
+		{"clients.0.timeout", Position{8, 1}, ""},
+		{"clients.1.timeout", Position{11, 1}, ""},
+		{"legacy", Position{13, 1}, ""},
+	}
+	if !reflect.DeepEqual(d.DeprecatedKeys(), expected) {
+		t.Errorf("expected %v, got %v", expected, d.DeprecatedKeys())
+	}
+	if s := expected[0].String(); s != "(5, 1): key server.addr is deprecated, use server.host instead" {
+		t.Errorf("unexpected message: %s", s)
+	}
+	if s := expected[1].String(); s != "(8, 1): key clients.0.timeout is deprecated" {
+		t.Errorf("unexpected message: %s", s)
+	}
+
+	if keys := NewDecoder(strings.NewReader(input)).DeprecatedKeys(); keys != nil {
+		t.Errorf("expected no deprecated keys before Decode, got %v", keys)
+	}
+}
@@ -0,0 +1,151 @@
+// Differences from default values.
+
+package toml
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change describes a key whose value differs from its default.
+type Change struct {
+	Path    []string    // path of the key
+	Default interface{} // default value, or nil if the key has no default
+	Value   interface{} // current value, or nil if the key is not set
+}
+
+// Key returns the path of the change as a dot-separated TOML key.
+func (c Change) Key() string {
+	keys := make([]string, len(c.Path))
+	for i, k := range c.Path {
+		keys[i] = quoteKeyIfNeeded(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+// DiffFromDefaults compares current to defaults, two values of the same
+// struct or map type, and returns the keys that were customized. Both values
+// are converted as Marshal would, and changes are sorted by key. Values are
+// represented with the types documented for Tree.ToMap. Arrays are compared
+// as a whole.
+//
+// Use Encoder.OmitDefaults to write only the customized keys.
+func DiffFromDefaults(current interface{}, defaults interface{}) ([]Change, error) {
+	e := NewEncoder(nil)
+	cur, err := e.marshalableTree(current)
+	if err != nil {
+		return nil, err
+	}
+	def, err := e.marshalableTree(defaults)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	diffTrees(nil, cur, def, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key() < changes[j].Key()
+	})
+	return changes, nil
+}
+
+// OmitDefaults sets up the encoder to only write the keys whose value differs
+// from the ones of defaults, which must be of the same type as the encoded
+// values. This is useful to show the settings a user has overridden.
+func (e *Encoder) OmitDefaults(defaults interface{}) *Encoder {
+	e.defaults = defaults
+	return e
+}
+
+// Convert a value accepted by Marshal to a tree.
+func (e *Encoder) marshalableTree(v interface{}) (*Tree, error) {
+	mtype := reflect.TypeOf(v)
+	if mtype == nil {
+		return nil, errors.New("nil cannot be marshaled to TOML")
+	}
+	mval := reflect.ValueOf(v)
+	switch mtype.Kind() {
+	case reflect.Struct, reflect.Map:
+	case reflect.Ptr:
+		if mtype.Elem().Kind() != reflect.Struct {
+			return nil, errors.New("Only pointer to struct can be marshaled to TOML")
+		}
+		if mval.IsNil() {
+			return nil, errors.New("nil pointer cannot be marshaled to TOML")
+		}
+	default:
+		return nil, errors.New("Only a struct or map can be marshaled to TOML")
+	}
+	return e.valueToTree(mtype, mval)
+}
+
+func diffTrees(path []string, cur, def *Tree, changes *[]Change) {
+	for k, cv := range cur.values {
+		keyPath := append(append([]string{}, path...), k)
+		dv, ok := def.values[k]
+		if !ok {
+			*changes = append(*changes, Change{Path: keyPath, Value: treeValueToGo(cv)})
+			continue
+		}
+		ct, curIsTree := cv.(*Tree)
+		dt, defIsTree := dv.(*Tree)
+		if curIsTree && defIsTree {
+			diffTrees(keyPath, ct, dt, changes)
+			continue
+		}
+		if !treeValuesEqual(cv, dv) {
+			*changes = append(*changes, Change{Path: keyPath, Default: treeValueToGo(dv), Value: treeValueToGo(cv)})
+		}
+	}
+	for k, dv := range def.values {
+		if _, ok := cur.values[k]; !ok {
+			keyPath := append(append([]string{}, path...), k)
+			*changes = append(*changes, Change{Path: keyPath, Default: treeValueToGo(dv)})
+		}
+	}
+}
+
+// pruneDefaults removes from cur the values equal to the ones of def, as
+// well as the tables left empty.
+func pruneDefaults(cur, def *Tree) {
+	for k, cv := range cur.values {
+		dv, ok := def.values[k]
+		if !ok {
+			continue
+		}
+		ct, curIsTree := cv.(*Tree)
+		dt, defIsTree := dv.(*Tree)
+		if curIsTree && defIsTree {
+			pruneDefaults(ct, dt)
+			if len(ct.values) == 0 {
+				delete(cur.values, k)
+			}
+			continue
+		}
+		if treeValuesEqual(cv, dv) {
+			delete(cur.values, k)
+		}
+	}
+}
+
+func treeValuesEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(treeValueToGo(a), treeValueToGo(b))
+}
+
+func treeValueToGo(v interface{}) interface{} {
+	switch node := v.(type) {
+	case *Tree:
+		return node.ToMap()
+	case []*Tree:
+		array := make([]interface{}, len(node))
+		for i, item := range node {
+			array[i] = item.ToMap()
+		}
+		return array
+	case *tomlValue:
+		return tomlValueToGo(node.value)
+	default:
+		return tomlValueToGo(v)
+	}
+}
@@ -0,0 +1,115 @@
+package toml
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type diffServer struct {
+	Host  string
+	Port  int
+	Debug bool `toml:"debug-mode"`
+}
+
+type diffConfig struct {
+	Name    string
+	Tags    []string
+	Server  diffServer
+	Plugins []diffServer
+	Extra   map[string]string
+}
+
+func diffDefaults() diffConfig {
+	return diffConfig{
+		Name:   "app",
+		Tags:   []string{"a"},
+		Server: diffServer{Host: "localhost", Port: 80},
+		Extra:  map[string]string{"x": "1"},
+	}
+}
+
+func TestDiffFromDefaults(t *testing.T) {
+	current := diffDefaults()
+	current.Server.Port = 8080
+	current.Server.Debug = true
+	current.Tags = []string{"a", "b"}
+	current.Extra = map[string]string{"y": "2"}
+
+	changes, err := DiffFromDefaults(current, diffDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change{
+		{Path: []string{"Extra", "x"}, Default: "1"},
+		{Path: []string{"Extra", "y"}, Value: "2"},
+		{Path: []string{"Server", "Port"}, Default: int64(80), Value: int64(8080)},
+		{Path: []string{"Server", "debug-mode"}, Default: false, Value: true},
+		{Path: []string{"Tags"}, Default: []interface{}{"a"}, Value: []interface{}{"a", "b"}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes:\n%#v\nexpected:\n%#v", changes, expected)
+	}
+
+	changes, err = DiffFromDefaults(&current, &current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+
+	if _, err := DiffFromDefaults(current, nil); err == nil {
+		t.Error("expected an error for nil defaults")
+	}
+}
+
+func TestChangeKey(t *testing.T) {
+	c := Change{Path: []string{"a", "b.c", "d"}}
+	if c.Key() != `a."b.c".d` {
+		t.Errorf("unexpected key: %s", c.Key())
+	}
+}
+
+func TestEncoderOmitDefaults(t *testing.T) {
+	current := diffDefaults()
+	current.Server.Port = 8080
+	current.Plugins = []diffServer{{Host: "p"}}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).OmitDefaults(diffDefaults()).Encode(current); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+[[Plugins]]
+  Host = "p"
+  Port = 0
+  debug-mode = false
+
+[Server]
+  Port = 8080
+`
+	if buf.String() != expected {
+		t.Errorf("Bad output.\nExpected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := NewEncoder(&buf).OmitDefaults(diffDefaults()).Encode(diffDefaults()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected empty output, got:\n%s", buf.String())
+	}
+
+	tree, err := TreeFromMap(map[string]interface{}{"Name": "app", "Server": map[string]interface{}{"Host": "localhost", "Port": 8080}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := tree.String()
+	if err := NewEncoder(&bytes.Buffer{}).OmitDefaults(diffDefaults()).Encode(tree); err != nil {
+		t.Fatal(err)
+	}
+	if after := tree.String(); after != before {
+		t.Errorf("the encoded tree should be unchanged:\n%s\nexpected:\n%s", after, before)
+	}
+}
@@ -0,0 +1,77 @@
+// Decoding of streams of several documents.
+
+package toml
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Delimiter makes the decoder read the input as a stream of documents
+// separated by lines made of delim, such as "---", instead of as a single
+// document. Each call to Decode decodes the next document, while More reports
+// whether there is one:
+//
+//   d := toml.NewDecoder(r).Delimiter("---")
+//   for d.More() {
+//       var event Event
+//       if err := d.Decode(&event); err != nil {
+//           return err
+//       }
+//   }
+//
+// Positions are relative to the beginning of each document, and limits apply
+// to each document.
+func (d *Decoder) Delimiter(delim string) *Decoder {
+	d.delimiter = delim
+	return d
+}
+
+// More reports whether the input has data left to decode, such as another
+// document of a stream separated with Delimiter.
+func (d *Decoder) More() bool {
+	_, err := d.reader().Peek(1)
+	return err == nil
+}
+
+// reader returns the buffered input of the decoder.
+func (d *Decoder) reader() *bufio.Reader {
+	if d.input == nil {
+		d.input = bufio.NewReader(d.r)
+	}
+	return d.input
+}
+
+// readDocument reads the input up to the next delimiter line, which is
+// consumed but not returned, or to the end of the input. Lines are read by
+// pieces of the size of the buffer at most, so that max also bounds what is
+// read of a line without end.
+func (d *Decoder) readDocument(max int64) ([]byte, error) {
+	r := d.reader()
+	var doc, line []byte
+	for {
+		piece, err := r.ReadSlice('\n')
+		line = append(line, piece...)
+		if err == bufio.ErrBufferFull {
+			if max > 0 && int64(len(doc)+len(line)) > max {
+				return nil, &LimitError{Limit: LimitSize, Max: max}
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if string(bytes.TrimRight(line, " \t\r\n")) == d.delimiter {
+			return doc, nil
+		}
+		doc = append(doc, line...)
+		line = line[:0]
+		if max > 0 && int64(len(doc)) > max {
+			return nil, &LimitError{Limit: LimitSize, Max: max}
+		}
+		if err == io.EOF {
+			return doc, nil
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package toml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecoderDelimiter(t *testing.T) {
+	input := `id = 1
+name = "start"
+---
+id = 2
+---   
+id = 3
+[data]
+x = 1.5
+`
+	type event struct {
+		ID   int
+		Name string
+		Data map[string]float64
+	}
+	d := NewDecoder(strings.NewReader(input)).Delimiter("---")
+	var events []event
+	for d.More() {
+		var e event
+		if err := d.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 3 || events[0].Name != "start" || events[1].ID != 2 || events[2].Data["x"] != 1.5 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	d = NewDecoder(strings.NewReader("a = 1\n---\na = yes\n---\na = 3")).Delimiter("---")
+	var v map[string]int
+	if err := d.Decode(&v); err != nil || v["a"] != 1 {
+		t.Errorf("unexpected first document: %v, %v", v, err)
+	}
+	if err := d.Decode(&v); err == nil || !strings.HasPrefix(err.Error(), "(1, 5):") {
+		t.Errorf("expected an error on the first line of the second document, got %v", err)
+	}
+	if err := d.Decode(&v); err != nil || v["a"] != 3 || d.More() {
+		t.Errorf("unexpected last document: %v, %v", v, err)
+	}
+
+	d = NewDecoder(strings.NewReader("a = 1\n---\na = 123456789\n")).Delimiter("---").MaxDocumentSize(10)
+	if err := d.Decode(&v); err != nil {
+		t.Errorf("the first document is within the limit: %v", err)
+	}
+	if _, ok := d.Decode(&v).(*LimitError); !ok {
+		t.Errorf("expected a LimitError for the second document")
+	}
+
+	d = NewDecoder(endlessLine{}).Delimiter("---").MaxDocumentSize(1 << 16)
+	if _, ok := d.Decode(&v).(*LimitError); !ok {
+		t.Errorf("expected a LimitError for a line without end")
+	}
+}
+
+// endlessLine is an input made of a single line that never ends.
+type endlessLine struct{}
+
+func (endlessLine) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	return len(p), nil
+}
+
+func TestDecoderMore(t *testing.T) {
+	d := NewDecoder(strings.NewReader("a = 1"))
+	if !d.More() {
+		t.Errorf("expected a document before Decode")
+	}
+	var v map[string]int
+	if err := d.Decode(&v); err != nil || v["a"] != 1 {
+		t.Errorf("unexpected document: %v, %v", v, err)
+	}
+	if d.More() {
+		t.Errorf("expected no more documents after Decode")
+	}
+	if NewDecoder(strings.NewReader("")).More() {
+		t.Errorf("expected no document in an empty input")
+	}
+}
@@ -0,0 +1,81 @@
+package toml
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{"USER": "admin", "DB_PASS": "s3cret", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	tests := []struct {
+		in, out string
+	}{
+		{"no variables", "no variables"},
+		{"${USER}:${DB_PASS}@host", "admin:s3cret@host"},
+		{"$USER-$DB_PASS.", "admin-s3cret."},
+		{"[$EMPTY]", "[]"},
+		{"$$5 and $$USER", "$5 and $USER"},
+		{"trailing $", "trailing $"},
+		{"$ 1 and $-", "$ 1 and $-"},
+	}
+	for _, test := range tests {
+		got, err := expandEnv(test.in, lookup)
+		if err != nil || got != test.out {
+			t.Errorf("expandEnv(%q) = %q, %v, expected %q", test.in, got, err, test.out)
+		}
+	}
+
+	for _, in := range []string{"$UNDEFINED", "${UNDEFINED}", "${USER", "${1X}", "${}"} {
+		if got, err := expandEnv(in, lookup); err == nil {
+			t.Errorf("expandEnv(%q) should fail, got %q", in, got)
+		}
+	}
+}
+
+func TestDecoderExpandEnv(t *testing.T) {
+	os.Setenv("GO_TOML_TEST_HOST", "db.example.com")
+	defer os.Unsetenv("GO_TOML_TEST_HOST")
+
+	type config struct {
+		DSN     string
+		Mirrors []string
+		Extra   map[string]interface{}
+	}
+	doc := `
+dsn = "postgres://${GO_TOML_TEST_HOST}/app"
+mirrors = ["$GO_TOML_TEST_HOST", "backup"]
+[extra]
+host = "$GO_TOML_TEST_HOST"
+`
+	var c config
+	if err := NewDecoder(strings.NewReader(doc)).ExpandEnv(nil).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.DSN != "postgres://db.example.com/app" {
+		t.Errorf("bad dsn: %s", c.DSN)
+	}
+	if len(c.Mirrors) != 2 || c.Mirrors[0] != "db.example.com" {
+		t.Errorf("bad mirrors: %v", c.Mirrors)
+	}
+	if c.Extra["host"] != "db.example.com" {
+		t.Errorf("bad extra: %v", c.Extra)
+	}
+
+	var plain config
+	if err := NewDecoder(strings.NewReader(doc)).Decode(&plain); err != nil {
+		t.Fatal(err)
+	}
+	if plain.DSN != "postgres://${GO_TOML_TEST_HOST}/app" {
+		t.Errorf("variables should only be expanded on demand, got %s", plain.DSN)
+	}
+
+	err := NewDecoder(strings.NewReader(`dsn = "${GO_TOML_TEST_UNDEFINED}"`)).ExpandEnv(nil).Decode(&c)
+	if err == nil || !strings.Contains(err.Error(), "undefined environment variable GO_TOML_TEST_UNDEFINED") {
+		t.Errorf("expected an undefined variable error, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Escaping of basic strings.
+
+package toml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EscapeBasicString returns s as the content of a TOML basic string, without
+// the surrounding quotes. Quotation marks, backslashes and control
+// characters are escaped, with the short forms \b, \t, \n, \f and \r when
+// they exist and \uXXXX otherwise.
+func EscapeBasicString(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			writeEscapedControl(&b, r)
+		}
+	}
+	return b.String()
+}
+
+// EscapeMultilineBasicString returns s as the content of a TOML multi-line
+// basic string, without the surrounding quotes. Unlike EscapeBasicString, tabs
+// and new lines are kept as is, and quotation marks are only escaped where
+// they would end the string: in a sequence of three or at the end of s.
+func EscapeMultilineBasicString(s string) string {
+	var b bytes.Buffer
+	quotes := 0
+	for i, r := range s {
+		if r == '"' {
+			quotes++
+		} else {
+			quotes = 0
+		}
+		switch r {
+		case '"':
+			if quotes == 3 || i == len(s)-1 {
+				quotes = 0
+				b.WriteString(`\"`)
+			} else {
+				b.WriteRune(r)
+			}
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t', '\n':
+			b.WriteRune(r)
+		case '\r':
+			// only allowed in a CRLF new line
+			if strings.HasPrefix(s[i+1:], "\n") {
+				b.WriteRune(r)
+			} else {
+				b.WriteString(`\r`)
+			}
+		default:
+			writeEscapedControl(&b, r)
+		}
+	}
+	return b.String()
+}
+
+// writeEscapedControl writes r, escaped if it is a control character.
+func writeEscapedControl(b *bytes.Buffer, r rune) {
+	switch r {
+	case '\b':
+		b.WriteString(`\b`)
+	case '\t':
+		b.WriteString(`\t`)
+	case '\n':
+		b.WriteString(`\n`)
+	case '\f':
+		b.WriteString(`\f`)
+	case '\r':
+		b.WriteString(`\r`)
+	default:
+		if r <= 0x1F || r == 0x7F {
+			fmt.Fprintf(b, "\\u%04X", r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+}
+
+// UnescapeBasicString returns the value of the content of a TOML basic
+// string, given without the surrounding quotes. It returns an error for
+// invalid escape sequences, unescaped quotation marks and control
+// characters.
+func UnescapeBasicString(s string) (string, error) {
+	l := &tomlLexer{input: []rune(s + `"`)}
+	value, err := l.lexStringAsString(`"`, false, false)
+	if err != nil {
+		return "", err
+	}
+	if l.inputIdx != len(l.input)-1 {
+		return "", errors.New("unescaped quotation mark")
+	}
+	return value, nil
+}
+
+// UnescapeMultilineBasicString returns the value of the content of a TOML
+// multi-line basic string, given without the surrounding quotes. A new line
+// right after the opening quotes is not part of the content and must be
+// removed beforehand. Backslashes at the end of a line are removed with the
+// following whitespace and new lines.
+func UnescapeMultilineBasicString(s string) (string, error) {
+	quotes := 0
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+			quotes = 0
+		case r == '\\':
+			escaped = true
+			quotes = 0
+		case r == '"':
+			quotes++
+			if quotes == 3 {
+				return "", errors.New("unescaped sequence of three quotation marks")
+			}
+		default:
+			quotes = 0
+		}
+	}
+
+	// NUL is rejected when unescaped, so it cannot end the content early
+	l := &tomlLexer{input: []rune(s + "\x00")}
+	value, err := l.lexStringAsString("\x00", false, true)
+	if err != nil {
+		return "", err
+	}
+	if l.inputIdx != len(l.input)-1 {
+		return "", fmt.Errorf("unescaped control character %U", 0)
+	}
+	return value, nil
+}
@@ -0,0 +1,71 @@
+package toml
+
+import (
+	"testing"
+)
+
+func TestEscapeBasicString(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"plain", "plain"},
+		{"quote\" and \\", `quote\" and \\`},
+		{"\b\t\n\f\r", `\b\t\n\f\r`},
+		{"\x00\x1f\x7f", `\u0000\u001F\u007F`},
+		{"é\U0001001F", "é\U0001001F"},
+	}
+	for _, test := range tests {
+		if got := EscapeBasicString(test.in); got != test.out {
+			t.Errorf("EscapeBasicString(%q) = %q, expected %q", test.in, got, test.out)
+		}
+		back, err := UnescapeBasicString(test.out)
+		if err != nil || back != test.in {
+			t.Errorf("UnescapeBasicString(%q) = %q, %v, expected %q", test.out, back, err, test.in)
+		}
+	}
+}
+
+func TestEscapeMultilineBasicString(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"two\nlines\ttab", "two\nlines\ttab"},
+		{"crlf\r\nand cr\r", "crlf\r\nand cr\\r"},
+		{`a "" b`, `a "" b`},
+		{`a """ b`, `a ""\" b`},
+		{`ends with "`, `ends with \"`},
+		{"back\\slash\x01", `back\\slash\u0001`},
+	}
+	for _, test := range tests {
+		if got := EscapeMultilineBasicString(test.in); got != test.out {
+			t.Errorf("EscapeMultilineBasicString(%q) = %q, expected %q", test.in, got, test.out)
+		}
+		back, err := UnescapeMultilineBasicString(test.out)
+		if err != nil || back != test.in {
+			t.Errorf("UnescapeMultilineBasicString(%q) = %q, %v, expected %q", test.out, back, err, test.in)
+		}
+	}
+}
+
+func TestUnescapeBasicStringErrors(t *testing.T) {
+	for _, in := range []string{`a"b`, `\x`, `\u12`, "a\nb", `a\ b`, "a\x00b"} {
+		if got, err := UnescapeBasicString(in); err == nil {
+			t.Errorf("UnescapeBasicString(%q) should fail, got %q", in, got)
+		}
+	}
+	for _, in := range []string{`a"""b`, `\q`, "a\x00b", "a\x01b"} {
+		if got, err := UnescapeMultilineBasicString(in); err == nil {
+			t.Errorf("UnescapeMultilineBasicString(%q) should fail, got %q", in, got)
+		}
+	}
+}
+
+func TestUnescapeMultilineBasicStringLineEndingBackslash(t *testing.T) {
+	got, err := UnescapeMultilineBasicString("The quick \\\n   brown \\\r\n\n  fox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "The quick brown fox" {
+		t.Errorf("got %q", got)
+	}
+}
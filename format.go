@@ -0,0 +1,78 @@
+// Formatting styles of tables.
+
+package toml
+
+import (
+	"strings"
+)
+
+// TableFormat is a set of styles of a table that the writer keeps whatever
+// the settings of the Encoder, so that documents mixing styles are written
+// back the same way.
+type TableFormat int
+
+// Styles of tables, to combine with |.
+const (
+	FormatInline  TableFormat = 1 << iota // written as an inline table: key = { a = 1 }
+	FormatAligned                         // equal signs of the keys aligned
+	FormatSorted                          // keys sorted alphabetically, even with OrderPreserve
+)
+
+var formatNames = map[string]TableFormat{
+	"inline":  FormatInline,
+	"aligned": FormatAligned,
+	"sorted":  FormatSorted,
+}
+
+// SetFormat sets the styles of the table. Documents can also set the styles
+// of a table with a comment right above its header, or above the key of an
+// inline table, which the writer adds to the tables with styles:
+//
+//   # toml-fmt: aligned, sorted
+//   [servers]
+//
+// Tables read inline have no style, and are written with a header unless
+// their format is FormatInline.
+func (t *Tree) SetFormat(format TableFormat) {
+	t.format = format
+}
+
+// Format returns the styles of the table.
+func (t *Tree) Format() TableFormat {
+	return t.format
+}
+
+// String returns the styles as written in a directive, such as
+// "aligned, sorted".
+func (f TableFormat) String() string {
+	var names []string
+	for _, name := range []string{"inline", "aligned", "sorted"} {
+		if f&formatNames[name] != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseFormatDirective parses a "# toml-fmt: styles" comment.
+func parseFormatDirective(comment string) (TableFormat, bool) {
+	text := strings.TrimSpace(strings.TrimPrefix(comment, "#"))
+	if !strings.HasPrefix(text, "toml-fmt:") {
+		return 0, false
+	}
+	var format TableFormat
+	for _, name := range strings.Split(strings.TrimPrefix(text, "toml-fmt:"), ",") {
+		style, ok := formatNames[strings.TrimSpace(name)]
+		if !ok {
+			return 0, false
+		}
+		format |= style
+	}
+	return format, true
+}
+
+// isInlineTable reports whether v is a table written inline.
+func isInlineTable(v interface{}) bool {
+	tree, ok := v.(*Tree)
+	return ok && tree.format&FormatInline != 0
+}
@@ -0,0 +1,159 @@
+package toml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTreeSetFormat(t *testing.T) {
+	tree, err := Load(`
+[server]
+host = "localhost"
+port = 8080
+
+[server.tls]
+cert = "a.pem"
+key = "a.key"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Get("server").(*Tree).SetFormat(FormatAligned)
+	tree.Get("server.tls").(*Tree).SetFormat(FormatInline)
+
+	got, err := tree.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# toml-fmt: aligned
+[server]
+  host = "localhost"
+  port = 8080
+  # toml-fmt: inline
+  tls  = { cert = "a.pem", key = "a.key" }
+`
+	if string(got) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+	if _, err := LoadBytes(got); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInlineTableFormat(t *testing.T) {
+	tree, err := Load(`
+[server]
+tls = { cert = "a.pem", key = "a.key" }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := tree.Get("server.tls").(*Tree).Format(); format != 0 {
+		t.Errorf("inline tables should have no format, got %q", format)
+	}
+	if got := tree.String(); !strings.Contains(got, "[server.tls]") {
+		t.Errorf("inline tables should be written as tables by default:\n%s", got)
+	}
+
+	tree, err = Load(`
+[server]
+# toml-fmt: inline
+tls = { cert = "a.pem", key = "a.key" }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := tree.Get("server.tls").(*Tree).Format(); format != FormatInline {
+		t.Errorf("the directive should set FormatInline, got %q", format)
+	}
+	first, err := tree.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadBytes(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := reloaded.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) || !bytes.Contains(second, []byte(`tls = { cert = "a.pem", key = "a.key" }`)) {
+		t.Errorf("inline table should be kept:\n%s\nthen:\n%s", first, second)
+	}
+}
+
+func TestTableFormatDirectives(t *testing.T) {
+	tree, err := Load(`
+title = "example"
+
+# toml-fmt: inline
+[owner]
+name = "Tom"
+
+# toml-fmt: aligned, sorted
+[database]
+server = "192.168.1.1"
+enabled = true
+
+[other]
+b = 2
+a = 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := tree.Get("database").(*Tree).Format(); format != FormatAligned|FormatSorted {
+		t.Errorf("expected aligned and sorted, got %s", format)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Order(OrderPreserve).Encode(tree); err != nil {
+		t.Fatal(err)
+	}
+	expected := `title = "example"
+# toml-fmt: inline
+owner = { name = "Tom" }
+
+# toml-fmt: aligned, sorted
+[database]
+  enabled = true
+  server  = "192.168.1.1"
+
+[other]
+  b = 2
+  a = 1
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	// the directives written keep the formats
+	reloaded, err := LoadBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := reloaded.Get("database").(*Tree).Format(); format != FormatAligned|FormatSorted {
+		t.Errorf("expected aligned and sorted after reloading, got %s", format)
+	}
+}
+
+func TestParseFormatDirective(t *testing.T) {
+	for comment, expected := range map[string]TableFormat{
+		"# toml-fmt: inline":          FormatInline,
+		"#toml-fmt:sorted,aligned":    FormatSorted | FormatAligned,
+		"# toml-fmt: inline, aligned": FormatInline | FormatAligned,
+	} {
+		format, ok := parseFormatDirective(comment)
+		if !ok || format != expected {
+			t.Errorf("%q: expected %d, got %d (%v)", comment, expected, format, ok)
+		}
+	}
+	for _, comment := range []string{"# a comment", "# toml-fmt: compact", "# toml-fmt:"} {
+		if _, ok := parseFormatDirective(comment); ok {
+			t.Errorf("%q: expected no directive", comment)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Extraction of TOML front matter.
+
+package toml
+
+import (
+	"bytes"
+	"errors"
+)
+
+// frontMatterDelimiter is the line around TOML front matter, as in Hugo.
+const frontMatterDelimiter = "+++"
+
+// SplitFrontMatter splits data into its TOML front matter, delimited by
+// lines of +++ at the very beginning of data, and the body that follows:
+//
+//   +++
+//   title = "Hello"
+//   +++
+//   Body of the page.
+//
+// The front matter is parsed as with LoadBytes, and the positions of its
+// values and errors are the ones in data, starting on line 2. Without front
+// matter, SplitFrontMatter returns a nil Tree and the whole data as the body.
+func SplitFrontMatter(data []byte) (*Tree, []byte, error) {
+	doc := stripBOM(data)
+	first, rest := cutLine(doc)
+	if !isFrontMatterDelimiter(first) {
+		return nil, data, nil
+	}
+	for offset := 0; offset < len(rest); {
+		line, next := cutLine(rest[offset:])
+		if isFrontMatterDelimiter(line) {
+			// the blank line stands for the opening delimiter, so that
+			// positions are the ones in data
+			tree, err := LoadBytes(append([]byte{'\n'}, rest[:offset]...))
+			if err != nil {
+				return nil, nil, err
+			}
+			return tree, next, nil
+		}
+		offset = len(rest) - len(next)
+	}
+	return nil, nil, errors.New("(1, 1): front matter is not closed by " + frontMatterDelimiter)
+}
+
+// cutLine returns the first line of b, without its line ending, and the rest
+// of b.
+func cutLine(b []byte) (line []byte, rest []byte) {
+	idx := bytes.IndexByte(b, '\n')
+	if idx < 0 {
+		return b, nil
+	}
+	return b[:idx], b[idx+1:]
+}
+
+func isFrontMatterDelimiter(line []byte) bool {
+	return string(bytes.TrimRight(line, " \t\r")) == frontMatterDelimiter
+}
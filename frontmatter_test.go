@@ -0,0 +1,42 @@
+package toml
+
+import (
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tree, body, err := SplitFrontMatter([]byte("+++\r\ntitle = \"Hello\"\r\n\r\n[params]\r\ndraft = true\r\n+++\r\nBody\n+++\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Get("title") != "Hello" || tree.Get("params.draft") != true {
+		t.Errorf("unexpected front matter: %v", tree)
+	}
+	if pos := tree.GetPosition("params.draft"); pos != (Position{5, 1}) {
+		t.Errorf("expected the position in the whole document, got %s", pos)
+	}
+	if string(body) != "Body\n+++\n" {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	tree, body, err = SplitFrontMatter([]byte("+++\n+++"))
+	if err != nil || tree == nil || len(tree.Keys()) != 0 || len(body) != 0 {
+		t.Errorf("expected empty front matter, got %v, %q, %v", tree, body, err)
+	}
+
+	data := []byte("title = \"Hello\"\n")
+	tree, body, err = SplitFrontMatter(data)
+	if err != nil || tree != nil || string(body) != string(data) {
+		t.Errorf("expected no front matter, got %v, %q, %v", tree, body, err)
+	}
+
+	_, _, err = SplitFrontMatter([]byte("+++\ntitle = \"Hello\"\ndraft = yes\n+++\n"))
+	if err == nil || err.Error() != "(3, 9): no value can start with y" {
+		t.Errorf("expected an error on line 3, got %v", err)
+	}
+
+	_, _, err = SplitFrontMatter([]byte("+++\ntitle = \"Hello\"\n"))
+	if err == nil || err.Error() != "(1, 1): front matter is not closed by +++" {
+		t.Errorf("expected an unclosed front matter error, got %v", err)
+	}
+}
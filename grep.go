@@ -0,0 +1,131 @@
+// Search of the lines of documents.
+
+package toml
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Match is a line of a document matched by Grep.
+type Match struct {
+	Line int    // number of the line, starting at 1
+	Text string // content of the line, without its line ending
+	// Key of the value or table header the line belongs to, elements of
+	// arrays of tables being designated by their index, as in
+	// [servers 1 host]. Nil for the lines outside of them, such as comments.
+	Key []string
+	// Header of the table of the line, such as [servers], as written in the
+	// document. Empty for the root table.
+	Header string
+}
+
+// grepElement is a table header or key/value pair found by Grep.
+type grepElement struct {
+	line   int
+	key    []string
+	header bool
+}
+
+// Grep returns the lines of src matching pattern, with the key and the table
+// they belong to. Lines of multi-line arrays and strings belong to the key of
+// their value. Unlike LoadBytes, Grep does not check the consistency of the
+// document, and the lines following a syntax error are returned with the
+// header of the last table before the error, and no key.
+func Grep(src []byte, pattern *regexp.Regexp) []Match {
+	doc := stripBOM(src)
+	lines := strings.Split(string(doc), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	var elements []grepElement
+	arrays := map[string]int{} // index of the current table of arrays of tables
+	var table []string
+	last := len(lines) // last line of the elements
+	p := &tomlParser{flow: lexToml(doc)}
+	for {
+		tok, err := p.nextToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if p.flowIdx > 0 {
+				last = p.flow[p.flowIdx-1].Line - 1
+			}
+			break
+		}
+		switch tok.Kind {
+		case TokenArrayTable:
+			name := strings.Join(tok.Key, ".")
+			if index, ok := arrays[name]; ok {
+				arrays[name] = index + 1
+			} else {
+				arrays[name] = 0
+			}
+			for other := range arrays {
+				if strings.HasPrefix(other, name+".") {
+					delete(arrays, other)
+				}
+			}
+			fallthrough
+		case TokenTable:
+			table = nil
+			for i, k := range tok.Key {
+				table = append(table, k)
+				if index, ok := arrays[strings.Join(tok.Key[:i+1], ".")]; ok {
+					table = append(table, strconv.Itoa(index))
+				}
+			}
+			elements = append(elements, grepElement{tok.Position.Line, table, true})
+		case TokenKeyValue:
+			key := append(table[:len(table):len(table)], tok.Key...)
+			elements = append(elements, grepElement{tok.Position.Line, key, false})
+		}
+	}
+
+	// a value spans the lines up to the next element, except the comments
+	// and blank lines before it
+	keys := make([][]string, len(lines))
+	headers := make([]string, len(lines))
+	header := ""
+	next := 0
+	for i, element := range elements {
+		for ; next < element.line-1; next++ {
+			headers[next] = header
+		}
+		if element.header {
+			header = strings.TrimSpace(lines[element.line-1])
+			keys[element.line-1] = element.key
+			continue
+		}
+		end := last
+		if i+1 < len(elements) {
+			end = elements[i+1].line - 1
+		}
+		for end > element.line && isBlankOrComment(lines[end-1]) {
+			end--
+		}
+		for l := element.line - 1; l < end; l++ {
+			keys[l] = element.key
+		}
+	}
+	for ; next < len(lines); next++ {
+		headers[next] = header
+	}
+
+	var matches []Match
+	for i, line := range lines {
+		if pattern.MatchString(line) {
+			matches = append(matches, Match{Line: i + 1, Text: line, Key: keys[i], Header: headers[i]})
+		}
+	}
+	return matches
+}
+
+func isBlankOrComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || line[0] == '#'
+}
@@ -0,0 +1,49 @@
+package toml
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	doc := `# top
+name = "x"
+
+[[servers]]
+host = "a" # host
+ports = [
+  80,
+]
+# host of the next server
+
+[[servers]]
+host = "b"
+[servers.tls]
+cert = """
+host
+"""
+[other]
+host = [
+broken
+`
+	expected := []Match{
+		{5, `host = "a" # host`, []string{"servers", "0", "host"}, "[[servers]]"},
+		{9, "# host of the next server", nil, "[[servers]]"},
+		{12, `host = "b"`, []string{"servers", "1", "host"}, "[[servers]]"},
+		{15, "host", []string{"servers", "1", "tls", "cert"}, "[servers.tls]"},
+		{18, "host = [", nil, "[other]"},
+	}
+	matches := Grep([]byte(doc), regexp.MustCompile("host"))
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %+v, got %+v", expected, matches)
+	}
+
+	expected = []Match{
+		{7, "  80,", []string{"servers", "0", "ports"}, "[[servers]]"},
+	}
+	matches = Grep([]byte(doc), regexp.MustCompile("80"))
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %+v, got %+v", expected, matches)
+	}
+}
@@ -0,0 +1,78 @@
+// Hashes of the content of tables.
+
+package toml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TableHash returns a hash of the content of the table at the given
+// dot-separated key, or of the whole tree for the empty key, which is its
+// SemanticHash. The hash only changes with the values of the table and its
+// sub-tables: the formatting, the comments, the order of the keys and the
+// representation of the values, such as 0x10 for 16, are ignored. Arrays of
+// tables are hashed as a whole.
+//
+// Services reloading their configuration can compare the hashes of the
+// tables of their components to restart only the ones that changed.
+func (t *Tree) TableHash(key string) (string, error) {
+	var table interface{} = t
+	if key != "" {
+		keys, err := parseKey(key)
+		if err != nil {
+			return "", err
+		}
+		table = t.GetPath(keys)
+	}
+	switch table.(type) {
+	case *Tree, []*Tree:
+	case nil:
+		return "", fmt.Errorf("key %s not found", key)
+	default:
+		return "", fmt.Errorf("key %s is not a table", key)
+	}
+	h := sha256.New()
+	writeCanonical(h, tomlValueToGo(table))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeCanonical writes a representation of v, with the types documented for
+// Tree.ToMap, which is the same for equal values.
+func writeCanonical(w io.Writer, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		io.WriteString(w, "{")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%q=", k)
+			writeCanonical(w, v[k])
+			io.WriteString(w, ";")
+		}
+		io.WriteString(w, "}")
+	case []interface{}:
+		io.WriteString(w, "[")
+		for _, item := range v {
+			writeCanonical(w, item)
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, "]")
+	case string:
+		fmt.Fprintf(w, "string:%q", v)
+	case float64:
+		io.WriteString(w, "float64:"+strconv.FormatFloat(v, 'g', -1, 64))
+	case time.Time:
+		io.WriteString(w, "time.Time:"+v.Format(time.RFC3339Nano))
+	default:
+		fmt.Fprintf(w, "%T:%v", v, v)
+	}
+}
@@ -0,0 +1,98 @@
+package toml
+
+import (
+	"testing"
+)
+
+func TestTableHash(t *testing.T) {
+	hashes := func(doc string) map[string]string {
+		tree, err := Load(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := map[string]string{}
+		for _, key := range []string{"", "server", "server.tls", "database", "workers"} {
+			hash, err := tree.TableHash(key)
+			if err != nil {
+				t.Fatalf("%q: %s", key, err)
+			}
+			result[key] = hash
+		}
+		return result
+	}
+
+	original := hashes(`
+[server]
+host = "localhost"
+port = 0x1F90
+tls = { cert = "a.pem", key = "a.key" }
+
+[database]
+url = "postgres://db"
+timeout = 1.5
+
+[[workers]]
+name = "w1"
+`)
+	reformatted := hashes(`
+# the same values
+[database]
+timeout = 1.50
+url = 'postgres://db'
+
+[server]
+port = 8080
+host = "localhost"
+
+[server.tls]
+key = "a.key"
+cert = "a.pem"
+
+[[workers]]
+name = "w1"
+`)
+	for key, hash := range original {
+		if reformatted[key] != hash {
+			t.Errorf("%q: expected the same hash for the same values", key)
+		}
+	}
+
+	changed := hashes(`
+[server]
+host = "localhost"
+port = 8080
+tls = { cert = "b.pem", key = "a.key" }
+
+[database]
+url = "postgres://db"
+timeout = 1.5
+
+[[workers]]
+name = "w1"
+`)
+	for key, differs := range map[string]bool{"": true, "server": true, "server.tls": true, "database": false, "workers": false} {
+		if (changed[key] != original[key]) != differs {
+			t.Errorf("%q: expected the hash to change: %v", key, differs)
+		}
+	}
+
+	tree, _ := Load("a = 1\nb = 1.0\n[c]")
+	root, _ := tree.TableHash("")
+	if semantic, _ := tree.SemanticHash(); root == "" || root != semantic {
+		t.Errorf("expected the hash of the root table to be the semantic hash: %q, %q", root, semantic)
+	}
+	if _, err := tree.TableHash("a"); err == nil || err.Error() != "key a is not a table" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := tree.TableHash("missing"); err == nil || err.Error() != "key missing not found" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	one, _ := Load("[t]\nv = 1")
+	oneFloat, _ := Load("[t]\nv = 1.0")
+	h1, _ := one.TableHash("t")
+	h2, _ := oneFloat.TableHash("t")
+	if h1 == h2 {
+		t.Errorf("expected integers and floats to have different hashes")
+	}
+}
@@ -0,0 +1,96 @@
+// Conversion hooks of the Decoder.
+
+package toml
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DecodeHook converts a value of the document before the Decoder assigns it.
+// from is the type of the TOML value (string, int64, float64, bool,
+// time.Time, LocalDate, LocalTime, LocalDateTime, []interface{}, *Tree or
+// []*Tree), to is the type of the destination and value the TOML value, or
+// the result of the previous hook. A hook returns value as is when it does
+// not apply.
+type DecodeHook func(from, to reflect.Type, value interface{}) (interface{}, error)
+
+// WithDecodeHook makes the decoder pass every value through hooks, in order,
+// before decoding it. A result of another type than the TOML value that is
+// assignable to the destination is assigned as is. Other results are decoded
+// as if they were the TOML value. This allows conversions such as strings to
+// enumerations:
+//
+//   d.WithDecodeHook(func(from, to reflect.Type, v interface{}) (interface{}, error) {
+//     if to != reflect.TypeOf(Level(0)) || from.Kind() != reflect.String {
+//       return v, nil
+//     }
+//     return ParseLevel(v.(string))
+//   })
+func (d *Decoder) WithDecodeHook(hooks ...DecodeHook) *Decoder {
+	d.hooks = append(d.hooks, hooks...)
+	return d
+}
+
+// applyHooks passes tval through the hooks of the decoder. It returns the
+// value to decode, or the decoded value when ok is true.
+func (d *Decoder) applyHooks(mtype reflect.Type, tval interface{}) (next interface{}, mval reflect.Value, ok bool, err error) {
+	from := reflect.TypeOf(tval)
+	next = tval
+	for _, hook := range d.hooks {
+		next, err = hook(reflect.TypeOf(next), mtype, next)
+		if err != nil {
+			return nil, reflect.Value{}, false, fmt.Errorf("decode hook: %v", err)
+		}
+	}
+	if next == nil {
+		return nil, reflect.Value{}, false, fmt.Errorf("decode hook returned nil for %v", mtype)
+	}
+	if to := reflect.TypeOf(next); to != from && to.AssignableTo(mtype) {
+		d.visitor.visitAll()
+		val := reflect.New(mtype).Elem()
+		val.Set(reflect.ValueOf(next))
+		return next, val, true, nil
+	}
+	return next, reflect.Value{}, false, nil
+}
+
+// RegisterDecoder makes the decoder decode values of type typ with fn, for
+// types that cannot implement Unmarshaler, such as types of other packages.
+// fn receives the TOML value, as described for DecodeHook, and a settable
+// zero value of typ to fill. Registering typ again replaces its function.
+// Functions registered for a pointer type only apply to this pointer type.
+func (d *Decoder) RegisterDecoder(typ reflect.Type, fn func(tval interface{}, v reflect.Value) error) *Decoder {
+	if d.decoders == nil {
+		d.decoders = make(map[reflect.Type]func(interface{}, reflect.Value) error)
+	}
+	d.decoders[typ] = fn
+	return d
+}
+
+// registeredDecoder decodes tval with the function registered for mtype, if
+// any.
+func (d *Decoder) registeredDecoder(mtype reflect.Type, tval interface{}) (reflect.Value, bool, error) {
+	fn, ok := d.decoders[mtype]
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+	d.visitor.visitAll()
+	val := reflect.New(mtype).Elem()
+	if err := fn(tval, val); err != nil {
+		return reflect.Value{}, true, fmt.Errorf("decode %v: %v", mtype, err)
+	}
+	return val, true, nil
+}
+
+func (d *Decoder) isDecoderSequence(mtype reflect.Type) bool {
+	switch mtype.Kind() {
+	case reflect.Ptr:
+		return d.isDecoderSequence(mtype.Elem())
+	case reflect.Slice, reflect.Array:
+		_, ok := d.decoders[mtype.Elem()]
+		return ok
+	default:
+		return false
+	}
+}
@@ -0,0 +1,139 @@
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type hookLevel int
+
+type hookPort struct {
+	Number int
+}
+
+type hookConfig struct {
+	Level  hookLevel
+	Port   hookPort
+	Name   string
+	Others []hookLevel
+}
+
+func hookLevelFromString(from, to reflect.Type, v interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(hookLevel(0)) || from.Kind() != reflect.String {
+		return v, nil
+	}
+	switch v.(string) {
+	case "DEBUG":
+		return hookLevel(1), nil
+	case "INFO":
+		return hookLevel(2), nil
+	}
+	return nil, fmt.Errorf("unknown level %q", v)
+}
+
+func hookPortFromInt(from, to reflect.Type, v interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(hookPort{}) || from.Kind() != reflect.Int64 {
+		return v, nil
+	}
+	return hookPort{Number: int(v.(int64))}, nil
+}
+
+func hookUpper(from, to reflect.Type, v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return strings.ToUpper(s), nil
+	}
+	return v, nil
+}
+
+func TestDecoderDecodeHook(t *testing.T) {
+	doc := []byte(`
+level = "debug"
+port = 8080
+name = "web"
+others = ["info", "debug"]
+`)
+	var config hookConfig
+	err := NewDecoder(bytes.NewReader(doc)).Strict(true).
+		WithDecodeHook(hookUpper, hookLevelFromString, hookPortFromInt).
+		Decode(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := hookConfig{
+		Level:  1,
+		Port:   hookPort{Number: 8080},
+		Name:   "WEB",
+		Others: []hookLevel{2, 1},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("got %+v, expected %+v", config, expected)
+	}
+}
+
+func TestDecoderDecodeHookError(t *testing.T) {
+	var config hookConfig
+	err := NewDecoder(strings.NewReader(`level = "trace"`)).
+		WithDecodeHook(hookLevelFromString).
+		Decode(&config)
+	if err == nil || !strings.Contains(err.Error(), `unknown level "trace"`) {
+		t.Errorf("expected an unknown level error, got %v", err)
+	}
+}
+
+func decodeURL(tval interface{}, v reflect.Value) error {
+	s, ok := tval.(string)
+	if !ok {
+		return fmt.Errorf("expected a string, not %T", tval)
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(*u))
+	return nil
+}
+
+func TestDecoderRegisterDecoder(t *testing.T) {
+	type config struct {
+		Endpoint  url.URL
+		Mirrors   []url.URL
+		Fallback  *url.URL
+		Unrelated string
+	}
+	doc := []byte(`
+endpoint = "https://example.com/api"
+mirrors = ["https://a.example.com", "https://b.example.com"]
+fallback = "https://c.example.com"
+unrelated = "value"
+`)
+	var c config
+	err := NewDecoder(bytes.NewReader(doc)).Strict(true).
+		RegisterDecoder(reflect.TypeOf(url.URL{}), decodeURL).
+		Decode(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Endpoint.Host != "example.com" || c.Endpoint.Path != "/api" {
+		t.Errorf("bad endpoint: %v", c.Endpoint)
+	}
+	if len(c.Mirrors) != 2 || c.Mirrors[1].Host != "b.example.com" {
+		t.Errorf("bad mirrors: %v", c.Mirrors)
+	}
+	if c.Fallback == nil || c.Fallback.Host != "c.example.com" {
+		t.Errorf("bad fallback: %v", c.Fallback)
+	}
+	if c.Unrelated != "value" {
+		t.Errorf("bad unrelated: %v", c.Unrelated)
+	}
+
+	err = NewDecoder(strings.NewReader(`endpoint = 42`)).
+		RegisterDecoder(reflect.TypeOf(url.URL{}), decodeURL).
+		Decode(&c)
+	if err == nil || !strings.Contains(err.Error(), "expected a string, not int64") {
+		t.Errorf("expected an error from the decoder, got %v", err)
+	}
+}
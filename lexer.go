@@ -9,8 +9,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Define state functions
@@ -28,6 +28,16 @@ type tomlLexer struct {
 	col               int
 	endbufferLine     int
 	endbufferCol      int
+	strBuf            []byte // reused to build strings, see appendRune
+	escaped           bool   // the last basic string had escape sequences
+	notes             lexerNotes
+}
+
+// lexerNotes holds what the lexer learns about the document besides tokens.
+type lexerNotes struct {
+	reprs      map[Position]StringRepresentation // of string tokens, unless basic without escapes
+	directives map[int]TableFormat               // toml-fmt comments, by line
+	comments   map[int]string                    // text of the other comments, by line
 }
 
 // Basic read operations on input
@@ -79,6 +89,18 @@ func (l *tomlLexer) emitWithValue(t tokenType, value string) {
 	l.ignore()
 }
 
+// emitString emits a string token, recording its representation unless it
+// is a basic string without escapes.
+func (l *tomlLexer) emitString(value string, repr StringRepresentation) {
+	if repr != (StringRepresentation{}) {
+		if l.notes.reprs == nil {
+			l.notes.reprs = make(map[Position]StringRepresentation)
+		}
+		l.notes.reprs[Position{l.line, l.col}] = repr
+	}
+	l.emitWithValue(tokenString, value)
+}
+
 func (l *tomlLexer) emit(t tokenType) {
 	l.emitWithValue(t, string(l.input[l.currentTokenStart:l.currentTokenStop]))
 }
@@ -624,6 +646,18 @@ func (l *tomlLexer) lexComment(previousState tomlLexStateFn) tomlLexStateFn {
 			}
 			l.next()
 		}
+		comment := string(l.input[l.currentTokenStart:l.currentTokenStop])
+		if format, ok := parseFormatDirective(comment); ok {
+			if l.notes.directives == nil {
+				l.notes.directives = make(map[int]TableFormat)
+			}
+			l.notes.directives[l.line] = format
+		} else {
+			if l.notes.comments == nil {
+				l.notes.comments = make(map[int]string)
+			}
+			l.notes.comments[l.line] = strings.TrimSpace(strings.TrimPrefix(comment, "#"))
+		}
 		l.ignore()
 		return previousState
 	}
@@ -637,7 +671,7 @@ func (l *tomlLexer) lexLeftBracket() tomlLexStateFn {
 }
 
 func (l *tomlLexer) lexLiteralStringAsString(terminator string, discardLeadingNewLine bool) (string, error) {
-	var sb strings.Builder
+	l.strBuf = l.strBuf[:0]
 
 	if discardLeadingNewLine {
 		if l.follow("\r\n") {
@@ -651,14 +685,14 @@ func (l *tomlLexer) lexLiteralStringAsString(terminator string, discardLeadingNe
 	// find end of string
 	for {
 		if l.follow(terminator) {
-			return sb.String(), nil
+			return string(l.strBuf), nil
 		}
 
 		next := l.peek()
 		if next == eof {
 			break
 		}
-		sb.WriteRune(l.next())
+		l.appendRune(l.next())
 	}
 
 	return "", errors.New("unclosed string")
@@ -682,7 +716,11 @@ func (l *tomlLexer) lexLiteralString() tomlLexStateFn {
 		return l.errorf(err.Error())
 	}
 
-	l.emitWithValue(tokenString, str)
+	style := StringLiteral
+	if terminator == "'''" {
+		style = StringMultilineLiteral
+	}
+	l.emitString(str, StringRepresentation{Style: style})
 	l.fastForward(len(terminator))
 	l.ignore()
 	return l.lexRvalue
@@ -692,7 +730,8 @@ func (l *tomlLexer) lexLiteralString() tomlLexStateFn {
 // Terminator is the substring indicating the end of the token.
 // The resulting string does not include the terminator.
 func (l *tomlLexer) lexStringAsString(terminator string, discardLeadingNewLine, acceptNewLines bool) (string, error) {
-	var sb strings.Builder
+	l.strBuf = l.strBuf[:0]
+	l.escaped = false
 
 	if discardLeadingNewLine {
 		if l.follow("\r\n") {
@@ -705,11 +744,12 @@ func (l *tomlLexer) lexStringAsString(terminator string, discardLeadingNewLine,
 
 	for {
 		if l.follow(terminator) {
-			return sb.String(), nil
+			return string(l.strBuf), nil
 		}
 
 		if l.follow("\\") {
 			l.next()
+			l.escaped = true
 			switch l.peek() {
 			case '\r':
 				fallthrough
@@ -718,66 +758,51 @@ func (l *tomlLexer) lexStringAsString(terminator string, discardLeadingNewLine,
 			case '\t':
 				fallthrough
 			case ' ':
+				if !acceptNewLines {
+					return "", errors.New("invalid escape sequence: \\" + string(l.peek()))
+				}
 				// skip all whitespace chars following backslash
 				for strings.ContainsRune("\r\n\t ", l.peek()) {
 					l.next()
 				}
 			case '"':
-				sb.WriteString("\"")
+				l.strBuf = append(l.strBuf, '"')
 				l.next()
 			case 'n':
-				sb.WriteString("\n")
+				l.strBuf = append(l.strBuf, '\n')
 				l.next()
 			case 'b':
-				sb.WriteString("\b")
+				l.strBuf = append(l.strBuf, '\b')
 				l.next()
 			case 'f':
-				sb.WriteString("\f")
+				l.strBuf = append(l.strBuf, '\f')
 				l.next()
 			case '/':
-				sb.WriteString("/")
+				l.strBuf = append(l.strBuf, '/')
 				l.next()
 			case 't':
-				sb.WriteString("\t")
+				l.strBuf = append(l.strBuf, '\t')
 				l.next()
 			case 'r':
-				sb.WriteString("\r")
+				l.strBuf = append(l.strBuf, '\r')
 				l.next()
 			case '\\':
-				sb.WriteString("\\")
+				l.strBuf = append(l.strBuf, '\\')
 				l.next()
 			case 'u':
 				l.next()
-				var code strings.Builder
-				for i := 0; i < 4; i++ {
-					c := l.peek()
-					if !isHexDigit(c) {
-						return "", errors.New("unfinished unicode escape")
-					}
-					l.next()
-					code.WriteRune(c)
-				}
-				intcode, err := strconv.ParseInt(code.String(), 16, 32)
+				code, err := l.lexUnicodeEscape(4)
 				if err != nil {
-					return "", errors.New("invalid unicode escape: \\u" + code.String())
+					return "", err
 				}
-				sb.WriteRune(rune(intcode))
+				l.appendRune(code)
 			case 'U':
 				l.next()
-				var code strings.Builder
-				for i := 0; i < 8; i++ {
-					c := l.peek()
-					if !isHexDigit(c) {
-						return "", errors.New("unfinished unicode escape")
-					}
-					l.next()
-					code.WriteRune(c)
-				}
-				intcode, err := strconv.ParseInt(code.String(), 16, 64)
+				code, err := l.lexUnicodeEscape(8)
 				if err != nil {
-					return "", errors.New("invalid unicode escape: \\U" + code.String())
+					return "", err
 				}
-				sb.WriteRune(rune(intcode))
+				l.appendRune(code)
 			default:
 				return "", errors.New("invalid escape sequence: \\" + string(l.peek()))
 			}
@@ -788,7 +813,7 @@ func (l *tomlLexer) lexStringAsString(terminator string, discardLeadingNewLine,
 				return "", fmt.Errorf("unescaped control character %U", r)
 			}
 			l.next()
-			sb.WriteRune(r)
+			l.appendRune(r)
 		}
 
 		if l.peek() == eof {
@@ -799,6 +824,44 @@ func (l *tomlLexer) lexStringAsString(terminator string, discardLeadingNewLine,
 	return "", errors.New("unclosed string")
 }
 
+// Lex the given number of hexadecimal digits of a unicode escape.
+func (l *tomlLexer) lexUnicodeEscape(digits int) (rune, error) {
+	var code rune
+	for i := 0; i < digits; i++ {
+		c := l.peek()
+		if !isHexDigit(c) {
+			return 0, errors.New("unfinished unicode escape")
+		}
+		l.next()
+		code = code<<4 | hexDigitValue(c)
+	}
+	return code, nil
+}
+
+func hexDigitValue(r rune) rune {
+	switch {
+	case r >= 'a':
+		return r - 'a' + 10
+	case r >= 'A':
+		return r - 'A' + 10
+	default:
+		return r - '0'
+	}
+}
+
+// appendRune appends r to the string being lexed. Strings are built in a
+// buffer shared by the whole input, so that each string only allocates its
+// result.
+func (l *tomlLexer) appendRune(r rune) {
+	if r < utf8.RuneSelf {
+		l.strBuf = append(l.strBuf, byte(r))
+		return
+	}
+	var b [utf8.UTFMax]byte
+	n := utf8.EncodeRune(b[:], r)
+	l.strBuf = append(l.strBuf, b[:n]...)
+}
+
 func (l *tomlLexer) lexString() tomlLexStateFn {
 	l.skip()
 
@@ -819,7 +882,11 @@ func (l *tomlLexer) lexString() tomlLexStateFn {
 		return l.errorf(err.Error())
 	}
 
-	l.emitWithValue(tokenString, str)
+	style := StringBasic
+	if acceptNewLines {
+		style = StringMultilineBasic
+	}
+	l.emitString(str, StringRepresentation{Style: style, Escaped: l.escaped})
 	l.fastForward(len(terminator))
 	l.ignore()
 	return l.lexRvalue
@@ -1017,15 +1084,35 @@ func (l *tomlLexer) run() {
 
 // Entry point
 func lexToml(inputBytes []byte) []token {
-	runes := bytes.Runes(inputBytes)
-	l := &tomlLexer{
-		input:         runes,
+	tokens, _ := lexTomlWithNotes(inputBytes)
+	return tokens
+}
+
+// lexTomlWithNotes also returns what the lexer learns about the document
+// besides tokens.
+func lexTomlWithNotes(inputBytes []byte) ([]token, lexerNotes) {
+	l := newTomlLexer(inputBytes)
+	l.run()
+	return l.tokens, l.notes
+}
+
+// lexValue lexes a standalone value, as found after the equal sign of a
+// key/value pair.
+func lexValue(inputBytes []byte) ([]token, lexerNotes) {
+	l := newTomlLexer(inputBytes)
+	for state := l.lexRvalue; state != nil; {
+		state = state()
+	}
+	return l.tokens, l.notes
+}
+
+func newTomlLexer(inputBytes []byte) *tomlLexer {
+	return &tomlLexer{
+		input:         bytes.Runes(inputBytes),
 		tokens:        make([]token, 0, 256),
 		line:          1,
 		col:           1,
 		endbufferLine: 1,
 		endbufferCol:  1,
 	}
-	l.run()
-	return l.tokens
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"text/tabwriter"
 )
@@ -1245,3 +1246,18 @@ pluralizeListTitles = false
 		lexToml([]byte(sample))
 	}
 }
+
+func BenchmarkLexerEscapedStrings(b *testing.B) {
+	var sample strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&sample, "key%d = \"tab\\there, quote\\\" and \\u00e9\\U0001F600 in a long enough line\"\n", i)
+		fmt.Fprintf(&sample, "lit%d = 'C:\\Users\\nodejs\\templates'\n", i)
+		fmt.Fprintf(&sample, "multi%d = \"\"\"\nfirst line\\n\\\n    continued \\\"quoted\\\"\"\"\"\n", i)
+	}
+	input := []byte(sample.String())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lexToml(input)
+	}
+}
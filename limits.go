@@ -0,0 +1,154 @@
+// Resource limits for untrusted documents.
+
+package toml
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Limits reported in LimitError.
+const (
+	LimitDepth = "depth"          // nesting of tables, arrays and inline tables
+	LimitSize  = "size"           // size of the document in bytes
+	LimitKeys  = "number of keys" // key/value pairs and table headers
+)
+
+// Default limits of the Decoder, which accept any reasonable configuration
+// file. LoadBytes and the functions built on it have no limits.
+const (
+	DefaultMaxDepth        = 1000
+	DefaultMaxKeys         = 1 << 20
+	DefaultMaxDocumentSize = 64 << 20
+	DefaultMaxErrors       = 100
+)
+
+// Limits bounds the resources used to decode a document. Zero fields disable
+// their limit.
+type Limits struct {
+	MaxDepth        int   // nesting of tables, arrays and inline tables
+	MaxKeys         int   // key/value pairs and table headers
+	MaxDocumentSize int64 // size of the document in bytes
+	MaxErrors       int   // errors reported by a Decoder set up with CollectErrors
+}
+
+// DefaultLimits returns the limits of a Decoder unless others are set.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxDepth:        DefaultMaxDepth,
+		MaxKeys:         DefaultMaxKeys,
+		MaxDocumentSize: DefaultMaxDocumentSize,
+		MaxErrors:       DefaultMaxErrors,
+	}
+}
+
+// NoLimits returns limits accepting documents of any size, for trusted
+// inputs only.
+func NoLimits() Limits {
+	return Limits{}
+}
+
+// LimitError is returned by the Decoder when a document exceeds one of the
+// limits set with Limits, MaxDepth, MaxDocumentSize or MaxKeys.
+type LimitError struct {
+	Limit    string   // one of the Limit constants
+	Max      int64    // value of the exceeded limit
+	Position Position // where the limit was exceeded, invalid for LimitSize
+}
+
+func (e *LimitError) Error() string {
+	msg := fmt.Sprintf("document exceeds the maximum %s of %d", e.Limit, e.Max)
+	if e.Position.Invalid() {
+		return msg
+	}
+	return e.Position.String() + ": " + msg
+}
+
+// Limits sets all the limits of the decoder at once. The default is
+// DefaultLimits().
+//
+//   d := toml.NewDecoder(r).Limits(toml.NoLimits())
+func (d *Decoder) Limits(limits Limits) *Decoder {
+	d.limits = limits
+	return d
+}
+
+// MaxDepth makes the decoder reject documents where tables, arrays and inline
+// tables are nested more than max levels deep. A value at the root has a
+// depth of 1, a value of [a.b] a depth of 3, and the elements of an array
+// one more than the array. This protects from documents made to exhaust
+// resources, such as deeply nested arrays.
+func (d *Decoder) MaxDepth(max int) *Decoder {
+	d.limits.MaxDepth = max
+	return d
+}
+
+// MaxKeys makes the decoder reject documents with more than max key/value
+// pairs and table headers, including the keys of inline tables.
+func (d *Decoder) MaxKeys(max int) *Decoder {
+	d.limits.MaxKeys = max
+	return d
+}
+
+// MaxDocumentSize makes the decoder reject inputs of more than max bytes
+// without reading them past the limit.
+func (d *Decoder) MaxDocumentSize(max int64) *Decoder {
+	d.limits.MaxDocumentSize = max
+	return d
+}
+
+// MaxErrors makes a decoder set up with CollectErrors stop after max errors.
+func (d *Decoder) MaxErrors(max int) *Decoder {
+	d.limits.MaxErrors = max
+	return d
+}
+
+// LoadBytesWithLimits creates a Tree from a []byte, like LoadBytes, within
+// the given limits, such as DefaultLimits() for untrusted inputs.
+func LoadBytesWithLimits(b []byte, limits Limits) (*Tree, error) {
+	if limits.MaxDocumentSize > 0 && int64(len(b)) > limits.MaxDocumentSize {
+		return nil, &LimitError{Limit: LimitSize, Max: limits.MaxDocumentSize}
+	}
+	return loadBytes(b, parseOptions{limits: limits})
+}
+
+// readAll reads the whole input, or its next document when documents are
+// delimited, up to the maximum document size.
+func (d *Decoder) readAll() ([]byte, error) {
+	max := d.limits.MaxDocumentSize
+	var b []byte
+	var err error
+	if d.delimiter != "" {
+		b, err = d.readDocument(max)
+	} else {
+		var r io.Reader = d.reader()
+		if max > 0 {
+			r = io.LimitReader(r, max+1)
+		}
+		b, err = ioutil.ReadAll(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if max > 0 && int64(len(b)) > max {
+		return nil, &LimitError{Limit: LimitSize, Max: max}
+	}
+	if d.lenientUTF8 {
+		b = replaceInvalidUTF8(stripBOM(b))
+	}
+	return b, nil
+}
+
+func (p *tomlParser) checkDepth(tok *token, depth int) {
+	if p.limits.MaxDepth > 0 && depth > p.limits.MaxDepth {
+		panic(&LimitError{Limit: LimitDepth, Max: int64(p.limits.MaxDepth), Position: tok.Position})
+	}
+}
+
+func (p *tomlParser) countKey(tok *token) {
+	p.keys++
+	if p.limits.MaxKeys > 0 && p.keys > p.limits.MaxKeys {
+		panic(&LimitError{Limit: LimitKeys, Max: int64(p.limits.MaxKeys), Position: tok.Position})
+	}
+}
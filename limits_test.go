@@ -0,0 +1,159 @@
+package toml
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoderLimits(t *testing.T) {
+	tests := []struct {
+		doc   string
+		setup func(d *Decoder) *Decoder
+		limit string
+		pos   Position
+	}{
+		{
+			doc:   "a = [[[[1]]]]",
+			setup: func(d *Decoder) *Decoder { return d.MaxDepth(4) },
+			limit: LimitDepth,
+			pos:   Position{1, 9},
+		},
+		{
+			doc:   "[a.b]\nc = { d = 1 }",
+			setup: func(d *Decoder) *Decoder { return d.MaxDepth(3) },
+			limit: LimitDepth,
+			pos:   Position{2, 7},
+		},
+		{
+			doc:   "[a.b.c.d]",
+			setup: func(d *Decoder) *Decoder { return d.MaxDepth(3) },
+			limit: LimitDepth,
+			pos:   Position{1, 2},
+		},
+		{
+			doc:   "a = 1\nb = { c = 2, d = 3 }",
+			setup: func(d *Decoder) *Decoder { return d.MaxKeys(3) },
+			limit: LimitKeys,
+			pos:   Position{2, 14},
+		},
+		{
+			doc:   "a = 1\n[t]\nb = 2",
+			setup: func(d *Decoder) *Decoder { return d.MaxKeys(2) },
+			limit: LimitKeys,
+			pos:   Position{3, 1},
+		},
+		{
+			doc:   "a = \"0123456789\"",
+			setup: func(d *Decoder) *Decoder { return d.MaxDocumentSize(10) },
+			limit: LimitSize,
+		},
+	}
+	for _, test := range tests {
+		var v map[string]interface{}
+		err := test.setup(NewDecoder(strings.NewReader(test.doc))).Decode(&v)
+		limitErr, ok := err.(*LimitError)
+		if !ok {
+			t.Errorf("%q: expected a LimitError, got %v", test.doc, err)
+			continue
+		}
+		if limitErr.Limit != test.limit || limitErr.Position != test.pos {
+			t.Errorf("%q: unexpected error %s", test.doc, limitErr)
+		}
+
+	}
+
+	var v map[string]interface{}
+	d := NewDecoder(strings.NewReader("a = [[1]]\n[b.c]\nd = 1")).MaxDepth(3).MaxKeys(3).MaxDocumentSize(100)
+	if err := d.Decode(&v); err != nil {
+		t.Errorf("the document should be within the limits: %s", err)
+	}
+}
+
+func TestDecoderTokenLimits(t *testing.T) {
+	d := NewDecoder(strings.NewReader("a = 1\n[t.u]\nb = [[2]]")).MaxDepth(4)
+	var err error
+	for err == nil {
+		_, err = d.Token()
+	}
+	limitErr, ok := err.(*LimitError)
+	if !ok || limitErr.Limit != LimitDepth || limitErr.Position != (Position{3, 7}) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	d = NewDecoder(strings.NewReader("a = 1\nb = 2")).MaxKeys(2)
+	for err = nil; err == nil; {
+		_, err = d.Token()
+	}
+	if err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestLimitErrorMessage(t *testing.T) {
+	err := &LimitError{Limit: LimitKeys, Max: 10, Position: Position{3, 1}}
+	if err.Error() != "(3, 1): document exceeds the maximum number of keys of 10" {
+		t.Errorf("unexpected message: %s", err)
+	}
+	err = &LimitError{Limit: LimitSize, Max: 1024}
+	if err.Error() != "document exceeds the maximum size of 1024" {
+		t.Errorf("unexpected message: %s", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	deep := strings.Repeat("[", DefaultMaxDepth) + strings.Repeat("]", DefaultMaxDepth)
+	var v map[string]interface{}
+	err := NewDecoder(strings.NewReader("a = " + deep)).Decode(&v)
+	if limitErr, ok := err.(*LimitError); !ok || limitErr.Limit != LimitDepth || limitErr.Max != DefaultMaxDepth {
+		t.Errorf("expected the default depth limit, got %v", err)
+	}
+	if _, err := LoadBytes([]byte("a = " + deep)); err != nil {
+		t.Errorf("expected LoadBytes to have no limits, got %v", err)
+	}
+	if _, err := LoadBytesWithLimits([]byte("a = "+deep), DefaultLimits()); err == nil {
+		t.Errorf("expected LoadBytesWithLimits to apply the default limits")
+	}
+	if err := NewDecoder(strings.NewReader("a = " + deep)).Limits(NoLimits()).Decode(&v); err != nil {
+		t.Errorf("expected no error without limits, got %v", err)
+	}
+	if _, err := LoadBytesWithLimits([]byte("a = "+deep), NoLimits()); err != nil {
+		t.Errorf("expected no error without limits, got %v", err)
+	}
+
+	_, err = LoadBytesWithLimits([]byte("a = 1\nb = 2"), Limits{MaxDocumentSize: 8})
+	if limitErr, ok := err.(*LimitError); !ok || limitErr.Limit != LimitSize {
+		t.Errorf("expected a size limit error, got %v", err)
+	}
+	_, err = LoadBytesWithLimits([]byte("a = 1\nb = 2"), Limits{MaxKeys: 1})
+	if limitErr, ok := err.(*LimitError); !ok || limitErr.Limit != LimitKeys || limitErr.Position != (Position{2, 1}) {
+		t.Errorf("expected a key limit error, got %v", err)
+	}
+}
+
+func TestDecoderMaxErrors(t *testing.T) {
+	var v struct {
+		A, B, C int
+	}
+	err := NewDecoder(strings.NewReader("a = 'x'\nb = 'y'\nc = 'z'")).CollectErrors(true).MaxErrors(2).Decode(&v)
+	errs, ok := err.(DecodeErrors)
+	if !ok || len(errs) != 2 {
+		t.Errorf("expected two errors, got %v", err)
+	}
+
+	var nested struct {
+		Sub struct {
+			A, B, C int
+		}
+	}
+	err = NewDecoder(strings.NewReader("[sub]\na = 'x'\nb = 'y'\nc = 'z'")).CollectErrors(true).MaxErrors(2).Decode(&nested)
+	errs, ok = err.(DecodeErrors)
+	if !ok || len(errs) > 2 {
+		t.Errorf("expected at most two errors, got %v", err)
+	}
+	for _, err := range errs {
+		if _, ok := err.(DecodeErrors); ok || strings.Contains(err.Error(), "errors while decoding") {
+			t.Errorf("errors should not be nested: %v", err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Conversions of local dates and times to user types.
+
+package toml
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+type localConversionKey struct {
+	from reflect.Type
+	to   reflect.Type
+}
+
+var localConversions = struct {
+	sync.RWMutex
+	funcs map[localConversionKey]reflect.Value
+}{funcs: make(map[localConversionKey]reflect.Value)}
+
+// RegisterLocalType makes the Decoder convert TOML local dates, times or
+// date-times with fn when they are decoded into the type returned by fn. fn
+// must be a function taking a LocalDate, a LocalTime or a LocalDateTime and
+// returning a single value, for example:
+//
+//   toml.RegisterLocalType(func(d toml.LocalDate) civil.Date {
+//     return civil.Date{Year: d.Year, Month: d.Month, Day: d.Day}
+//   })
+//
+// Fields of the returned type then accept local values without a wrapper
+// type, as do pointers to this type. Registering a conversion between the same
+// types again replaces it. RegisterLocalType panics if fn has another
+// signature.
+func RegisterLocalType(fn interface{}) {
+	fval := reflect.ValueOf(fn)
+	ftype := fval.Type()
+	if ftype.Kind() != reflect.Func || ftype.NumIn() != 1 || ftype.NumOut() != 1 || ftype.IsVariadic() {
+		panic(fmt.Sprintf("toml: local type conversion must be a function with one argument and one result, not %s", ftype))
+	}
+	switch from := ftype.In(0); from {
+	case localDateType, localTimeType, localDateTimeType:
+	default:
+		panic(fmt.Sprintf("toml: local type conversion must take a LocalDate, a LocalTime or a LocalDateTime, not %s", from))
+	}
+	localConversions.Lock()
+	defer localConversions.Unlock()
+	localConversions.funcs[localConversionKey{from: ftype.In(0), to: ftype.Out(0)}] = fval
+}
+
+// convertLocal converts tval to mtype with a function registered by
+// RegisterLocalType, if any.
+func convertLocal(tval interface{}, mtype reflect.Type) (reflect.Value, bool) {
+	localConversions.RLock()
+	fn, ok := localConversions.funcs[localConversionKey{from: reflect.TypeOf(tval), to: mtype}]
+	localConversions.RUnlock()
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return fn.Call([]reflect.Value{reflect.ValueOf(tval)})[0], true
+}
+
+// isLocalType reports whether a conversion to mtype, or to the type mtype
+// points to, is registered.
+func isLocalType(mtype reflect.Type) bool {
+	if mtype.Kind() == reflect.Ptr {
+		mtype = mtype.Elem()
+	}
+	localConversions.RLock()
+	defer localConversions.RUnlock()
+	for key := range localConversions.funcs {
+		if key.to == mtype {
+			return true
+		}
+	}
+	return false
+}
+
+func isLocalTypeSequence(mtype reflect.Type) bool {
+	switch mtype.Kind() {
+	case reflect.Ptr:
+		return isLocalTypeSequence(mtype.Elem())
+	case reflect.Slice, reflect.Array:
+		return isLocalType(mtype.Elem())
+	default:
+		return false
+	}
+}
@@ -0,0 +1,64 @@
+package toml
+
+import (
+	"testing"
+	"time"
+)
+
+type testCivilDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+type testClock int // seconds since midnight
+
+func TestRegisterLocalType(t *testing.T) {
+	RegisterLocalType(func(d LocalDate) testCivilDate {
+		return testCivilDate{Year: d.Year, Month: d.Month, Day: d.Day}
+	})
+	RegisterLocalType(func(lt LocalTime) testClock {
+		return testClock(lt.Hour*3600 + lt.Minute*60 + lt.Second)
+	})
+
+	var config struct {
+		Released testCivilDate
+		Opening  *testClock
+		Dates    []testCivilDate
+	}
+	doc := `
+released = 2021-06-14 # first release
+opening = 08:30:00
+dates = [ 1979-05-27 ]
+`
+	if err := Unmarshal([]byte(doc), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Released != (testCivilDate{2021, time.June, 14}) {
+		t.Errorf("unexpected release date: %+v", config.Released)
+	}
+	if config.Opening == nil || *config.Opening != 8*3600+30*60 {
+		t.Errorf("unexpected opening time: %v", config.Opening)
+	}
+	if len(config.Dates) != 1 || config.Dates[0] != (testCivilDate{1979, time.May, 27}) {
+		t.Errorf("unexpected dates: %+v", config.Dates)
+	}
+}
+
+func TestRegisterLocalTypeInvalid(t *testing.T) {
+	for _, fn := range []interface{}{
+		42,
+		func(d LocalDate) {},
+		func(s string) int { return 0 },
+		func(d LocalDate, loc *time.Location) time.Time { return time.Time{} },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%T should be rejected", fn)
+				}
+			}()
+			RegisterLocalType(fn)
+		}()
+	}
+}
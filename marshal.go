@@ -1,11 +1,15 @@
 package toml
 
 import (
+	"bufio"
 	"bytes"
 	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
+	"math/rand"
 	"reflect"
 	"sort"
 	"strconv"
@@ -31,6 +35,8 @@ type tomlOpts struct {
 	literal      bool
 	include      bool
 	omitempty    bool
+	required     bool
+	keyedBy      string
 	defaultValue string
 }
 
@@ -45,11 +51,13 @@ var encOptsDefaults = encOpts{
 
 type annotation struct {
 	tag          string
+	fallbackTag  string // read when a field has no tag, if set
 	comment      string
 	commented    string
 	multiline    string
 	literal      string
 	defaultValue string
+	fieldName    FieldNameMapper
 }
 
 var annotationDefault = annotation{
@@ -72,6 +80,17 @@ const (
 	OrderPreserve
 )
 
+// LineEnding is the sequence of characters the Encoder uses to end lines.
+type LineEnding int
+
+// Line endings the Encoder can write to the output stream.
+const (
+	// End lines with "\n".
+	LineEndingLF LineEnding = iota + 1
+	// End lines with "\r\n".
+	LineEndingCRLF
+)
+
 var timeType = reflect.TypeOf(time.Time{})
 var marshalerType = reflect.TypeOf(new(Marshaler)).Elem()
 var unmarshalerType = reflect.TypeOf(new(Unmarshaler)).Elem()
@@ -81,6 +100,7 @@ var localDateType = reflect.TypeOf(LocalDate{})
 var localTimeType = reflect.TypeOf(LocalTime{})
 var localDateTimeType = reflect.TypeOf(LocalDateTime{})
 var mapStringInterfaceType = reflect.TypeOf(map[string]interface{}{})
+var jsonRawMessageType = reflect.TypeOf(json.RawMessage{})
 
 // Check if the given marshal type maps to a Tree primitive
 func isPrimitive(mtype reflect.Type) bool {
@@ -144,6 +164,30 @@ func isTextMarshalerSequence(mtype reflect.Type) bool {
 	}
 }
 
+// Check if the given marshal type maps to a slice or array of a custom unmarshaler type
+func isCustomUnmarshalerSequence(mtype reflect.Type) bool {
+	switch mtype.Kind() {
+	case reflect.Ptr:
+		return isCustomUnmarshalerSequence(mtype.Elem())
+	case reflect.Slice, reflect.Array:
+		return isCustomUnmarshaler(mtype.Elem()) || isCustomUnmarshaler(reflect.New(mtype.Elem()).Type())
+	default:
+		return false
+	}
+}
+
+// Check if the given marshal type maps to a slice or array of a text unmarshaler type
+func isTextUnmarshalerSequence(mtype reflect.Type) bool {
+	switch mtype.Kind() {
+	case reflect.Ptr:
+		return isTextUnmarshalerSequence(mtype.Elem())
+	case reflect.Slice, reflect.Array:
+		return isTextUnmarshaler(mtype.Elem()) || isTextUnmarshaler(reflect.New(mtype.Elem()).Type())
+	default:
+		return false
+	}
+}
+
 // Check if the given marshal type maps to a non-Tree slice or array
 func isOtherSequence(mtype reflect.Type) bool {
 	switch mtype.Kind() {
@@ -170,6 +214,11 @@ func isTree(mtype reflect.Type) bool {
 	}
 }
 
+// Check if the given marshal type is a pointer to a struct mapping to a Tree
+func isStructPointer(mtype reflect.Type) bool {
+	return mtype.Kind() == reflect.Ptr && mtype.Elem().Kind() == reflect.Struct && isTree(mtype)
+}
+
 func isCustomMarshaler(mtype reflect.Type) bool {
 	return mtype.Implements(marshalerType)
 }
@@ -210,6 +259,16 @@ type Marshaler interface {
 
 // Unmarshaler is the interface implemented by types that
 // can unmarshal a TOML description of themselves.
+//
+// UnmarshalTOML receives the TOML value with the types documented for
+// Tree.ToMap: tables are given as map[string]interface{}, arrays and arrays
+// of tables as []interface{}, and scalars as string, int64, float64, bool or
+// one of the date and time types. This allows types such as versions or
+// identifiers to parse their own representation.
+//
+// This is the Unmarshaler interface of github.com/BurntSushi/toml, so types
+// written for it decode unchanged, except that local dates and times are
+// given as LocalDate, LocalDateTime and LocalTime instead of time.Time.
 type Unmarshaler interface {
 	UnmarshalTOML(interface{}) error
 }
@@ -247,6 +306,10 @@ Tree primitive types and corresponding marshal types:
   bool       bool, pointers to same
   time.LocalTime  time.LocalTime{}, pointers to same
 
+A json.RawMessage is encoded as the value it holds, objects as tables and
+arrays of objects as arrays of tables. As with pointers, empty messages and
+null are not emitted, nor are the keys of objects holding null.
+
 For additional flexibility, use the Encoder API.
 */
 func Marshal(v interface{}) ([]byte, error) {
@@ -264,18 +327,28 @@ type Encoder struct {
 	promoteAnon     bool
 	compactComments bool
 	indentation     string
+	lineEnding      LineEnding
+	trailingNewline bool
+	groupDigits     bool
+	defaults        interface{}
+	provenance      func(key []string) string
+	shards          ShardFunc
+	seedMaps        bool
+	mapSeed         int64
 }
 
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
 	return &Encoder{
-		w:           w,
-		encOpts:     encOptsDefaults,
-		annotation:  annotationDefault,
-		line:        0,
-		col:         1,
-		order:       OrderAlphabetical,
-		indentation: "  ",
+		w:               w,
+		encOpts:         encOptsDefaults,
+		annotation:      annotationDefault,
+		line:            0,
+		col:             1,
+		order:           OrderAlphabetical,
+		indentation:     "  ",
+		lineEnding:      LineEndingLF,
+		trailingNewline: true,
 	}
 }
 
@@ -327,6 +400,16 @@ func (e *Encoder) Order(ord MarshalOrder) *Encoder {
 	return e
 }
 
+// MapOrderSeed makes the encoder visit the keys of maps in an order derived
+// from seed instead of the random iteration order of Go maps. The keys are
+// not sorted, but the same seed always gives the same order, which keeps
+// golden files stable when used with OrderPreserve.
+func (e *Encoder) MapOrderSeed(seed int64) *Encoder {
+	e.seedMaps = true
+	e.mapSeed = seed
+	return e
+}
+
 // Indentation allows to change indentation when marshalling.
 func (e *Encoder) Indentation(indent string) *Encoder {
 	e.indentation = indent
@@ -339,6 +422,21 @@ func (e *Encoder) SetTagName(v string) *Encoder {
 	return e
 }
 
+// SetFallbackTagName makes the encoder read the name and options of the
+// fields without a toml tag from the tag v, such as "json", so that structs
+// already annotated for another format need no toml tags.
+func (e *Encoder) SetFallbackTagName(v string) *Encoder {
+	e.fallbackTag = v
+	return e
+}
+
+// WithFieldNameMapper sets the function giving the keys of the struct fields
+// that have no name in their toml tag, instead of their Go name.
+func (e *Encoder) WithFieldNameMapper(fn FieldNameMapper) *Encoder {
+	e.fieldName = fn
+	return e
+}
+
 // SetTagComment allows changing default tag "comment"
 func (e *Encoder) SetTagComment(v string) *Encoder {
 	e.comment = v
@@ -376,6 +474,85 @@ func (e *Encoder) CompactComments(cc bool) *Encoder {
 	return e
 }
 
+// LineEnding allows to change the line ending written to the output stream.
+// Defaults to LineEndingLF.
+func (e *Encoder) LineEnding(le LineEnding) *Encoder {
+	e.lineEnding = le
+	return e
+}
+
+// LineEndingFrom sets the line ending to the one used by template, typically
+// the previous version of the file being written. Templates without any line
+// ending leave the current setting unchanged.
+func (e *Encoder) LineEndingFrom(template []byte) *Encoder {
+	idx := bytes.IndexByte(template, '\n')
+	if idx < 0 {
+		return e
+	}
+	if idx > 0 && template[idx-1] == '\r' {
+		e.lineEnding = LineEndingCRLF
+	} else {
+		e.lineEnding = LineEndingLF
+	}
+	return e
+}
+
+// GroupDigits sets up the encoder to separate groups of three digits with
+// underscores in integers of five digits or more, for readability.
+//
+// For example:
+//
+//   A = 1000000
+//
+// Becomes
+//
+//   A = 1_000_000
+func (e *Encoder) GroupDigits(v bool) *Encoder {
+	e.groupDigits = v
+	return e
+}
+
+// Provenance sets up the encoder to describe the origin of each value in a
+// comment at the end of its line. origin is called with the path of each key,
+// elements of arrays of tables being designated by their index, and returns
+// a description such as "default" or "from env APP_PORT". Keys for which it
+// returns an empty string are left without comment.
+//
+// For example:
+//
+//   port = 8080 # from env APP_PORT
+func (e *Encoder) Provenance(origin func(key []string) string) *Encoder {
+	e.provenance = origin
+	return e
+}
+
+// lineBreaks replaces the line breaks of trailing comments, which cannot
+// span several lines.
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+func annotateProvenance(path []string, t *Tree, origin func(key []string) string) {
+	for k, v := range t.values {
+		keyPath := append(append([]string{}, path...), k)
+		switch node := v.(type) {
+		case *Tree:
+			annotateProvenance(keyPath, node, origin)
+		case []*Tree:
+			for i, item := range node {
+				annotateProvenance(append(keyPath[:len(keyPath):len(keyPath)], strconv.Itoa(i)), item, origin)
+			}
+		case *tomlValue:
+			node.trailing = lineBreaks.Replace(origin(keyPath))
+		}
+	}
+}
+
+// TrailingNewline sets whether a non-empty output ends with a line ending.
+// Defaults to true. When false, trailing line endings are removed.
+func (e *Encoder) TrailingNewline(v bool) *Encoder {
+	e.trailingNewline = v
+	return e
+}
+
 func (e *Encoder) marshal(v interface{}) ([]byte, error) {
 	// Check if indentation is valid
 	for _, char := range e.indentation {
@@ -413,11 +590,51 @@ func (e *Encoder) marshal(v interface{}) ([]byte, error) {
 	if err != nil {
 		return []byte{}, err
 	}
+	if e.defaults != nil || e.provenance != nil || e.shards != nil {
+		// the tables of trees given to Encode, directly or in fields, are
+		// the ones of the caller
+		t = t.deepCopy()
+	}
+	if e.defaults != nil {
+		de := *e
+		def, err := de.marshalableTree(e.defaults)
+		if err != nil {
+			return []byte{}, err
+		}
+		pruneDefaults(t, def)
+	}
+	if e.provenance != nil {
+		annotateProvenance(nil, t, e.provenance)
+	}
+	if e.shards != nil {
+		if err := e.writeShards(t); err != nil {
+			return []byte{}, err
+		}
+	}
+	return e.writeTree(t)
+}
 
+// Write a tree with the settings of the encoder.
+func (e *Encoder) writeTree(t *Tree) ([]byte, error) {
 	var buf bytes.Buffer
-	_, err = t.writeToOrdered(&buf, "", "", 0, e.arraysOneElementPerLine, e.order, e.indentation, e.compactComments, false)
+	_, err := t.writeToOrdered(&buf, "", "", 0, e.arraysOneElementPerLine, e.order, e.indentation, e.compactComments, false, e.newline())
+	if err != nil {
+		return buf.Bytes(), err
+	}
 
-	return buf.Bytes(), err
+	if !e.trailingNewline {
+		return bytes.TrimRight(buf.Bytes(), "\r\n"), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// newline returns the line ending written by the encoder. The line breaks in
+// the content of multi-line strings are "\n" whatever the line ending.
+func (e *Encoder) newline() string {
+	if e.lineEnding == LineEndingCRLF {
+		return "\r\n"
+	}
+	return "\n"
 }
 
 // Create next tree with a position based on Encoder.line
@@ -440,7 +657,7 @@ func (e *Encoder) valueToTree(mtype reflect.Type, mval reflect.Value) (*Tree, er
 			for i := 0; i < mtype.NumField(); i++ {
 				mtypef, mvalf := mtype.Field(i), mval.Field(i)
 				opts := tomlOptions(mtypef, e.annotation)
-				if opts.include && ((mtypef.Type.Kind() != reflect.Interface && !opts.omitempty) || !isZero(mvalf)) {
+				if opts.include && ((mtypef.Type.Kind() != reflect.Interface && !opts.omitempty) || !isZero(mvalf)) && !isJSONNull(mtypef.Type, mvalf) {
 					val, err := e.valueToToml(mtypef.Type, mvalf)
 					if err != nil {
 						return nil, err
@@ -461,7 +678,9 @@ func (e *Encoder) valueToTree(mtype reflect.Type, mval reflect.Value) (*Tree, er
 		}
 	case reflect.Map:
 		keys := mval.MapKeys()
-		if e.order == OrderPreserve && len(keys) > 0 {
+		if e.seedMaps {
+			shuffleKeys(keys, e.mapSeed)
+		} else if e.order == OrderPreserve && len(keys) > 0 {
 			// Sorting []reflect.Value is not straight forward.
 			//
 			// OrderPreserve will support deterministic results when string is used
@@ -481,7 +700,7 @@ func (e *Encoder) valueToTree(mtype reflect.Type, mval reflect.Value) (*Tree, er
 		}
 		for _, key := range keys {
 			mvalf := mval.MapIndex(key)
-			if (mtype.Elem().Kind() == reflect.Ptr || mtype.Elem().Kind() == reflect.Interface) && mvalf.IsNil() {
+			if (mtype.Elem().Kind() == reflect.Ptr || mtype.Elem().Kind() == reflect.Interface) && mvalf.IsNil() || isJSONNull(mtype.Elem(), mvalf) {
 				continue
 			}
 			val, err := e.valueToToml(mtype.Elem(), mvalf)
@@ -490,13 +709,13 @@ func (e *Encoder) valueToTree(mtype reflect.Type, mval reflect.Value) (*Tree, er
 			}
 			val = e.wrapTomlValue(val, tval)
 			if e.quoteMapKeys {
-				keyStr, err := tomlValueStringRepresentation(key.String(), "", "", e.order, e.arraysOneElementPerLine)
+				keyStr, err := tomlValueStringRepresentation(mapKeyToString(key), "", "", e.order, e.arraysOneElementPerLine, "\n")
 				if err != nil {
 					return nil, err
 				}
 				tval.SetPath([]string{keyStr}, val)
 			} else {
-				tval.SetPath([]string{key.String()}, val)
+				tval.SetPath([]string{mapKeyToString(key)}, val)
 			}
 		}
 	}
@@ -543,9 +762,14 @@ func (e *Encoder) valueToToml(mtype reflect.Type, mval reflect.Value) (interface
 		}
 	}
 	if mtype.Kind() == reflect.Interface {
+		if name, ok := registeredTypeName(mtype, mval.Elem().Type()); ok {
+			return e.valueToTypedTree(name, mval.Elem())
+		}
 		return e.valueToToml(mval.Elem().Type(), mval.Elem())
 	}
 	switch {
+	case mtype == jsonRawMessageType:
+		return e.jsonToToml(mval)
 	case isCustomMarshaler(mtype):
 		return callCustomMarshaler(mval)
 	case isTextMarshaler(mtype):
@@ -580,6 +804,70 @@ func (e *Encoder) valueToToml(mtype reflect.Type, mval reflect.Value) (interface
 	}
 }
 
+// Convert a json.RawMessage to the toml value it holds
+func (e *Encoder) jsonToToml(mval reflect.Value) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(mval.Bytes()))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("marshal json: %v", err)
+	}
+	v, err := fromJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	return e.valueToToml(reflect.TypeOf(v), reflect.ValueOf(v))
+}
+
+// fromJSON converts the numbers of a decoded JSON value to int64 or float64
+// and the arrays of objects to arrays of tables, and drops the keys of
+// objects holding null, which TOML cannot represent.
+func fromJSON(v interface{}) (interface{}, error) {
+	var err error
+	switch value := v.(type) {
+	case nil:
+		return nil, errors.New("marshal json: null cannot be marshaled to TOML")
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i, nil
+		}
+		return value.Float64()
+	case []interface{}:
+		tables := make([]map[string]interface{}, 0, len(value))
+		for i := range value {
+			if value[i], err = fromJSON(value[i]); err != nil {
+				return nil, err
+			}
+			if table, ok := value[i].(map[string]interface{}); ok {
+				tables = append(tables, table)
+			}
+		}
+		// arrays of objects are arrays of tables
+		if len(value) > 0 && len(tables) == len(value) {
+			return tables, nil
+		}
+	case map[string]interface{}:
+		for k := range value {
+			if value[k] == nil {
+				delete(value, k)
+			} else if value[k], err = fromJSON(value[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// isJSONNull reports whether mval is an empty json.RawMessage or holds null,
+// which are omitted like nil pointers.
+func isJSONNull(mtype reflect.Type, mval reflect.Value) bool {
+	if mtype != jsonRawMessageType {
+		return false
+	}
+	b := bytes.TrimSpace(mval.Bytes())
+	return len(b) == 0 || string(b) == "null"
+}
+
 func (e *Encoder) appendTree(t, o *Tree) error {
 	for key, value := range o.values {
 		if _, ok := t.values[key]; ok {
@@ -609,13 +897,46 @@ func (e *Encoder) wrapTomlValue(val interface{}, parent *Tree) interface{} {
 			parent.position.Col,
 		},
 	}
+	if e.groupDigits {
+		ret.raw = groupDigits(val)
+	}
 	e.line++
 	return ret
 }
 
+// Returns the representation of an integer with groups of three digits
+// separated by underscores, or an empty string if val is not an integer
+// of at least five digits.
+func groupDigits(val interface{}) string {
+	var s string
+	switch v := val.(type) {
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case uint64:
+		s = strconv.FormatUint(v, 10)
+	default:
+		return ""
+	}
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	if len(s) < 5 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
 // Unmarshal attempts to unmarshal the Tree into a Go struct pointed by v.
-// Neither Unmarshaler interfaces nor UnmarshalTOML functions are supported for
-// sub-structs, and only definite types can be unmarshaled.
+// See the documentation of the Unmarshal function for details.
 func (t *Tree) Unmarshal(v interface{}) error {
 	d := Decoder{tval: t, tagName: tagFieldName}
 	return d.unmarshal(v)
@@ -633,16 +954,30 @@ func (t *Tree) Marshal() ([]byte, error) {
 }
 
 // Unmarshal parses the TOML-encoded data and stores the result in the value
-// pointed to by v. Behavior is similar to the Go json encoder, except that there
-// is no concept of an Unmarshaler interface or UnmarshalTOML function for
-// sub-structs, and currently only definite types can be unmarshaled to (i.e. no
-// `interface{}`).
+// pointed to by v. Behavior is similar to the Go json encoder. Types
+// implementing Unmarshaler, or encoding.TextUnmarshaler for scalar values,
+// decode their own representation. Fields of type time.Duration accept
+// integers of nanoseconds as well as strings in the format of
+// time.ParseDuration, such as "1h15m". Fields of type json.RawMessage
+// receive their value encoded as JSON, tables included, for sections handed
+// over to libraries configured with JSON. Map keys are strings, integers, or
+// types implementing encoding.TextUnmarshaler; keys of integer maps must be
+// decimal integers that fit the type.
 //
 // The following struct annotations are supported:
 //
 //   toml:"Field" Overrides the field's name to map to.
+//   required      Makes Unmarshal fail when the key is missing.
+//   keyed=name    Decodes an array of tables into a map, by their name key.
 //   default:"foo" Provides a default value.
 //
+// Unmarshal reports all the missing required keys at once, as in
+// toml:"dsn,required". Required fields of a missing table are reported too.
+//
+// With toml:"servers,keyed=name", the tables of [[servers]] decode into a map
+// such as map[string]Server, indexed by their name key. Tables of tables
+// decode into the map as usual.
+//
 // For default values, only fields of the following types are supported:
 //   * string
 //   * bool
@@ -650,6 +985,9 @@ func (t *Tree) Marshal() ([]byte, error) {
 //   * int64
 //   * float64
 //
+// Unmarshal returns an InvalidUnmarshalError when v is not a non-nil pointer
+// to a struct, a map or an interface.
+//
 // See Marshal() documentation for types mapping table.
 func Unmarshal(data []byte, v interface{}) error {
 	t, err := LoadReader(bytes.NewReader(data))
@@ -659,14 +997,140 @@ func Unmarshal(data []byte, v interface{}) error {
 	return t.Unmarshal(v)
 }
 
+// InvalidUnmarshalError describes an invalid argument passed to Unmarshal,
+// which needs a non-nil pointer to a struct, a map or an interface.
+type InvalidUnmarshalError struct {
+	Type reflect.Type // type of the argument, nil for a nil interface
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "nil cannot be unmarshaled from TOML, pass a pointer such as &v"
+	}
+	if e.Type.Kind() != reflect.Ptr {
+		return "non-pointer " + e.Type.String() + " cannot be unmarshaled from TOML, pass a pointer such as &v"
+	}
+	switch e.Type.Elem().Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface:
+		return "nil " + e.Type.String() + " cannot be unmarshaled from TOML, the pointer must point to a value"
+	}
+	return e.Type.String() + " cannot be unmarshaled from TOML, only a pointer to struct or map can"
+}
+
+// DecodeErrors is returned by a Decoder set up with CollectErrors when some
+// values cannot be decoded. It lists the errors in the order of the fields.
+type DecodeErrors []error
+
+func (e DecodeErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msg := fmt.Sprintf("%d errors while decoding:", len(e))
+	for _, err := range e {
+		msg += "\n" + err.Error()
+	}
+	return msg
+}
+
+// OverflowPolicy defines how the Decoder handles integers that do not fit in
+// the integer type of their field.
+type OverflowPolicy int
+
+// Overflow policies of the Decoder.
+const (
+	// Fail the decoding.
+	OverflowError OverflowPolicy = iota
+	// Store the closest value the field can hold, for example 127 for 300 in
+	// an int8.
+	OverflowSaturate
+	// Store the low-order bits of the integer, like a Go conversion does, for
+	// example 44 for 300 in an int8.
+	OverflowWrap
+)
+
+// TablePolicy defines how the Decoder fills pointers to structs, such as
+// *Server, for tables that are missing or empty.
+type TablePolicy int
+
+// Table policies of the Decoder.
+const (
+	// Leave the pointer nil for a missing table, and allocate it for a table
+	// in the document, even when it is empty.
+	TablePresent TablePolicy = iota
+	// Leave the pointer nil for an empty table too, as for a missing one.
+	TableNonEmpty
+	// Allocate the pointer for a missing table too, with the zero value and
+	// the default values of its fields.
+	TableAlways
+)
+
+// DuplicatePolicy defines how the Decoder handles keys defined twice in the
+// same table. A table defined twice is merged with its first definition,
+// except with DuplicateError.
+type DuplicatePolicy int
+
+// Duplicate policies of the Decoder.
+const (
+	// Fail the decoding, as the TOML specification requires.
+	DuplicateError DuplicatePolicy = iota
+	// Keep the first value of the key and ignore the others.
+	DuplicateKeepFirst
+	// Keep the last value of the key, replacing the previous ones.
+	DuplicateKeepLast
+)
+
 // Decoder reads and decodes TOML values from an input stream.
 type Decoder struct {
 	r    io.Reader
 	tval *Tree
 	encOpts
-	tagName string
-	strict  bool
-	visitor visitorState
+	tagName     string
+	fallbackTag string
+	fieldName   FieldNameMapper
+	keyMapper   func(key string) string
+	strict      bool
+	visitor     visitorState
+
+	disallowUnknown bool
+	recordUnused    bool
+
+	tokens   *tomlParser
+	tokenErr error
+
+	recordKeyOrder bool
+	keyOrder       map[string][]string
+
+	useNumber  bool
+	bigNumbers bool
+	overflow   OverflowPolicy
+	tables     TablePolicy
+	duplicates DuplicatePolicy
+
+	disallowNonFinite bool
+
+	limits      Limits
+	lenientUTF8 bool
+	input       *bufio.Reader // of r, when used
+	delimiter   string        // line separating the documents of the input
+
+	timeLayouts []string
+	hooks       []DecodeHook
+	decoders    map[reflect.Type]func(interface{}, reflect.Value) error
+	lookupEnv   func(name string) (string, bool)
+	readInclude func(path string) ([]byte, error)
+	missing     []string // required keys not found
+	overlay     bool
+
+	collectErrors bool
+	errs          DecodeErrors
+
+	deprecated     map[string]string // replacements of the deprecated keys
+	deprecatedKeys []DeprecatedKey
+	fieldPath      []string            // Go path of the value being decoded
+	fieldPositions map[string]Position // by Go path, when recorded
+
+	typeTrace bool
+	trace     []TypeTraceEvent
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -675,6 +1139,7 @@ func NewDecoder(r io.Reader) *Decoder {
 		r:       r,
 		encOpts: encOptsDefaults,
 		tagName: tagFieldName,
+		limits:  DefaultLimits(),
 	}
 }
 
@@ -684,19 +1149,125 @@ func NewDecoder(r io.Reader) *Decoder {
 // See the documentation for Marshal for details.
 func (d *Decoder) Decode(v interface{}) error {
 	var err error
-	d.tval, err = LoadReader(d.r)
+	d.tval, err = d.load()
 	if err != nil {
 		return err
 	}
 	return d.unmarshal(v)
 }
 
+// DecodePath reads a TOML-encoded value from it's input and unmarshals the
+// table at the given dot-separated key in the value pointed at by v. The rest
+// of the document is ignored, even in strict mode, which avoids declaring
+// types for all of it.
+//
+// An error is returned if the key does not designate a table.
+func (d *Decoder) DecodePath(key string, v interface{}) error {
+	keys, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	tree, err := d.load()
+	if err != nil {
+		return err
+	}
+	switch node := tree.GetPath(keys).(type) {
+	case *Tree:
+		d.tval = node
+	case nil:
+		return fmt.Errorf("key %s not found", key)
+	default:
+		return fmt.Errorf("%s: key %s is not a table", tree.GetPositionPath(keys), key)
+	}
+	return d.unmarshal(v)
+}
+
+// parseOptions returns the options of the parser of the input.
+func (d *Decoder) parseOptions() parseOptions {
+	return parseOptions{bigNumbers: d.bigNumbers, finiteFloats: d.disallowNonFinite, limits: d.limits, duplicates: d.duplicates}
+}
+
+// Read the input of the decoder.
+func (d *Decoder) load() (*Tree, error) {
+	b, err := d.readAll()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := loadBytes(b, d.parseOptions())
+	if err != nil || d.readInclude == nil {
+		return tree, err
+	}
+	r := &refResolver{
+		readFile: d.readInclude,
+		parse: func(b []byte) (*Tree, error) {
+			return loadBytes(b, d.parseOptions())
+		},
+	}
+	if err := r.resolve("", tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// Overlay makes Decode only change the values of the keys present in the
+// document, so that a document can be decoded over the values of another one,
+// such as defaults overridden by a local configuration:
+//
+//   toml.NewDecoder(base).Decode(&config)
+//   toml.NewDecoder(local).Overlay(true).Decode(&config)
+//
+// Structs, maps and pointers to them are updated instead of being replaced,
+// while other values, including arrays, are replaced. The default tag and the
+// required tag option are ignored, since the keys may come from a previous
+// document.
+func (d *Decoder) Overlay(overlay bool) *Decoder {
+	d.overlay = overlay
+	return d
+}
+
+// overlayTarget returns a copy of v to be updated by decoding in overlay
+// mode, or nil.
+func (d *Decoder) overlayTarget(v reflect.Value) *reflect.Value {
+	if !d.overlay || !v.IsValid() {
+		return nil
+	}
+	target := reflect.New(v.Type()).Elem()
+	target.Set(v)
+	return &target
+}
+
 // SetTagName allows changing default tag "toml"
 func (d *Decoder) SetTagName(v string) *Decoder {
 	d.tagName = v
 	return d
 }
 
+// SetFallbackTagName makes the decoder read the name and options of the
+// fields without a toml tag from the tag v, such as "json", so that structs
+// already annotated for another format need no toml tags.
+func (d *Decoder) SetFallbackTagName(v string) *Decoder {
+	d.fallbackTag = v
+	return d
+}
+
+// WithFieldNameMapper sets the function giving the keys of the struct fields
+// that have no name in their toml tag, instead of their Go name.
+func (d *Decoder) WithFieldNameMapper(fn FieldNameMapper) *Decoder {
+	d.fieldName = fn
+	return d
+}
+
+// WithKeyMapper sets a function applied to the keys of the document before
+// matching them to struct fields, so that documents following another naming
+// convention can be decoded into existing structs. A key matches a field when
+// the mapped key is the name of the field, ignoring case. For example
+// CamelCase maps the key http-port to httpPort, which matches the field
+// HTTPPort.
+func (d *Decoder) WithKeyMapper(fn func(key string) string) *Decoder {
+	d.keyMapper = fn
+	return d
+}
+
 // Strict allows changing to strict decoding. Any fields that are found in the
 // input data and do not have a corresponding struct member cause an error.
 func (d *Decoder) Strict(strict bool) *Decoder {
@@ -704,13 +1275,219 @@ func (d *Decoder) Strict(strict bool) *Decoder {
 	return d
 }
 
+// DisallowUnknownFields makes Decode fail when the document contains keys
+// that are not used to fill the target value, like Strict. The error lists
+// every such key with its position, in document order:
+//
+//   unknown keys:
+//   (3, 1): server.hostname
+//   (7, 3): clients.0.timeout
+//
+// Use UnusedKeys to get these keys after the error.
+func (d *Decoder) DisallowUnknownFields() *Decoder {
+	d.disallowUnknown = true
+	return d
+}
+
+// WithUnusedKeys makes the decoder record the keys of the document that are
+// not used to fill the target value, which are returned by UnusedKeys.
+func (d *Decoder) WithUnusedKeys() *Decoder {
+	d.recordUnused = true
+	return d
+}
+
+// UnusedKeys returns the keys of the document read by the last call to Decode
+// that were not used to fill the target value, sorted alphabetically. Keys are
+// dot-separated paths, elements of arrays of tables being designated by their
+// index (e.g. servers.0.host). The keys are only recorded with WithUnusedKeys,
+// Strict or DisallowUnknownFields.
+//
+// This helps finding settings that a program does not read anymore. Use
+// Strict to make such keys an error instead.
+func (d *Decoder) UnusedKeys() []string {
+	return d.visitor.unvisited()
+}
+
+// CollectErrors makes Decode go on after a value that cannot be decoded into
+// its field, and fail at the end with a DecodeErrors listing every such value
+// with its position:
+//
+//   3 errors while decoding:
+//   (2, 1): Can't convert x(string) to int
+//   (4, 1): Can't convert true(bool) to int
+//   missing required keys: ["database.dsn"]
+//
+// The values that could be decoded are stored in the target as usual.
+// Decoding stops after DefaultMaxErrors errors, see MaxErrors.
+func (d *Decoder) CollectErrors(collect bool) *Decoder {
+	d.collectErrors = collect
+	return d
+}
+
+// collect records err and returns nil when the decoder collects errors, and
+// returns err otherwise. Once MaxErrors errors are recorded, it returns them
+// as they are, which stops the decoding up to Decode.
+func (d *Decoder) collect(err error) error {
+	if !d.collectErrors {
+		return err
+	}
+	if d.limits.MaxErrors > 0 && len(d.errs) >= d.limits.MaxErrors {
+		return d.errs
+	}
+	d.errs = append(d.errs, err)
+	if d.limits.MaxErrors > 0 && len(d.errs) >= d.limits.MaxErrors {
+		return d.errs
+	}
+	return nil
+}
+
+// BigNumbers makes the decoder accept integers that do not fit in an int64
+// and floats that do not fit in a float64, which the TOML specification
+// rejects otherwise. They are stored as *big.Int and *big.Float in interface{}
+// targets, and accepted by fields of type big.Int, big.Float and Number, but
+// rejected by other types.
+func (d *Decoder) BigNumbers() *Decoder {
+	d.bigNumbers = true
+	return d
+}
+
+// UseNumber makes the decoder store integers and floats as Number instead of
+// int64 and float64 values in interface{} targets, including the values of
+// map[string]interface{}.
+func (d *Decoder) UseNumber() *Decoder {
+	d.useNumber = true
+	return d
+}
+
+// IntegerOverflow sets what the decoder does with integers that do not fit
+// in the integer field they are decoded into. The default is OverflowError.
+// Integers that do not fit in an int64 are only accepted with BigNumbers.
+func (d *Decoder) IntegerOverflow(policy OverflowPolicy) *Decoder {
+	d.overflow = policy
+	return d
+}
+
+// PointerTables sets when the decoder allocates the pointers to structs of
+// tables. The default is TablePresent, which tells a missing table from an
+// empty one.
+func (d *Decoder) PointerTables(policy TablePolicy) *Decoder {
+	d.tables = policy
+	return d
+}
+
+// DuplicateKeys sets what the decoder does with keys and tables defined more
+// than once, which machine-generated documents sometimes contain. The default
+// is DuplicateError.
+func (d *Decoder) DuplicateKeys(policy DuplicatePolicy) *Decoder {
+	d.duplicates = policy
+	return d
+}
+
+// DisallowNonFinite makes Decode fail when the document contains nan, +inf or
+// -inf floats, which TOML allows but most configurations consider invalid.
+// The error gives the position of the first such float.
+func (d *Decoder) DisallowNonFinite() *Decoder {
+	d.disallowNonFinite = true
+	return d
+}
+
+// TimeLayoutUnix is a layout for TimeLayouts accepting integers of seconds
+// since the Unix epoch, decoded as UTC times.
+const TimeLayoutUnix = "unix"
+
+// TimeLayouts makes the decoder accept strings in the given layouts, in the
+// format of time.Parse, for fields of type time.Time. Layouts are tried in
+// order. TimeLayoutUnix accepts integer timestamps instead. This allows
+// reading timestamps of legacy files that are not TOML date-times:
+//
+//   d.TimeLayouts(time.RFC1123, toml.TimeLayoutUnix)
+func (d *Decoder) TimeLayouts(layouts ...string) *Decoder {
+	d.timeLayouts = append(d.timeLayouts, layouts...)
+	return d
+}
+
+// WithKeyOrder makes the decoder record the order in which keys appear in
+// the tables decoded into maps, which iterate in random order. The order is
+// returned by KeyOrder.
+func (d *Decoder) WithKeyOrder() *Decoder {
+	d.recordKeyOrder = true
+	return d
+}
+
+// KeyOrder returns the keys of the tables decoded into maps by the last call
+// to Decode, in document order. Tables are designated by dot-separated paths
+// as in UnusedKeys, the root table being "". For example:
+//
+//   var m map[string]interface{}
+//   d := toml.NewDecoder(r).WithKeyOrder()
+//   err := d.Decode(&m)
+//   for _, key := range d.KeyOrder()["server"] {
+//     fmt.Println(key, m["server"].(map[string]interface{})[key])
+//   }
+func (d *Decoder) KeyOrder() map[string][]string {
+	return d.keyOrder
+}
+
+// DecodeMetadata describes how the keys of a document were used by Unmarshal.
+// Keys are named as in Decoder.UnusedKeys, and sorted alphabetically.
+type DecodeMetadata struct {
+	Decoded   []string          // keys used to fill the target value
+	Undecoded []string          // keys present in the document but not used
+	Types     map[string]string // TOML type of each key, e.g. "integer"
+
+	// Position in the document of each decoded struct field, map entry and
+	// slice element, by Go path such as "Server.Port" or "Users.0.Name".
+	// This allows applications to report where invalid settings are.
+	FieldPositions map[string]Position
+}
+
+// UnmarshalWithMetadata is like Unmarshal, and also returns which keys of
+// the document were decoded. This is useful to warn about settings that are
+// not recognized.
+func UnmarshalWithMetadata(data []byte, v interface{}) (DecodeMetadata, error) {
+	t, err := LoadReader(bytes.NewReader(data))
+	if err != nil {
+		return DecodeMetadata{}, err
+	}
+	d := Decoder{tval: t, tagName: tagFieldName, recordUnused: true, fieldPositions: map[string]Position{}}
+	if err := d.unmarshal(v); err != nil {
+		return DecodeMetadata{}, err
+	}
+	md := DecodeMetadata{
+		Undecoded:      d.visitor.unvisited(),
+		Types:          map[string]string{},
+		FieldPositions: d.fieldPositions,
+	}
+	insertKeyTypes(nil, md.Types, t)
+	for key := range md.Types {
+		if _, undecoded := d.visitor.keys[key]; !undecoded {
+			md.Decoded = append(md.Decoded, key)
+		}
+	}
+	sort.Strings(md.Decoded)
+	return md, nil
+}
+
+// UnmarshalFlat parses the TOML-encoded data and returns its values by dotted
+// key, such as "server.http.port", with the parts of keys quoted as in TOML
+// when needed, such as `site."example.com".port`. The tables of arrays of tables are
+// numbered from 0, as in "products.0.name". Values are converted as ToMap
+// does. This is useful to compare documents or to export them to key/value
+// stores or environment variables.
+func UnmarshalFlat(data []byte) (map[string]interface{}, error) {
+	t, err := LoadReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	insertFlatValues(nil, m, t)
+	return m, nil
+}
+
 func (d *Decoder) unmarshal(v interface{}) error {
 	mtype := reflect.TypeOf(v)
-	if mtype == nil {
-		return errors.New("nil cannot be unmarshaled from TOML")
-	}
-	if mtype.Kind() != reflect.Ptr {
-		return errors.New("only a pointer to struct or map can be unmarshaled from TOML")
+	if mtype == nil || mtype.Kind() != reflect.Ptr {
+		return &InvalidUnmarshalError{mtype}
 	}
 
 	elem := mtype.Elem()
@@ -720,25 +1497,49 @@ func (d *Decoder) unmarshal(v interface{}) error {
 	case reflect.Interface:
 		elem = mapStringInterfaceType
 	default:
-		return errors.New("only a pointer to struct or map can be unmarshaled from TOML")
+		return &InvalidUnmarshalError{mtype}
 	}
 
 	if reflect.ValueOf(v).IsNil() {
-		return errors.New("nil pointer cannot be unmarshaled from TOML")
+		return &InvalidUnmarshalError{mtype}
 	}
 
 	vv := reflect.ValueOf(v).Elem()
 
-	if d.strict {
-		d.visitor = newVisitorState(d.tval)
+	d.visitor = newVisitorState(d.tval, d.recordUnused || d.strict || d.disallowUnknown)
+	d.trace = nil
+	d.missing = nil
+	d.errs = nil
+	d.deprecatedKeys = nil
+	if d.deprecated != nil {
+		d.deprecatedKeys = findDeprecatedKeys(d.tval, d.deprecated)
+	}
+	d.keyOrder = nil
+	if d.recordKeyOrder {
+		d.keyOrder = map[string][]string{}
 	}
 
 	sval, err := d.valueFromTree(elem, d.tval, &vv)
 	if err != nil {
 		return err
 	}
-	if err := d.visitor.validate(); err != nil {
-		return err
+	if len(d.missing) > 0 {
+		if err := d.collect(fmt.Errorf("missing required keys: %q", d.missing)); err != nil {
+			return err
+		}
+	}
+	if len(d.errs) > 0 {
+		reflect.ValueOf(v).Elem().Set(sval)
+		return d.errs
+	}
+	if d.disallowUnknown {
+		if err := d.visitor.validatePositions(); err != nil {
+			return err
+		}
+	} else if d.strict {
+		if err := d.visitor.validate(); err != nil {
+			return err
+		}
 	}
 	reflect.ValueOf(v).Elem().Set(sval)
 	return nil
@@ -759,7 +1560,9 @@ func (d *Decoder) valueFromTree(mtype reflect.Type, tval *Tree, mval1 *reflect.V
 			return mvalPtr.Elem(), nil
 		}
 
-		if err := callCustomUnmarshaler(mvalPtr, tval.ToMap()); err != nil {
+		err := callCustomUnmarshaler(mvalPtr, tval.ToMap())
+		d.traceType(d.visitor.path, tval, mtype, ConversionUnmarshaler, err)
+		if err != nil {
 			return reflect.ValueOf(nil), fmt.Errorf("unmarshal toml: %v", err)
 		}
 		return mvalPtr.Elem(), nil
@@ -778,9 +1581,16 @@ func (d *Decoder) valueFromTree(mtype reflect.Type, tval *Tree, mval1 *reflect.V
 		case Tree:
 			mval.Set(reflect.ValueOf(tval).Elem())
 		default:
+			var mappedKeys map[string]string
+			if d.keyMapper != nil && tval != nil {
+				mappedKeys = make(map[string]string)
+				for _, key := range tval.Keys() {
+					mappedKeys[strings.ToLower(d.keyMapper(key))] = key
+				}
+			}
 			for i := 0; i < mtype.NumField(); i++ {
 				mtypef := mtype.Field(i)
-				an := annotation{tag: d.tagName}
+				an := annotation{tag: d.tagName, fallbackTag: d.fallbackTag, fieldName: d.fieldName}
 				opts := tomlOptions(mtypef, an)
 				if !opts.include {
 					continue
@@ -792,6 +1602,9 @@ func (d *Decoder) valueFromTree(mtype reflect.Type, tval *Tree, mval1 *reflect.V
 					strings.ToTitle(baseKey),
 					strings.ToLower(string(baseKey[0])) + baseKey[1:],
 				}
+				if mapped, ok := mappedKeys[strings.ToLower(baseKey)]; ok {
+					keysToTry = append(keysToTry, mapped)
+				}
 
 				found := false
 				if tval != nil {
@@ -802,70 +1615,43 @@ func (d *Decoder) valueFromTree(mtype reflect.Type, tval *Tree, mval1 *reflect.V
 						}
 
 						d.visitor.push(key)
-						val := tval.GetPath([]string{key})
+						val := d.withNumberLiteral(mtypef.Type, tval, key, withLiteral(mtypef.Type, tval, key, tval.GetPath([]string{key})))
+						if tree, ok := val.(*Tree); ok && d.tables == TableNonEmpty && isStructPointer(mtypef.Type) && len(tree.values) == 0 {
+							found = true
+							d.visitor.pop()
+							break
+						}
 						fval := mval.Field(i)
-						mvalf, err := d.valueFromToml(mtypef.Type, val, &fval)
+						var mvalf reflect.Value
+						var err error
+						d.pushField(mtypef.Name, keyPosition(tval, key))
+						if trees, ok := val.([]*Tree); ok && opts.keyedBy != "" && mtypef.Type.Kind() == reflect.Map {
+							mvalf, err = d.valueFromKeyedTrees(mtypef.Type, trees, opts.keyedBy)
+						} else {
+							mvalf, err = d.valueFromToml(mtypef.Type, val, &fval)
+						}
 						if err != nil {
-							return mval, formatError(err, tval.GetPositionPath([]string{key}))
+							if err := d.collect(formatError(err, tval.GetPositionPath([]string{key}))); err != nil {
+								return mval, err
+							}
+						} else {
+							mval.Field(i).Set(mvalf)
 						}
-						mval.Field(i).Set(mvalf)
+						d.popField()
 						found = true
 						d.visitor.pop()
 						break
 					}
 				}
 
-				if !found && opts.defaultValue != "" {
-					mvalf := mval.Field(i)
-					var val interface{}
-					var err error
-					switch mvalf.Kind() {
-					case reflect.String:
-						val = opts.defaultValue
-					case reflect.Bool:
-						val, err = strconv.ParseBool(opts.defaultValue)
-					case reflect.Uint:
-						val, err = strconv.ParseUint(opts.defaultValue, 10, 0)
-					case reflect.Uint8:
-						val, err = strconv.ParseUint(opts.defaultValue, 10, 8)
-					case reflect.Uint16:
-						val, err = strconv.ParseUint(opts.defaultValue, 10, 16)
-					case reflect.Uint32:
-						val, err = strconv.ParseUint(opts.defaultValue, 10, 32)
-					case reflect.Uint64:
-						val, err = strconv.ParseUint(opts.defaultValue, 10, 64)
-					case reflect.Int:
-						val, err = strconv.ParseInt(opts.defaultValue, 10, 0)
-					case reflect.Int8:
-						val, err = strconv.ParseInt(opts.defaultValue, 10, 8)
-					case reflect.Int16:
-						val, err = strconv.ParseInt(opts.defaultValue, 10, 16)
-					case reflect.Int32:
-						val, err = strconv.ParseInt(opts.defaultValue, 10, 32)
-					case reflect.Int64:
-						// Check if the provided number has a non-numeric extension.
-						var hasExtension bool
-						if len(opts.defaultValue) > 0 {
-							lastChar := opts.defaultValue[len(opts.defaultValue)-1]
-							if lastChar < '0' || lastChar > '9' {
-								hasExtension = true
-							}
-						}
-						// If the value is a time.Duration with extension, parse as duration.
-						// If the value is an int64 or a time.Duration without extension, parse as number.
-						if hasExtension && mvalf.Type().String() == "time.Duration" {
-							val, err = time.ParseDuration(opts.defaultValue)
-						} else {
-							val, err = strconv.ParseInt(opts.defaultValue, 10, 64)
-						}
-					case reflect.Float32:
-						val, err = strconv.ParseFloat(opts.defaultValue, 32)
-					case reflect.Float64:
-						val, err = strconv.ParseFloat(opts.defaultValue, 64)
-					default:
-						return mvalf, fmt.Errorf("unsupported field type for default option")
-					}
+				if !found && opts.required && !d.overlay {
+					d.missing = append(d.missing, strings.Join(append(d.visitor.path[:len(d.visitor.path):len(d.visitor.path)], opts.name), "."))
+				}
 
+				if !found && opts.defaultValue != "" && !d.overlay {
+					mvalf := mval.Field(i)
+					val, err := parseDefaultValue(mvalf.Type(), opts.defaultValue)
+					d.traceType(append(d.visitor.path[:len(d.visitor.path):len(d.visitor.path)], opts.name), nil, mvalf.Type(), ConversionDefault, err)
 					if err != nil {
 						return mvalf, err
 					}
@@ -877,33 +1663,173 @@ func (d *Decoder) valueFromTree(mtype reflect.Type, tval *Tree, mval1 *reflect.V
 					tmpTval := tval
 					if !mtypef.Anonymous {
 						tmpTval = nil
+						d.visitor.push(opts.name)
 					}
 					fval := mval.Field(i)
 					v, err := d.valueFromTree(mtypef.Type, tmpTval, &fval)
 					if err != nil {
 						return v, err
 					}
+					if !mtypef.Anonymous {
+						d.visitor.pop()
+					}
 					mval.Field(i).Set(v)
 				}
+
+				if !found && d.tables == TableAlways && isStructPointer(mtypef.Type) && mval.Field(i).IsNil() {
+					d.visitor.push(opts.name)
+					v, err := d.valueFromTree(mtypef.Type.Elem(), nil, nil)
+					if err != nil {
+						return v, err
+					}
+					d.visitor.pop()
+					ptr := reflect.New(mtypef.Type.Elem())
+					ptr.Elem().Set(v)
+					mval.Field(i).Set(ptr)
+				}
 			}
 		}
 	case reflect.Map:
-		mval = reflect.MakeMap(mtype)
+		if d.overlay && mval1 != nil && !mval1.IsNil() {
+			mval = *mval1
+		} else {
+			mval = reflect.MakeMap(mtype)
+		}
+		if d.keyOrder != nil {
+			d.keyOrder[strings.Join(d.visitor.path, ".")] = keysInDocumentOrder(tval)
+		}
 		for _, key := range tval.Keys() {
 			d.visitor.push(key)
+			mkey, err := mapKeyFromString(mtype.Key(), key)
+			if err != nil {
+				if err := d.collect(formatError(err, tval.GetPositionPath([]string{key}))); err != nil {
+					return mval, err
+				}
+				d.visitor.pop()
+				continue
+			}
 			// TODO: path splits key
-			val := tval.GetPath([]string{key})
-			mvalf, err := d.valueFromToml(mtype.Elem(), val, nil)
+			val := d.withNumberLiteral(mtype.Elem(), tval, key, withLiteral(mtype.Elem(), tval, key, tval.GetPath([]string{key})))
+			d.pushField(key, keyPosition(tval, key))
+			mvalf, err := d.valueFromToml(mtype.Elem(), val, d.overlayTarget(mval.MapIndex(mkey)))
 			if err != nil {
-				return mval, formatError(err, tval.GetPositionPath([]string{key}))
+				if err := d.collect(formatError(err, tval.GetPositionPath([]string{key}))); err != nil {
+					return mval, err
+				}
+			} else {
+				mval.SetMapIndex(mkey, mvalf)
 			}
-			mval.SetMapIndex(reflect.ValueOf(key).Convert(mtype.Key()), mvalf)
+			d.popField()
 			d.visitor.pop()
 		}
 	}
 	return mval, nil
 }
 
+// Enter the value at the given Go path element, and record its position when
+// field positions are recorded.
+func (d *Decoder) pushField(name string, pos Position) {
+	if d.fieldPositions == nil {
+		return
+	}
+	d.fieldPath = append(d.fieldPath, name)
+	d.fieldPositions[strings.Join(d.fieldPath, ".")] = pos
+}
+
+func (d *Decoder) popField() {
+	if d.fieldPositions != nil {
+		d.fieldPath = d.fieldPath[:len(d.fieldPath)-1]
+	}
+}
+
+// Return the position of the value of key in t, which is the one of the
+// first table for arrays of tables.
+func keyPosition(t *Tree, key string) Position {
+	if trees, ok := t.values[key].([]*Tree); ok && len(trees) > 0 {
+		return trees[0].position
+	}
+	return t.GetPositionPath([]string{key})
+}
+
+// Return the keys of t sorted by position.
+func keysInDocumentOrder(t *Tree) []string {
+	keys := t.Keys()
+	positions := make(map[string]Position, len(keys))
+	for _, k := range keys {
+		switch node := t.values[k].(type) {
+		case *tomlValue:
+			positions[k] = node.position
+		case *Tree:
+			positions[k] = node.position
+		case []*Tree:
+			if len(node) > 0 {
+				positions[k] = node[0].position
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := positions[keys[i]], positions[keys[j]]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Col != b.Col {
+			return a.Col < b.Col
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// Convert a table key to a map key of type mtype. Key types implementing
+// encoding.TextUnmarshaler parse the key themselves.
+func mapKeyFromString(mtype reflect.Type, key string) (reflect.Value, error) {
+	if mkeyPtr := reflect.New(mtype); isTextUnmarshaler(mkeyPtr.Type()) {
+		if err := callTextUnmarshaler(mkeyPtr, []byte(key)); err != nil {
+			return reflect.ValueOf(nil), fmt.Errorf("unmarshal text: %v", err)
+		}
+		return mkeyPtr.Elem(), nil
+	}
+	switch mtype.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(mtype), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, mtype.Bits())
+		if err != nil {
+			return reflect.ValueOf(nil), fmt.Errorf("Can't convert key %q to %v", key, mtype)
+		}
+		return reflect.ValueOf(n).Convert(mtype), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, mtype.Bits())
+		if err != nil {
+			return reflect.ValueOf(nil), fmt.Errorf("Can't convert key %q to %v", key, mtype)
+		}
+		return reflect.ValueOf(n).Convert(mtype), nil
+	}
+	return reflect.ValueOf(nil), fmt.Errorf("Can't convert key %q to %v", key, mtype)
+}
+
+// shuffleKeys puts keys in a pseudo-random order which only depends on
+// their string representations and seed.
+func shuffleKeys(keys []reflect.Value, seed int64) {
+	sort.Slice(keys, func(i, j int) bool {
+		return mapKeyToString(keys[i]) < mapKeyToString(keys[j])
+	})
+	rand.New(rand.NewSource(seed)).Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+}
+
+// Return the TOML key of a map key: integers are written in decimal.
+func mapKeyToString(key reflect.Value) string {
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10)
+	}
+	return key.String()
+}
+
 // Convert toml value to marshal struct/map slice, using marshal type
 func (d *Decoder) valueFromTreeSlice(mtype reflect.Type, tval []*Tree) (reflect.Value, error) {
 	mval, err := makeSliceOrArray(mtype, len(tval))
@@ -913,11 +1839,50 @@ func (d *Decoder) valueFromTreeSlice(mtype reflect.Type, tval []*Tree) (reflect.
 
 	for i := 0; i < len(tval); i++ {
 		d.visitor.push(strconv.Itoa(i))
+		d.pushField(strconv.Itoa(i), tval[i].position)
 		val, err := d.valueFromTree(mtype.Elem(), tval[i], nil)
 		if err != nil {
 			return mval, err
 		}
 		mval.Index(i).Set(val)
+		d.popField()
+		d.visitor.pop()
+	}
+	return mval, nil
+}
+
+// Convert an array of tables to a map, indexed by the value of the given key
+// of each table, for fields with the keyed tag option.
+func (d *Decoder) valueFromKeyedTrees(mtype reflect.Type, tval []*Tree, keyedBy string) (reflect.Value, error) {
+	mval := reflect.MakeMap(mtype)
+	for i, tree := range tval {
+		d.visitor.push(strconv.Itoa(i))
+		tv, _ := tree.values[keyedBy].(*tomlValue)
+		if tv == nil {
+			return mval, fmt.Errorf("%s: table has no %s key", tree.position, keyedBy)
+		}
+		name, ok := tv.value.(string)
+		if !ok {
+			return mval, fmt.Errorf("%s: key %s must be a string, not %T", tv.position, keyedBy, tv.value)
+		}
+		d.visitor.push(keyedBy)
+		d.visitor.visit()
+		d.visitor.pop()
+
+		mkey, err := mapKeyFromString(mtype.Key(), name)
+		if err != nil {
+			return mval, formatError(err, tv.position)
+		}
+		if mval.MapIndex(mkey).IsValid() {
+			return mval, fmt.Errorf("%s: duplicate %s %q", tv.position, keyedBy, name)
+		}
+		d.pushField(name, tree.position)
+		val, err := d.valueFromToml(mtype.Elem(), tree, nil)
+		if err != nil {
+			return mval, err
+		}
+		d.popField()
+		mval.SetMapIndex(mkey, val)
 		d.visitor.pop()
 	}
 	return mval, nil
@@ -977,15 +1942,57 @@ func makeSliceOrArray(mtype reflect.Type, tLength int) (reflect.Value, error) {
 
 // Convert toml value to marshal value, using marshal type. When mval1 is non-nil
 // and the given type is a struct value, merge fields into it.
-func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *reflect.Value) (reflect.Value, error) {
+func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *reflect.Value) (mval reflect.Value, err error) {
+	if val, ok, err := d.registeredDecoder(mtype, tval); ok {
+		if d.typeTrace {
+			d.traceType(d.visitor.path, tval, mtype, ConversionDecoder, err)
+		}
+		return val, err
+	}
+
 	if mtype.Kind() == reflect.Ptr {
 		return d.unwrapPointer(mtype, tval, mval1)
 	}
 
+	if len(d.hooks) > 0 {
+		next, val, ok, err := d.applyHooks(mtype, tval)
+		if err != nil || ok {
+			if d.typeTrace {
+				d.traceType(d.visitor.path, tval, mtype, ConversionHook, err)
+			}
+			return val, err
+		}
+		tval = next
+	}
+
+	// json.RawMessage fields receive the value encoded as JSON
+	if mtype == jsonRawMessageType {
+		d.visitor.visitAll()
+		b, err := json.Marshal(tomlValueToGo(tval))
+		if err != nil {
+			return reflect.ValueOf(nil), fmt.Errorf("unmarshal json: %v", err)
+		}
+		return reflect.ValueOf(json.RawMessage(b)), nil
+	}
+
+	// Unmarshaler types decode arrays too, as with BurntSushi/toml
+	switch tval.(type) {
+	case []*Tree, []interface{}:
+		if mvalPtr := reflect.New(mtype); isCustomUnmarshaler(mvalPtr.Type()) {
+			d.visitor.visitAll()
+			err := callCustomUnmarshaler(mvalPtr, tomlValueToGo(tval))
+			d.traceType(d.visitor.path, tval, mtype, ConversionUnmarshaler, err)
+			if err != nil {
+				return reflect.ValueOf(nil), fmt.Errorf("unmarshal toml: %v", err)
+			}
+			return mvalPtr.Elem(), nil
+		}
+	}
+
 	switch t := tval.(type) {
 	case *Tree:
 		var mval11 *reflect.Value
-		if mtype.Kind() == reflect.Struct {
+		if mtype.Kind() == reflect.Struct || d.overlay && mtype.Kind() == reflect.Map {
 			mval11 = mval1
 		}
 
@@ -993,11 +2000,15 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 			return d.valueFromTree(mtype, t, mval11)
 		}
 
+		if name, ok := t.Get(TypeKey).(string); ok && mtype.Kind() == reflect.Interface && mtype.NumMethod() > 0 {
+			return d.valueFromTypedTree(mtype, t, name)
+		}
+
 		if mtype.Kind() == reflect.Interface {
 			if mval1 == nil || mval1.IsNil() {
 				return d.valueFromTree(reflect.TypeOf(map[string]interface{}{}), t, nil)
 			} else {
-				return d.valueFromToml(mval1.Elem().Type(), t, nil)
+				return d.valueFromToml(mval1.Elem().Type(), t, d.overlayTarget(mval1.Elem()))
 			}
 		}
 
@@ -1017,7 +2028,7 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 		return reflect.ValueOf(nil), fmt.Errorf("Can't convert %v(%T) to trees", tval, tval)
 	case []interface{}:
 		d.visitor.visit()
-		if isOtherSequence(mtype) {
+		if isOtherSequence(mtype) || isCustomUnmarshalerSequence(mtype) || isTextUnmarshalerSequence(mtype) || isLocalTypeSequence(mtype) || d.isDecoderSequence(mtype) {
 			return d.valueFromOtherSlice(mtype, t)
 		}
 		if mtype.Kind() == reflect.Interface {
@@ -1033,8 +2044,25 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 		d.visitor.visit()
 		mvalPtr := reflect.New(mtype)
 
+		if s, ok := tval.(string); ok && d.lookupEnv != nil {
+			if tval, err = expandEnv(s, d.lookupEnv); err != nil {
+				return reflect.ValueOf(nil), err
+			}
+		}
+
+		conversion := ConversionConvert
+		if d.typeTrace {
+			defer func() { d.traceType(d.visitor.path, tval, mtype, conversion, err) }()
+		}
+
+		if val, ok := convertLocal(tval, mtype); ok {
+			conversion = ConversionLocalType
+			return val, nil
+		}
+
 		// Check if pointer to value implements the Unmarshaler interface.
 		if isCustomUnmarshaler(mvalPtr.Type()) {
+			conversion = ConversionUnmarshaler
 			if err := callCustomUnmarshaler(mvalPtr, tval); err != nil {
 				return reflect.ValueOf(nil), fmt.Errorf("unmarshal toml: %v", err)
 			}
@@ -1043,6 +2071,7 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 
 		// Check if pointer to value implements the encoding.TextUnmarshaler.
 		if isTextUnmarshaler(mvalPtr.Type()) && !isTimeType(mtype) {
+			conversion = ConversionTextUnmarshaler
 			if err := d.unmarshalText(tval, mvalPtr); err != nil {
 				return reflect.ValueOf(nil), fmt.Errorf("unmarshal text: %v", err)
 			}
@@ -1058,12 +2087,14 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 				localDate := val.Interface().(LocalDate)
 				switch mtype {
 				case timeType:
+					conversion = ConversionLocalTime
 					return reflect.ValueOf(time.Date(localDate.Year, localDate.Month, localDate.Day, 0, 0, 0, 0, time.Local)), nil
 				}
 			case localDateTimeType:
 				localDateTime := val.Interface().(LocalDateTime)
 				switch mtype {
 				case timeType:
+					conversion = ConversionLocalTime
 					return reflect.ValueOf(time.Date(
 						localDateTime.Date.Year,
 						localDateTime.Date.Month,
@@ -1076,6 +2107,13 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 				}
 			}
 
+			if mtype == timeType && len(d.timeLayouts) > 0 {
+				if tm, ok, err := d.timeFromLayouts(tval); ok {
+					conversion = ConversionTimeLayout
+					return tm, err
+				}
+			}
+
 			// if this passes for when mtype is reflect.Struct, tval is a time.LocalTime
 			if !val.Type().ConvertibleTo(mtype) {
 				return reflect.ValueOf(nil), fmt.Errorf("Can't convert %v(%T) to %v", tval, tval, mtype.String())
@@ -1083,6 +2121,13 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 
 			return val.Convert(mtype), nil
 		case reflect.String:
+			if mtype == numberType {
+				n, ok := toNumber(tval)
+				if !ok {
+					return reflect.ValueOf(nil), fmt.Errorf("Can't convert %v(%T) to %v", tval, tval, mtype.String())
+				}
+				return reflect.ValueOf(n), nil
+			}
 			val := reflect.ValueOf(tval)
 			// stupidly, int64 is convertible to string. So special case this.
 			if !val.Type().ConvertibleTo(mtype) || val.Kind() == reflect.Int64 {
@@ -1093,12 +2138,16 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 			val := reflect.ValueOf(tval)
 			if mtype.Kind() == reflect.Int64 && mtype == reflect.TypeOf(time.Duration(1)) && val.Kind() == reflect.String {
+				conversion = ConversionDuration
 				d, err := time.ParseDuration(val.String())
 				if err != nil {
 					return reflect.ValueOf(nil), fmt.Errorf("Can't convert %v(%T) to %v. %s", tval, tval, mtype.String(), err)
 				}
 				return reflect.ValueOf(d), nil
 			}
+			if v, ok := d.overflowInteger(mtype, tval); ok {
+				return v, nil
+			}
 			if !val.Type().ConvertibleTo(mtype) || val.Kind() == reflect.Float64 {
 				return reflect.ValueOf(nil), fmt.Errorf("Can't convert %v(%T) to %v", tval, tval, mtype.String())
 			}
@@ -1108,6 +2157,9 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 
 			return val.Convert(mtype), nil
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if v, ok := d.overflowInteger(mtype, tval); ok {
+				return v, nil
+			}
 			val := reflect.ValueOf(tval)
 			if !val.Type().ConvertibleTo(mtype) || val.Kind() == reflect.Float64 {
 				return reflect.ValueOf(nil), fmt.Errorf("Can't convert %v(%T) to %v", tval, tval, mtype.String())
@@ -1133,13 +2185,30 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 			return val.Convert(mtype), nil
 		case reflect.Interface:
 			if mval1 == nil || mval1.IsNil() {
+				conversion = ConversionInterface
+				if n, ok := toNumber(tval); ok && d.useNumber {
+					return reflect.ValueOf(n), nil
+				}
+				switch tval.(type) {
+				case *big.Int:
+					if !d.bigNumbers {
+						return reflect.ValueOf(nil), fmt.Errorf("%v(%T) would overflow int64", tval, tval)
+					}
+				case *big.Float:
+					if !d.bigNumbers {
+						return reflect.ValueOf(nil), fmt.Errorf("%v(%T) would overflow float64", tval, tval)
+					}
+				}
 				return reflect.ValueOf(tval), nil
 			} else {
+				// traced by the recursive call
+				conversion = ""
 				ival := mval1.Elem()
 				return d.valueFromToml(mval1.Elem().Type(), t, &ival)
 			}
 		case reflect.Slice, reflect.Array:
 			if isOtherSequence(mtype) && isOtherSequence(reflect.TypeOf(t)) {
+				conversion = ""
 				return d.valueFromOtherSliceI(mtype, t)
 			}
 			return reflect.ValueOf(nil), fmt.Errorf("Can't convert %v(%T) to %v(%v)", tval, tval, mtype, mtype.Kind())
@@ -1149,10 +2218,85 @@ func (d *Decoder) valueFromToml(mtype reflect.Type, tval interface{}, mval1 *ref
 	}
 }
 
+// timeFromLayouts parses tval with the layouts set by TimeLayouts. The
+// boolean is false if none of the layouts applies to the type of tval.
+func (d *Decoder) timeFromLayouts(tval interface{}) (reflect.Value, bool, error) {
+	switch v := tval.(type) {
+	case string:
+		var layouts []string
+		for _, layout := range d.timeLayouts {
+			if layout == TimeLayoutUnix {
+				continue
+			}
+			if t, err := time.Parse(layout, v); err == nil {
+				return reflect.ValueOf(t), true, nil
+			}
+			layouts = append(layouts, layout)
+		}
+		if len(layouts) == 0 {
+			return reflect.Value{}, false, nil
+		}
+		return reflect.ValueOf(nil), true, fmt.Errorf("Can't parse %q as a time with layouts %q", v, layouts)
+	case int64:
+		for _, layout := range d.timeLayouts {
+			if layout == TimeLayoutUnix {
+				return reflect.ValueOf(time.Unix(v, 0).UTC()), true, nil
+			}
+		}
+	}
+	return reflect.Value{}, false, nil
+}
+
+var maxUint64 = new(big.Int).SetUint64(^uint64(0))
+
+// overflowInteger converts the integer tval to the integer type mtype
+// according to the overflow policy, if tval does not fit in mtype and the
+// policy is not OverflowError.
+func (d *Decoder) overflowInteger(mtype reflect.Type, tval interface{}) (reflect.Value, bool) {
+	if d.overflow == OverflowError {
+		return reflect.Value{}, false
+	}
+	var x *big.Int
+	switch v := tval.(type) {
+	case int64:
+		x = big.NewInt(v)
+	case *big.Int:
+		x = v
+	default:
+		return reflect.Value{}, false
+	}
+
+	bits := uint(mtype.Bits())
+	min, max := new(big.Int), new(big.Int)
+	switch mtype.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		min.Lsh(big.NewInt(-1), bits-1)
+		max.Lsh(big.NewInt(1), bits-1).Sub(max, big.NewInt(1))
+	default:
+		max.Lsh(big.NewInt(1), bits).Sub(max, big.NewInt(1))
+	}
+	switch {
+	case x.Cmp(min) < 0:
+		if d.overflow == OverflowSaturate {
+			x = min
+		}
+	case x.Cmp(max) > 0:
+		if d.overflow == OverflowSaturate {
+			x = max
+		}
+	default:
+		return reflect.Value{}, false
+	}
+	// big.Int.And works on the two's complement of negative numbers, and the
+	// conversion of the uint64 to mtype keeps its low-order bits.
+	low := new(big.Int).And(x, maxUint64).Uint64()
+	return reflect.ValueOf(low).Convert(mtype), true
+}
+
 func (d *Decoder) unwrapPointer(mtype reflect.Type, tval interface{}, mval1 *reflect.Value) (reflect.Value, error) {
 	var melem *reflect.Value
 
-	if mval1 != nil && !mval1.IsNil() && (mtype.Elem().Kind() == reflect.Struct || mtype.Elem().Kind() == reflect.Interface) {
+	if mval1 != nil && !mval1.IsNil() && (mtype.Elem().Kind() == reflect.Struct || mtype.Elem().Kind() == reflect.Interface || d.overlay && mtype.Elem().Kind() == reflect.Map) {
 		elem := mval1.Elem()
 		melem = &elem
 	}
@@ -1172,8 +2316,62 @@ func (d *Decoder) unmarshalText(tval interface{}, mval reflect.Value) error {
 	return callTextUnmarshaler(mval, buf.Bytes())
 }
 
+// Parse the value of a default tag for a field of type mtype.
+func parseDefaultValue(mtype reflect.Type, s string) (val interface{}, err error) {
+	switch mtype.Kind() {
+	case reflect.String:
+		val = s
+	case reflect.Bool:
+		val, err = strconv.ParseBool(s)
+	case reflect.Uint:
+		val, err = strconv.ParseUint(s, 10, 0)
+	case reflect.Uint8:
+		val, err = strconv.ParseUint(s, 10, 8)
+	case reflect.Uint16:
+		val, err = strconv.ParseUint(s, 10, 16)
+	case reflect.Uint32:
+		val, err = strconv.ParseUint(s, 10, 32)
+	case reflect.Uint64:
+		val, err = strconv.ParseUint(s, 10, 64)
+	case reflect.Int:
+		val, err = strconv.ParseInt(s, 10, 0)
+	case reflect.Int8:
+		val, err = strconv.ParseInt(s, 10, 8)
+	case reflect.Int16:
+		val, err = strconv.ParseInt(s, 10, 16)
+	case reflect.Int32:
+		val, err = strconv.ParseInt(s, 10, 32)
+	case reflect.Int64:
+		// Check if the provided number has a non-numeric extension.
+		var hasExtension bool
+		if len(s) > 0 {
+			lastChar := s[len(s)-1]
+			if lastChar < '0' || lastChar > '9' {
+				hasExtension = true
+			}
+		}
+		// If the value is a time.Duration with extension, parse as duration.
+		// If the value is an int64 or a time.Duration without extension, parse as number.
+		if hasExtension && mtype.String() == "time.Duration" {
+			val, err = time.ParseDuration(s)
+		} else {
+			val, err = strconv.ParseInt(s, 10, 64)
+		}
+	case reflect.Float32:
+		val, err = strconv.ParseFloat(s, 32)
+	case reflect.Float64:
+		val, err = strconv.ParseFloat(s, 64)
+	default:
+		return nil, fmt.Errorf("unsupported field type for default option")
+	}
+	return val, err
+}
+
 func tomlOptions(vf reflect.StructField, an annotation) tomlOpts {
-	tag := vf.Tag.Get(an.tag)
+	tag, ok := vf.Tag.Lookup(an.tag)
+	if !ok && an.fallbackTag != "" {
+		tag = vf.Tag.Get(an.fallbackTag)
+	}
 	parse := strings.Split(tag, ",")
 	var comment string
 	if c := vf.Tag.Get(an.comment); c != "" {
@@ -1194,6 +2392,9 @@ func tomlOptions(vf reflect.StructField, an annotation) tomlOpts {
 		omitempty:    false,
 		defaultValue: defaultValue,
 	}
+	if an.fieldName != nil {
+		result.name = an.fieldName(vf.Name)
+	}
 	if parse[0] != "" {
 		if parse[0] == "-" && len(parse) == 1 {
 			result.include = false
@@ -1205,8 +2406,17 @@ func tomlOptions(vf reflect.StructField, an annotation) tomlOpts {
 	if vf.PkgPath != "" {
 		result.include = false
 	}
-	if len(parse) > 1 && strings.Trim(parse[1], " ") == "omitempty" {
-		result.omitempty = true
+	for _, option := range parse[1:] {
+		switch strings.Trim(option, " ") {
+		case "omitempty":
+			result.omitempty = true
+		case "required":
+			result.required = true
+		default:
+			if key := strings.TrimPrefix(strings.Trim(option, " "), "keyed="); key != strings.Trim(option, " ") {
+				result.keyedBy = key
+			}
+		}
 	}
 	if vf.Type.Kind() == reflect.Ptr {
 		result.omitempty = true
@@ -1230,35 +2440,36 @@ func formatError(err error, pos Position) error {
 	return fmt.Errorf("%s: %s", pos, err)
 }
 
-// visitorState keeps track of which keys were unmarshaled.
+// visitorState keeps track of the key being unmarshaled and, when active, of
+// which keys were unmarshaled.
 type visitorState struct {
 	tree   *Tree
 	path   []string
-	keys   map[string]struct{}
+	keys   map[string]Position
 	active bool
 }
 
-func newVisitorState(tree *Tree) visitorState {
-	path, result := []string{}, map[string]struct{}{}
-	insertKeys(path, result, tree)
-	return visitorState{
+// newVisitorState returns the state of the unmarshaling of tree. Only active
+// states list the keys of tree, which costs a walk of the whole tree.
+func newVisitorState(tree *Tree, active bool) visitorState {
+	s := visitorState{
 		tree:   tree,
-		path:   path[:0],
-		keys:   result,
-		active: true,
+		path:   []string{},
+		active: active,
+	}
+	if active {
+		s.keys = map[string]Position{}
+		insertKeys(nil, s.keys, tree)
 	}
+	return s
 }
 
 func (s *visitorState) push(key string) {
-	if s.active {
-		s.path = append(s.path, key)
-	}
+	s.path = append(s.path, key)
 }
 
 func (s *visitorState) pop() {
-	if s.active {
-		s.path = s.path[:len(s.path)-1]
-	}
+	s.path = s.path[:len(s.path)-1]
 }
 
 func (s *visitorState) visit() {
@@ -1277,22 +2488,74 @@ func (s *visitorState) visitAll() {
 	}
 }
 
-func (s *visitorState) validate() error {
-	if !s.active {
-		return nil
-	}
+func (s *visitorState) unvisited() []string {
 	undecoded := make([]string, 0, len(s.keys))
 	for key := range s.keys {
 		undecoded = append(undecoded, key)
 	}
 	sort.Strings(undecoded)
-	if len(undecoded) > 0 {
+	return undecoded
+}
+
+func (s *visitorState) validate() error {
+	if !s.active {
+		return nil
+	}
+	if undecoded := s.unvisited(); len(undecoded) > 0 {
 		return fmt.Errorf("undecoded keys: %q", undecoded)
 	}
 	return nil
 }
 
-func insertKeys(path []string, m map[string]struct{}, tree *Tree) {
+// validatePositions is like validate, but reports the position of each key.
+func (s *visitorState) validatePositions() error {
+	undecoded := s.unvisited()
+	if len(undecoded) == 0 {
+		return nil
+	}
+	sort.SliceStable(undecoded, func(i, j int) bool {
+		a, b := s.keys[undecoded[i]], s.keys[undecoded[j]]
+		return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+	})
+	lines := make([]string, len(undecoded))
+	for i, key := range undecoded {
+		lines[i] = fmt.Sprintf("%s: %s", s.keys[key], key)
+	}
+	return fmt.Errorf("unknown keys:\n%s", strings.Join(lines, "\n"))
+}
+
+func insertKeyTypes(path []string, m map[string]string, tree *Tree) {
+	for k, v := range tree.values {
+		switch node := v.(type) {
+		case []*Tree:
+			for i, item := range node {
+				insertKeyTypes(append(path, k, strconv.Itoa(i)), m, item)
+			}
+		case *Tree:
+			insertKeyTypes(append(path, k), m, node)
+		case *tomlValue:
+			m[strings.Join(append(path, k), ".")] = tomlTypeName(node.value)
+		}
+	}
+}
+
+func insertFlatValues(path []string, m map[string]interface{}, tree *Tree) {
+	for k, v := range tree.values {
+		k = quoteKeyIfNeeded(k)
+		switch node := v.(type) {
+		case []*Tree:
+			for i, item := range node {
+				insertFlatValues(append(path, k, strconv.Itoa(i)), m, item)
+			}
+		case *Tree:
+			insertFlatValues(append(path, k), m, node)
+		case *tomlValue:
+			m[strings.Join(append(path, k), ".")] = tomlValueToGo(node.value)
+		}
+	}
+}
+
+func insertKeys(path []string, m map[string]Position, tree *Tree) {
 	for k, v := range tree.values {
 		switch node := v.(type) {
 		case []*Tree:
@@ -1302,7 +2565,7 @@ func insertKeys(path []string, m map[string]struct{}, tree *Tree) {
 		case *Tree:
 			insertKeys(append(path, k), m, node)
 		case *tomlValue:
-			m[strings.Join(append(path, k), ".")] = struct{}{}
+			m[strings.Join(append(path, k), ".")] = node.position
 		}
 	}
 }
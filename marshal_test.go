@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"reflect"
 	"strconv"
@@ -507,6 +508,33 @@ func TestDocMarshalOrderedMaps(t *testing.T) {
 	}
 }
 
+func TestEncoderMapOrderSeed(t *testing.T) {
+	data := map[int]string{}
+	for i := 0; i < 20; i++ {
+		data[i] = fmt.Sprint("value ", i)
+	}
+	encode := func(seed int64) string {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Order(OrderPreserve).MapOrderSeed(seed).Encode(data); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	expected := encode(42)
+	for i := 0; i < 10; i++ {
+		if result := encode(42); result != expected {
+			t.Fatalf("expected the same output for the same seed:\n%s\ngot:\n%s", expected, result)
+		}
+	}
+	if encode(7) == expected {
+		t.Errorf("expected another order for another seed")
+	}
+	if !strings.HasPrefix(expected, "14 = \"value 14\"\n16 = \"value 16\"\n") {
+		t.Errorf("unexpected order:\n%s", expected)
+	}
+}
+
 func TestDocMarshalPointer(t *testing.T) {
 	result, err := Marshal(&docData)
 	if err != nil {
@@ -1772,10 +1800,17 @@ func TestMarshalDirectMultilineString(t *testing.T) {
 	if err != nil {
 		t.Fatal("marshal should not error:", err)
 	}
-	expected := []byte("mykey = \"\"\"\nmy\\u0011multiline\nstring\\ba\tb\\fc\rd\"e\\!\"\"\"\n")
+	expected := []byte("mykey = \"\"\"\nmy\\u0011multiline\nstring\\ba\tb\\fc\\rd\"e\\\\!\"\"\"\n")
 	if !bytes.Equal(result, expected) {
 		t.Errorf("Bad marshal: expected\n-----\n%s\n-----\ngot\n-----\n%s\n-----\n", expected, result)
 	}
+	loaded, err := LoadBytes(result)
+	if err != nil {
+		t.Fatal("marshaled string should load:", err)
+	}
+	if loaded.Get("mykey") != tree.Get("mykey") {
+		t.Errorf("Bad round trip: %q", loaded.Get("mykey"))
+	}
 }
 
 func TestUnmarshalTabInStringAndQuotedKey(t *testing.T) {
@@ -2003,6 +2038,129 @@ func TestUnmarshalCustomTag(t *testing.T) {
 	}
 }
 
+func TestFallbackTagName(t *testing.T) {
+	type server struct {
+		Host    string `json:"host_name"`
+		Port    int    `json:"port,omitempty" toml:"listen_port"`
+		Debug   bool   `json:"debug,omitempty"`
+		Secret  string `json:"-"`
+		Comment string
+	}
+
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).SetFallbackTagName("json").Encode(server{Host: "a", Port: 80, Secret: "s", Comment: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Comment = \"c\"\nhost_name = \"a\"\nlisten_port = 80\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	var result server
+	doc := "host_name = \"b\"\nlisten_port = 81\ndebug = true\nSecret = \"s\""
+	if err := NewDecoder(strings.NewReader(doc)).SetFallbackTagName("json").Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if want := (server{Host: "b", Port: 81, Debug: true}); result != want {
+		t.Errorf("expected %+v, got %+v", want, result)
+	}
+
+	result = server{}
+	if err := NewDecoder(strings.NewReader(doc)).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Host != "" || result.Secret != "s" {
+		t.Errorf("json tags must be ignored by default, got %+v", result)
+	}
+}
+
+func TestUnmarshalJSONRawMessage(t *testing.T) {
+	doc := []byte(`
+name = "api"
+tags = ["a", "b"]
+started = 1979-05-27T07:32:00Z
+
+[plugin]
+enabled = true
+weights = { low = 1, high = 2.5 }
+
+[[plugin.rules]]
+match = "*.go"
+`)
+	var cfg struct {
+		Name    json.RawMessage
+		Tags    json.RawMessage
+		Started json.RawMessage
+		Plugin  *json.RawMessage
+	}
+	if err := Unmarshal(doc, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"name":    `"api"`,
+		"tags":    `["a","b"]`,
+		"started": `"1979-05-27T07:32:00Z"`,
+		"plugin":  `{"enabled":true,"rules":[{"match":"*.go"}],"weights":{"high":2.5,"low":1}}`,
+	}
+	for key, got := range map[string]json.RawMessage{"name": cfg.Name, "tags": cfg.Tags, "started": cfg.Started, "plugin": *cfg.Plugin} {
+		if string(got) != expected[key] {
+			t.Errorf("%s: expected %s, got %s", key, expected[key], got)
+		}
+	}
+
+	err := NewDecoder(bytes.NewReader(doc)).Strict(true).Decode(&cfg)
+	if err != nil {
+		t.Errorf("the keys of raw messages must be used: %s", err)
+	}
+}
+
+func TestMarshalJSONRawMessage(t *testing.T) {
+	doc := `name = "api"
+tags = ["a", "b"]
+
+[plugin]
+  enabled = true
+
+  [[plugin.rules]]
+    match = "*.go"
+
+  [plugin.weights]
+    high = 2.5
+    low = 1
+`
+	var cfg struct {
+		Name   json.RawMessage  `toml:"name"`
+		Tags   json.RawMessage  `toml:"tags"`
+		Plugin *json.RawMessage `toml:"plugin"`
+		Unset  json.RawMessage  `toml:"unset"`
+	}
+	if err := Unmarshal([]byte(doc), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	output, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != doc {
+		t.Errorf("Bad round trip.\nExpected:\n%s\nGot:\n%s", doc, output)
+	}
+
+	output, err = Marshal(map[string]json.RawMessage{"a": json.RawMessage(`{"b": null, "c": 1e3}`), "d": json.RawMessage("null")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "\n[a]\n  c = 1000.0\n"; string(output) != expected {
+		t.Errorf("Bad marshal.\nExpected: %q\nGot:      %q", expected, output)
+	}
+
+	for _, raw := range []string{`[1, null]`, `{"a": `} {
+		if _, err := Marshal(map[string]json.RawMessage{"a": json.RawMessage(raw)}); err == nil {
+			t.Errorf("%s: expected an error", raw)
+		}
+	}
+}
+
 func TestUnmarshalMap(t *testing.T) {
 	testToml := []byte(`
 		a = 1
@@ -2069,6 +2227,85 @@ func TestUnmarshalInvalidPointerKind(t *testing.T) {
 	}
 }
 
+func TestInvalidUnmarshalError(t *testing.T) {
+	var config struct{}
+	a := 1
+	tests := []struct {
+		v       interface{}
+		message string
+	}{
+		{nil, "nil cannot be unmarshaled from TOML, pass a pointer such as &v"},
+		{config, "non-pointer struct {} cannot be unmarshaled from TOML, pass a pointer such as &v"},
+		{(*map[string]int)(nil), "nil *map[string]int cannot be unmarshaled from TOML, the pointer must point to a value"},
+		{&a, "*int cannot be unmarshaled from TOML, only a pointer to struct or map can"},
+	}
+	for _, test := range tests {
+		err := Unmarshal([]byte(`a = 1`), test.v)
+		invalidErr, ok := err.(*InvalidUnmarshalError)
+		if !ok {
+			t.Errorf("%T: expected an InvalidUnmarshalError, got %v", test.v, err)
+			continue
+		}
+		if invalidErr.Type != reflect.TypeOf(test.v) {
+			t.Errorf("%T: unexpected type %v", test.v, invalidErr.Type)
+		}
+		if err.Error() != test.message {
+			t.Errorf("%T: expected %q, got %q", test.v, test.message, err.Error())
+		}
+	}
+}
+
+func TestDecoderCollectErrors(t *testing.T) {
+	type config struct {
+		Name    string
+		Port    int
+		Servers []struct {
+			Weight int
+		}
+		Limits   map[string]int
+		Database struct {
+			DSN string `toml:"dsn,required"`
+		}
+	}
+	input := `
+name = "api"
+port = "x"
+
+[[servers]]
+weight = true
+
+[[servers]]
+weight = 2
+
+[limits]
+a = 1
+b = "c"
+`
+
+	var result config
+	err := NewDecoder(strings.NewReader(input)).CollectErrors(true).Decode(&result)
+	errs, ok := err.(DecodeErrors)
+	if !ok {
+		t.Fatalf("expected DecodeErrors, got %v", err)
+	}
+	expected := `4 errors while decoding:
+(3, 1): Can't convert x(string) to int
+(6, 1): Can't convert true(bool) to int
+(13, 1): Can't convert c(string) to int
+missing required keys: ["Database.dsn"]`
+	if len(errs) != 4 || err.Error() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, err)
+	}
+	if result.Name != "api" || len(result.Servers) != 2 || result.Servers[1].Weight != 2 || !reflect.DeepEqual(result.Limits, map[string]int{"a": 1}) {
+		t.Errorf("valid values must be decoded, got %+v", result)
+	}
+
+	err = NewDecoder(strings.NewReader(input)).Decode(&config{})
+	if _, ok := err.(DecodeErrors); ok || err == nil || err.Error() != "(3, 1): Can't convert x(string) to int" {
+		t.Errorf("expected the first error only without CollectErrors, got %v", err)
+	}
+}
+
 func TestMarshalSlice(t *testing.T) {
 	m := make([]int, 1)
 	m[0] = 1
@@ -2439,6 +2676,177 @@ func TestUnmarshalDefault(t *testing.T) {
 	}
 }
 
+func TestUnmarshalRequired(t *testing.T) {
+	type database struct {
+		DSN  string `toml:"dsn,required"`
+		Pool int    `toml:"pool"`
+	}
+	type config struct {
+		Name     string   `toml:"name,omitempty,required"`
+		Port     int      `toml:"port,required"`
+		Primary  database `toml:"primary"`
+		Replica  database `toml:"replica"`
+		Optional string   `toml:"optional"`
+	}
+
+	var c config
+	err := Unmarshal([]byte("name = \"app\"\n[primary]\npool = 2\n"), &c)
+	expected := `missing required keys: ["port" "primary.dsn" "replica.dsn"]`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+
+	doc := []byte(`
+name = "app"
+port = 8080
+[primary]
+dsn = "postgres://primary"
+[replica]
+dsn = "postgres://replica"
+`)
+	if err := Unmarshal(doc, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 8080 || c.Replica.DSN != "postgres://replica" {
+		t.Errorf("bad config: %+v", c)
+	}
+}
+
+func TestDecoderOverlay(t *testing.T) {
+	type server struct {
+		Host string
+		Port int
+	}
+	type config struct {
+		Name    string
+		Workers int `default:"4"`
+		Server  server
+		Backup  *server
+		Labels  map[string]string
+		Tags    []string
+		Extra   map[string]interface{}
+	}
+	base := `
+name = "base"
+workers = 8
+tags = ["a", "b"]
+[server]
+host = "localhost"
+port = 80
+[backup]
+host = "backup"
+port = 81
+[labels]
+env = "dev"
+team = "core"
+[extra.limits]
+cpu = 1
+memory = 512
+`
+	local := `
+tags = ["c"]
+[server]
+port = 8080
+[backup]
+port = 8081
+[labels]
+env = "prod"
+[extra.limits]
+memory = 1024
+`
+	var c config
+	if err := NewDecoder(strings.NewReader(base)).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDecoder(strings.NewReader(local)).Overlay(true).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	expected := config{
+		Name:    "base",
+		Workers: 8,
+		Server:  server{Host: "localhost", Port: 8080},
+		Backup:  &server{Host: "backup", Port: 8081},
+		Labels:  map[string]string{"env": "prod", "team": "core"},
+		Tags:    []string{"c"},
+		Extra: map[string]interface{}{
+			"limits": map[string]interface{}{"cpu": int64(1), "memory": int64(1024)},
+		},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("got %+v (backup %+v), expected %+v", c, c.Backup, expected)
+	}
+
+	if err := NewDecoder(strings.NewReader(local)).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Workers != 4 || len(c.Labels) != 1 {
+		t.Errorf("without overlay, defaults apply and maps are replaced: %+v", c)
+	}
+}
+
+func TestUnmarshalKeyed(t *testing.T) {
+	type server struct {
+		Name string
+		Port int
+	}
+	type upstream struct {
+		Port int
+	}
+	type config struct {
+		Servers   map[string]server    `toml:"servers,keyed=name"`
+		Upstreams map[string]*upstream `toml:"upstreams,keyed=id"`
+	}
+	doc := []byte(`
+[[servers]]
+name = "a"
+port = 80
+
+[[servers]]
+name = "b"
+port = 81
+
+[[upstreams]]
+id = "x"
+port = 8080
+`)
+	var c config
+	if err := NewDecoder(bytes.NewReader(doc)).Strict(true).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	expected := config{
+		Servers:   map[string]server{"a": {Name: "a", Port: 80}, "b": {Name: "b", Port: 81}},
+		Upstreams: map[string]*upstream{"x": {Port: 8080}},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("got %+v, expected %+v", c, expected)
+	}
+
+	// output of Marshal
+	out, err := Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back config
+	if err := Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, expected) {
+		t.Errorf("bad round trip: %+v", back)
+	}
+
+	errors := map[string]string{
+		"[[servers]]\nport = 80":                               "(1, 1): table has no name key",
+		"[[servers]]\nname = 1":                                "(2, 1): key name must be a string, not int64",
+		"[[servers]]\nname = \"a\"\n[[servers]]\nname = \"a\"": "(4, 1): duplicate name \"a\"",
+	}
+	for doc, expected := range errors {
+		var c config
+		if err := Unmarshal([]byte(doc), &c); err == nil || err.Error() != expected {
+			t.Errorf("%q: expected error %q, got %v", doc, expected, err)
+		}
+	}
+}
+
 func TestUnmarshalDefaultFailureBool(t *testing.T) {
 	var doc struct {
 		Field bool `default:"blah"`
@@ -3772,6 +4180,45 @@ func TestDecoderStrict(t *testing.T) {
 	}
 }
 
+func TestDecoderDisallowUnknownFields(t *testing.T) {
+	input := `
+[decoded]
+  key = ""
+  extra = 1
+
+[undecoded]
+  key = ""
+
+  [[undecoded.array]]
+	key = ""
+
+  [[undecoded.array]]
+	key = ""
+`
+	var doc struct {
+		Decoded struct {
+			Key string
+		}
+	}
+
+	expected := `unknown keys:
+(4, 3): decoded.extra
+(7, 3): undecoded.key
+(10, 2): undecoded.array.0.key
+(13, 2): undecoded.array.1.key`
+
+	d := NewDecoder(bytes.NewReader([]byte(input))).DisallowUnknownFields()
+	err := d.Decode(&doc)
+	if err == nil {
+		t.Error("expected error, got none")
+	} else if err.Error() != expected {
+		t.Errorf("expect err:\n%s\ngot:\n%s", expected, err.Error())
+	}
+	if len(d.UnusedKeys()) != 4 {
+		t.Errorf("unexpected unused keys: %q", d.UnusedKeys())
+	}
+}
+
 func TestDecoderStrictValid(t *testing.T) {
 	input := `
 [decoded]
@@ -3924,6 +4371,45 @@ Float = 2.0
 	}
 }
 
+func TestTextUnmarshalScalars(t *testing.T) {
+	var doc struct {
+		Host     net.IP
+		Peers    []net.IP
+		Versions []intWrapper
+		Limits   map[string]intWrapper
+	}
+
+	input := `
+Host = "192.168.1.1"
+Peers = ["10.0.0.1", "::1"]
+Versions = ["1", "2"]
+
+[Limits]
+cpu = "4"
+`
+
+	if err := Unmarshal([]byte(input), &doc); err != nil {
+		t.Fatalf("unexpected err: %s", err.Error())
+	}
+	if !doc.Host.Equal(net.ParseIP("192.168.1.1")) {
+		t.Errorf("unexpected host: %v", doc.Host)
+	}
+	if len(doc.Peers) != 2 || !doc.Peers[1].Equal(net.IPv6loopback) {
+		t.Errorf("unexpected peers: %v", doc.Peers)
+	}
+	if !reflect.DeepEqual(doc.Versions, []intWrapper{{1}, {2}}) {
+		t.Errorf("unexpected versions: %v", doc.Versions)
+	}
+	if doc.Limits["cpu"].Value != 4 {
+		t.Errorf("unexpected limits: %v", doc.Limits)
+	}
+
+	err := Unmarshal([]byte(`Host = "not an address"`), &doc)
+	if err == nil || !strings.Contains(err.Error(), "unmarshal text") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestTextUnmarshalError(t *testing.T) {
 	var doc struct {
 		Failer intWrapper
@@ -4119,3 +4605,767 @@ ErrorField = "foo"
 		t.Fatalf("error was expected")
 	}
 }
+
+// anyValue keeps the value given to UnmarshalTOML, as types written for
+// BurntSushi/toml often do.
+type anyValue struct {
+	value interface{}
+}
+
+func (a *anyValue) UnmarshalTOML(v interface{}) error {
+	a.value = v
+	return nil
+}
+
+func TestUnmarshalerArrays(t *testing.T) {
+	src := `
+array = [1, { x = 2 }]
+
+[[tables]]
+name = "a"
+
+[[tables]]
+name = "b"
+`
+	var cfg struct {
+		Array  anyValue
+		Tables anyValue
+	}
+	if err := Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{int64(1), map[string]interface{}{"x": int64(2)}}
+	if !reflect.DeepEqual(cfg.Array.value, expected) {
+		t.Errorf("array: expected %#v, got %#v", expected, cfg.Array.value)
+	}
+	expected = []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}}
+	if !reflect.DeepEqual(cfg.Tables.value, expected) {
+		t.Errorf("array of tables: expected %#v, got %#v", expected, cfg.Tables.value)
+	}
+}
+
+func TestMarshalLineEnding(t *testing.T) {
+	type inner struct {
+		B string `toml:"b" multiline:"true"`
+	}
+	type doc struct {
+		A     int   `toml:"a"`
+		Inner inner `toml:"inner"`
+	}
+	v := doc{A: 1, Inner: inner{B: "x\ny"}}
+
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).LineEnding(LineEndingCRLF).Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "a = 1\r\n\r\n[inner]\r\n  b = \"\"\"\r\nx\ny\"\"\"\r\n"
+	if buf.String() != expected {
+		t.Errorf("Bad CRLF output.\nExpected: %q\nGot:      %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	err = NewEncoder(&buf).LineEnding(LineEndingCRLF).TrailingNewline(false).Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), `y"""`) {
+		t.Errorf("Trailing newline should be removed, got %q", buf.String())
+	}
+
+	var back doc
+	if err := Unmarshal(buf.Bytes(), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.A != 1 || back.Inner.B != "x\ny" {
+		t.Errorf("Bad round trip: %+v", back)
+	}
+
+	tree, err := Load(`# toml-fmt: inline
+owner = { name = "a" }
+ports = [1, 2] # ports
+
+[[servers]]
+  host = "a"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.SetWithComment("path", "line one\nline two", false, "x")
+	buf.Reset()
+	err = NewEncoder(&buf).LineEnding(LineEndingCRLF).ArraysWithOneElementPerLine(true).Encode(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "# toml-fmt: inline\r\nowner = { name = \"a\" }\r\n\r\n# line one\r\n#line two\r\npath = \"x\"\r\n" +
+		"ports = [\r\n  1,\r\n  2,\r\n] # ports\r\n\r\n[[servers]]\r\n  host = \"a\"\r\n"
+	if buf.String() != expected {
+		t.Errorf("Bad CRLF output.\nExpected: %q\nGot:      %q", expected, buf.String())
+	}
+}
+
+func TestMarshalLineEndingFrom(t *testing.T) {
+	v := struct{ A int }{1}
+	for template, expected := range map[string]string{
+		"x = 1\r\ny = 2\r\n": "A = 1\r\n",
+		"x = 1\ny = 2\n":     "A = 1\n",
+		"x = 1":              "A = 1\n",
+	} {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).LineEndingFrom([]byte(template)).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expected {
+			t.Errorf("template %q: expected %q, got %q", template, expected, buf.String())
+		}
+	}
+}
+
+func TestMarshalGroupDigits(t *testing.T) {
+	type doc struct {
+		A int64
+		B uint64
+		C int
+		D int
+		E float64
+	}
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).GroupDigits(true).Encode(doc{A: 1234567, B: 12345, C: -100000, D: 1000, E: 12345.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `A = 1_234_567
+B = 12_345
+C = -100_000
+D = 1000
+E = 12345.5
+`
+	if buf.String() != expected {
+		t.Errorf("Bad output.\nExpected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+
+	var back doc
+	if err := Unmarshal(buf.Bytes(), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.A != 1234567 || back.C != -100000 {
+		t.Errorf("Bad round trip: %+v", back)
+	}
+}
+
+func TestDecoderUnusedKeys(t *testing.T) {
+	input := `
+title = "config"
+legacy = true
+
+[server]
+  host = "localhost"
+  timeout = 30
+
+[[plugins]]
+  name = "a"
+  unused = 1
+`
+	var doc struct {
+		Title  string
+		Server struct {
+			Host string
+		}
+		Plugins []struct {
+			Name string
+		}
+	}
+
+	d := NewDecoder(bytes.NewReader([]byte(input)))
+	if err := d.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.UnusedKeys()) != 0 {
+		t.Errorf("unused keys should only be recorded on demand, got %v", d.UnusedKeys())
+	}
+
+	d = NewDecoder(bytes.NewReader([]byte(input))).WithUnusedKeys()
+	if err := d.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"legacy", "plugins.0.unused", "server.timeout"}
+	if !reflect.DeepEqual(d.UnusedKeys(), expected) {
+		t.Errorf("expected unused keys %v, got %v", expected, d.UnusedKeys())
+	}
+
+	var m map[string]interface{}
+	d = NewDecoder(bytes.NewReader([]byte(input))).WithUnusedKeys()
+	if err := d.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.UnusedKeys()) != 0 {
+		t.Errorf("decoding into a map should use every key, got %v", d.UnusedKeys())
+	}
+}
+
+type semver struct {
+	Major, Minor, Patch int
+}
+
+func (v *semver) UnmarshalTOML(i interface{}) error {
+	s, ok := i.(string)
+	if !ok {
+		return fmt.Errorf("version must be a string, not %T", i)
+	}
+	_, err := fmt.Sscanf(s, "%d.%d.%d", &v.Major, &v.Minor, &v.Patch)
+	return err
+}
+
+func TestUnmarshalCustomScalar(t *testing.T) {
+	var config struct {
+		Version  semver
+		Previous []semver
+		Plugins  map[string]*semver
+	}
+	doc := `
+Version = "1.2.3"
+Previous = ["1.0.0", "1.1.0"]
+
+[Plugins]
+auth = "0.4.1"
+`
+	if err := Unmarshal([]byte(doc), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Version != (semver{1, 2, 3}) {
+		t.Errorf("unexpected version: %v", config.Version)
+	}
+	if !reflect.DeepEqual(config.Previous, []semver{{1, 0, 0}, {1, 1, 0}}) {
+		t.Errorf("unexpected previous versions: %v", config.Previous)
+	}
+	if v := config.Plugins["auth"]; v == nil || *v != (semver{0, 4, 1}) {
+		t.Errorf("unexpected plugin version: %v", v)
+	}
+
+	err := Unmarshal([]byte(`Version = 1`), &config)
+	if err == nil || !strings.Contains(err.Error(), "version must be a string, not int64") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTextUnmarshalMapKeys(t *testing.T) {
+	var doc struct {
+		Ports map[intWrapper]string
+	}
+	input := `
+[Ports]
+80 = "http"
+"443" = "https"
+`
+	if err := Unmarshal([]byte(input), &doc); err != nil {
+		t.Fatalf("unexpected err: %s", err.Error())
+	}
+	expected := map[intWrapper]string{{80}: "http", {443}: "https"}
+	if !reflect.DeepEqual(doc.Ports, expected) {
+		t.Errorf("Bad unmarshal: expected %v, got %v", expected, doc.Ports)
+	}
+
+	err := Unmarshal([]byte("[Ports]\nhttp = \"80\""), &doc)
+	if err == nil || err.Error() != "(2, 1): unmarshal text: unsupported: http" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var floats struct {
+		Ports map[float64]string
+	}
+	err = Unmarshal([]byte(input), &floats)
+	if err == nil || !strings.Contains(err.Error(), `Can't convert key "`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestIntegerMapKeys(t *testing.T) {
+	input := `
+[Ports]
+80 = "http"
+"443" = "https"
+
+[Codes]
+200 = "OK"
+`
+	var doc struct {
+		Ports map[int]string
+		Codes map[uint16]string
+	}
+	if err := Unmarshal([]byte(input), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc.Ports, map[int]string{80: "http", 443: "https"}) || !reflect.DeepEqual(doc.Codes, map[uint16]string{200: "OK"}) {
+		t.Errorf("Bad unmarshal: %+v", doc)
+	}
+
+	result, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+[Codes]
+  200 = "OK"
+
+[Ports]
+  443 = "https"
+  80 = "http"
+`
+	if string(result) != expected {
+		t.Errorf("Bad marshal: expected\n-----\n%s\n-----\ngot\n-----\n%s\n-----\n", expected, result)
+	}
+
+	var int8s struct {
+		Ports map[int8]string
+	}
+	err = Unmarshal([]byte(input), &int8s)
+	if err == nil || err.Error() != `(4, 1): Can't convert key "443" to int8` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUnmarshalWithMetadata(t *testing.T) {
+	input := `
+name = "api"
+debug = true
+
+[server]
+port = 8080
+ratio = 0.5
+
+[[clients]]
+id = 1
+`
+	var config struct {
+		Name   string
+		Server struct {
+			Port int
+		}
+	}
+	md, err := UnmarshalWithMetadata([]byte(input), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DecodeMetadata{
+		Decoded:   []string{"name", "server.port"},
+		Undecoded: []string{"clients.0.id", "debug", "server.ratio"},
+		Types: map[string]string{
+			"name":         "string",
+			"debug":        "boolean",
+			"server.port":  "integer",
+			"server.ratio": "float",
+			"clients.0.id": "integer",
+		},
+		FieldPositions: map[string]Position{
+			"Name":        {2, 1},
+			"Server":      {5, 1},
+			"Server.Port": {6, 1},
+		},
+	}
+	if !reflect.DeepEqual(md, expected) {
+		t.Errorf("Bad metadata. Expected %+v, got %+v", expected, md)
+	}
+
+	if _, err := UnmarshalWithMetadata([]byte(`name = 1`), &config); err == nil {
+		t.Error("expected a type error")
+	}
+}
+
+func TestUnmarshalWithMetadataFieldPositions(t *testing.T) {
+	input := `
+[[users]]
+name = "alice"
+
+[[users]]
+name = "bob"
+
+[limits]
+cpu = 2
+
+[[servers]]
+name = "a"
+port = 80
+`
+	var config struct {
+		Users []struct {
+			Name string
+		}
+		Limits  map[string]int
+		Servers map[string]struct {
+			Port int
+		} `toml:"servers,keyed=name"`
+	}
+	md, err := UnmarshalWithMetadata([]byte(input), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Position{
+		"Users":          {2, 1},
+		"Users.0":        {2, 1},
+		"Users.0.Name":   {3, 1},
+		"Users.1":        {5, 1},
+		"Users.1.Name":   {6, 1},
+		"Limits":         {8, 1},
+		"Limits.cpu":     {9, 1},
+		"Servers":        {11, 1},
+		"Servers.a":      {11, 1},
+		"Servers.a.Port": {13, 1},
+	}
+	if !reflect.DeepEqual(md.FieldPositions, expected) {
+		t.Errorf("Bad field positions. Expected %v, got %v", expected, md.FieldPositions)
+	}
+}
+
+func TestUnmarshalFlat(t *testing.T) {
+	input := `
+name = "api"
+ports = [80, 443]
+
+[server.http]
+port = 8080
+headers = { accept = "json" }
+
+[[products]]
+name = "hammer"
+
+[[products]]
+name = "nail"
+
+[sites."example.com"]
+port = 443
+`
+	m, err := UnmarshalFlat([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"name":                       "api",
+		"ports":                      []interface{}{int64(80), int64(443)},
+		"server.http.port":           int64(8080),
+		"server.http.headers.accept": "json",
+		"products.0.name":            "hammer",
+		"products.1.name":            "nail",
+		`sites."example.com".port`:   int64(443),
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Bad flat map. Expected %v, got %v", expected, m)
+	}
+
+	if _, err := UnmarshalFlat([]byte(`name = `)); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestDecoderDecodePath(t *testing.T) {
+	input := `
+title = "huge"
+
+[server.http]
+port = 8080
+"read.timeout" = "5s"
+
+[server.grpc]
+port = 9090
+
+[[workers]]
+id = 1
+`
+	var http struct {
+		Port        int
+		ReadTimeout time.Duration `toml:"read.timeout"`
+	}
+	d := NewDecoder(strings.NewReader(input)).Strict(true)
+	if err := d.DecodePath("server.http", &http); err != nil {
+		t.Fatal(err)
+	}
+	if http.Port != 8080 || http.ReadTimeout != 5*time.Second {
+		t.Errorf("unexpected value: %+v", http)
+	}
+
+	for key, expected := range map[string]string{
+		"server.ftp": "key server.ftp not found",
+		"title":      "(2, 1): key title is not a table",
+		"workers":    "(11, 1): key workers is not a table",
+		"server.":    "unexpected end of key",
+	} {
+		err := NewDecoder(strings.NewReader(input)).DecodePath(key, &http)
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("%s: expected error %q, got %v", key, expected, err)
+		}
+	}
+}
+
+func TestDecoderKeyOrder(t *testing.T) {
+	input := `
+zebra = 1
+apple = { y = 1, x = 2 }
+mango = 3
+
+[[servers]]
+port = 80
+host = "a"
+
+[[servers]]
+name = "b"
+
+[config]
+b = 1
+a = 2
+`
+	var m map[string]interface{}
+	d := NewDecoder(strings.NewReader(input)).WithKeyOrder()
+	if err := d.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"":          {"zebra", "apple", "mango", "servers", "config"},
+		"apple":     {"y", "x"},
+		"servers.0": {"port", "host"},
+		"servers.1": {"name"},
+		"config":    {"b", "a"},
+	}
+	if !reflect.DeepEqual(d.KeyOrder(), expected) {
+		t.Errorf("Bad key order.\nExpected: %v\nGot:      %v", expected, d.KeyOrder())
+	}
+
+	var s struct {
+		Config map[string]int
+	}
+	d = NewDecoder(strings.NewReader(input)).WithKeyOrder()
+	if err := d.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if order := d.KeyOrder(); len(order) != 1 || !reflect.DeepEqual(order["config"], []string{"b", "a"}) {
+		t.Errorf("only tables decoded into maps should be recorded, got %v", order)
+	}
+}
+
+func TestMarshalProvenance(t *testing.T) {
+	type server struct {
+		Host string
+		Port int
+	}
+	config := struct {
+		Name    string
+		Servers []server
+	}{
+		Name:    "api",
+		Servers: []server{{"a", 80}, {"b", 8080}},
+	}
+	origins := map[string]string{
+		"Name":           "default\rvalue",
+		"Servers.0.Host": "from file\r\nhosts.txt",
+		"Servers.1.Port": "from env\nAPP_PORT",
+	}
+
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).Provenance(func(key []string) string {
+		return origins[strings.Join(key, ".")]
+	}).Encode(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `Name = "api" # default value
+
+[[Servers]]
+  Host = "a" # from file hosts.txt
+  Port = 80
+
+[[Servers]]
+  Host = "b"
+  Port = 8080 # from env APP_PORT
+`
+	if buf.String() != expected {
+		t.Errorf("Bad provenance. Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+	if _, err := LoadBytes(buf.Bytes()); err != nil {
+		t.Errorf("output should be valid TOML: %s", err)
+	}
+
+	tree, err := Load("a = 1 # set by hand\n[[x.y.b]]\nc = 1\n[[x.y.b]]\nc = 2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := tree.String()
+	var keys [][]string
+	err = NewEncoder(&bytes.Buffer{}).Provenance(func(key []string) string {
+		keys = append(keys, key)
+		return "generated"
+	}).Encode(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := tree.String(); after != before {
+		t.Errorf("the encoded tree should be unchanged:\n%s\nexpected:\n%s", after, before)
+	}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		seen[strings.Join(key, ".")] = true
+	}
+	if !seen["x.y.b.0.c"] || !seen["x.y.b.1.c"] {
+		t.Errorf("the keys given to the callback should not be reused, got %q", keys)
+	}
+}
+
+func TestDecoderIntegerOverflow(t *testing.T) {
+	type config struct {
+		Small  int8
+		Port   uint16
+		Offset uint8
+		Huge   int64
+	}
+	doc := []byte("small = 300\nport = 70000\noffset = -1\nhuge = 9223372036854775808\n")
+
+	var c config
+	if err := NewDecoder(bytes.NewReader(doc)).Decode(&c); err == nil {
+		t.Error("expected an overflow error by default")
+	}
+
+	expected := map[OverflowPolicy]config{
+		OverflowSaturate: {Small: 127, Port: 65535, Offset: 0, Huge: 9223372036854775807},
+		OverflowWrap:     {Small: 44, Port: 4464, Offset: 255, Huge: -9223372036854775808},
+	}
+	for policy, want := range expected {
+		var got config
+		err := NewDecoder(bytes.NewReader(doc)).BigNumbers().IntegerOverflow(policy).Decode(&got)
+		if err != nil {
+			t.Errorf("policy %d: %s", policy, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("policy %d: got %+v, expected %+v", policy, got, want)
+		}
+	}
+
+	var fits config
+	err := NewDecoder(bytes.NewReader([]byte("small = -5\n"))).IntegerOverflow(OverflowSaturate).Decode(&fits)
+	if err != nil || fits.Small != -5 {
+		t.Errorf("got %d, %v", fits.Small, err)
+	}
+}
+
+func TestDecoderPointerTables(t *testing.T) {
+	type server struct {
+		Host string
+		Port int `default:"80"`
+	}
+	type config struct {
+		Primary *server
+		Backup  *server
+		Cache   *server
+		Started *time.Time
+	}
+	doc := []byte("[primary]\nhost = \"a\"\n\n[backup]\n")
+
+	expected := map[TablePolicy]config{
+		TablePresent:  {Primary: &server{Host: "a", Port: 80}, Backup: &server{Port: 80}},
+		TableNonEmpty: {Primary: &server{Host: "a", Port: 80}},
+		TableAlways:   {Primary: &server{Host: "a", Port: 80}, Backup: &server{Port: 80}, Cache: &server{Port: 80}},
+	}
+	for policy, want := range expected {
+		var got config
+		err := NewDecoder(bytes.NewReader(doc)).PointerTables(policy).Decode(&got)
+		if err != nil {
+			t.Errorf("policy %d: %s", policy, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("policy %d: got %+v, expected %+v", policy, got, want)
+		}
+	}
+}
+
+func TestDecoderDuplicateKeys(t *testing.T) {
+	doc := []byte(`name = "a"
+name = "b"
+
+[server]
+host = "c"
+
+[server]
+host = "d"
+port = 80
+`)
+	expected := map[DuplicatePolicy]map[string]interface{}{
+		DuplicateKeepFirst: {"name": "a", "server": map[string]interface{}{"host": "c", "port": int64(80)}},
+		DuplicateKeepLast:  {"name": "b", "server": map[string]interface{}{"host": "d", "port": int64(80)}},
+	}
+	for policy, want := range expected {
+		var got map[string]interface{}
+		err := NewDecoder(bytes.NewReader(doc)).DuplicateKeys(policy).Decode(&got)
+		if err != nil {
+			t.Errorf("policy %d: %s", policy, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("policy %d: got %v, expected %v", policy, got, want)
+		}
+	}
+
+	var got map[string]interface{}
+	err := NewDecoder(bytes.NewReader(doc)).DuplicateKeys(DuplicateError).Decode(&got)
+	if err == nil || err.Error() != "(2, 1): The following key was defined twice: name" {
+		t.Errorf("expected an error with DuplicateError, got %v", err)
+	}
+}
+
+func TestDecoderDisallowNonFinite(t *testing.T) {
+	for _, test := range []struct {
+		doc     string
+		message string
+	}{
+		{"a = 1.5\nb = nan", "(2, 5): nan is not allowed, floats must be finite"},
+		{"a = [1.0, +inf]", "(1, 11): +inf is not allowed, floats must be finite"},
+		{"[t]\nb = { c = -inf }", "(2, 11): -inf is not allowed, floats must be finite"},
+	} {
+		var v map[string]interface{}
+		err := NewDecoder(strings.NewReader(test.doc)).DisallowNonFinite().Decode(&v)
+		if err == nil || err.Error() != test.message {
+			t.Errorf("%q: expected %q, got %v", test.doc, test.message, err)
+		}
+		if err := NewDecoder(strings.NewReader(test.doc)).Decode(&v); err != nil {
+			t.Errorf("%q: non-finite floats are allowed by default: %s", test.doc, err)
+		}
+	}
+
+	var v struct{ A float64 }
+	if err := NewDecoder(strings.NewReader("a = 1e300")).DisallowNonFinite().Decode(&v); err != nil || v.A != 1e300 {
+		t.Errorf("unexpected error for a finite float: %v", err)
+	}
+}
+
+func TestDecoderTimeLayouts(t *testing.T) {
+	type config struct {
+		Created  time.Time
+		Modified *time.Time
+		Expires  time.Time
+	}
+	doc := []byte(`created = "Mon, 02 Jan 2006 15:04:05 UTC"
+modified = 1136214245
+expires = 2006-01-02T15:04:05Z
+`)
+	var c config
+	err := NewDecoder(bytes.NewReader(doc)).TimeLayouts(time.RFC1123, TimeLayoutUnix).Decode(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	if !c.Created.Equal(expected) {
+		t.Errorf("created: got %s", c.Created)
+	}
+	if c.Modified == nil || !c.Modified.Equal(expected) {
+		t.Errorf("modified: got %v", c.Modified)
+	}
+	if !c.Expires.Equal(expected) {
+		t.Errorf("expires: got %s", c.Expires)
+	}
+
+	err = NewDecoder(bytes.NewReader([]byte(`created = "yesterday"`))).TimeLayouts(time.RFC1123).Decode(&c)
+	if err == nil || !strings.Contains(err.Error(), `Can't parse "yesterday"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = NewDecoder(bytes.NewReader([]byte(`created = 1136214245`))).TimeLayouts(time.RFC1123).Decode(&c)
+	if err == nil {
+		t.Error("integers must be rejected without TimeLayoutUnix")
+	}
+}
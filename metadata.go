@@ -0,0 +1,173 @@
+// Generator metadata stored in comments.
+
+package toml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	metadataPrefix        = "# @"
+	metadataGenerator     = "generator"
+	metadataTimestamp     = "generated-at"
+	metadataSchemaVersion = "schema-version"
+	metadataChecksum      = "checksum"
+)
+
+// Metadata describes how a TOML document was generated. It is stored as a
+// block of comments at the top of the document, so that the document itself
+// remains untouched for parsers:
+//
+//   # @generator: mytool v1.2.0
+//   # @generated-at: 2021-06-14T10:00:00Z
+//   # @schema-version: 3
+//   # @checksum: 5e884898da28047151d0e56f8dc62927...
+//
+// Checksum is the semantic hash of the document content (see SemanticHash).
+// It allows detecting whether a generated file was edited manually.
+type Metadata struct {
+	Generator     string
+	Timestamp     time.Time
+	SchemaVersion string
+	Checksum      string
+}
+
+// SemanticHash returns a hex-encoded SHA-256 hash of the content of the tree.
+// Comments, formatting and key order do not affect the result. It is the
+// TableHash of the whole tree.
+func (t *Tree) SemanticHash() (string, error) {
+	return t.TableHash("")
+}
+
+// StampMetadata returns doc prefixed with a metadata comment block describing
+// md. Any metadata block already present at the top of doc is replaced. The
+// checksum of md is ignored and computed from the content of doc. Values
+// with line breaks or other control characters, which would end the comments,
+// are rejected.
+func StampMetadata(doc []byte, md Metadata) ([]byte, error) {
+	_, body := splitMetadata(doc)
+	tree, err := LoadBytes(body)
+	if err != nil {
+		return nil, err
+	}
+	md.Checksum, err = tree.SemanticHash()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	var timestamp string
+	if !md.Timestamp.IsZero() {
+		timestamp = md.Timestamp.Format(time.RFC3339)
+	}
+	lines := [][2]string{
+		{metadataGenerator, md.Generator},
+		{metadataTimestamp, timestamp},
+		{metadataSchemaVersion, md.SchemaVersion},
+		{metadataChecksum, md.Checksum},
+	}
+	for _, line := range lines {
+		if err := writeMetadataLine(&buf, line[0], line[1]); err != nil {
+			return nil, err
+		}
+	}
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// ReadMetadata extracts the metadata comment block at the top of doc.
+// The boolean is false if doc does not start with such a block.
+func ReadMetadata(doc []byte) (Metadata, bool, error) {
+	var md Metadata
+	header, _ := splitMetadata(doc)
+	if len(header) == 0 {
+		return md, false, nil
+	}
+	for _, line := range header {
+		key, value := parseMetadataLine(line)
+		switch key {
+		case metadataGenerator:
+			md.Generator = value
+		case metadataTimestamp:
+			ts, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return md, true, err
+			}
+			md.Timestamp = ts
+		case metadataSchemaVersion:
+			md.SchemaVersion = value
+		case metadataChecksum:
+			md.Checksum = value
+		}
+	}
+	return md, true, nil
+}
+
+// EditedSinceGeneration reports whether the content of doc differs from the
+// content it had when its metadata block was written by StampMetadata.
+func EditedSinceGeneration(doc []byte) (bool, error) {
+	md, ok, err := ReadMetadata(doc)
+	if err != nil {
+		return false, err
+	}
+	if !ok || md.Checksum == "" {
+		return false, errors.New("document has no metadata checksum")
+	}
+	tree, err := LoadBytes(doc)
+	if err != nil {
+		return false, err
+	}
+	sum, err := tree.SemanticHash()
+	if err != nil {
+		return false, err
+	}
+	return sum != md.Checksum, nil
+}
+
+func writeMetadataLine(buf *bytes.Buffer, key, value string) error {
+	if value == "" {
+		return nil
+	}
+	for _, r := range value {
+		if r < 0x20 && r != '\t' || r == 0x7f {
+			return fmt.Errorf("metadata %s cannot contain control characters: %q", key, value)
+		}
+	}
+	buf.WriteString(metadataPrefix)
+	buf.WriteString(key)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteString("\n")
+	return nil
+}
+
+func parseMetadataLine(line string) (string, string) {
+	line = strings.TrimPrefix(line, metadataPrefix)
+	idx := strings.Index(line, ":")
+	if idx < 0 {
+		return "", ""
+	}
+	return strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:])
+}
+
+// splitMetadata separates the leading metadata lines of doc from the rest of
+// the document.
+func splitMetadata(doc []byte) ([]string, []byte) {
+	var header []string
+	rest := doc
+	for bytes.HasPrefix(rest, []byte(metadataPrefix)) {
+		idx := bytes.IndexByte(rest, '\n')
+		if idx < 0 {
+			header = append(header, strings.TrimRight(string(rest), "\r"))
+			rest = rest[len(rest):]
+			break
+		}
+		header = append(header, strings.TrimRight(string(rest[:idx]), "\r"))
+		rest = rest[idx+1:]
+	}
+	return header, rest
+}
@@ -0,0 +1,121 @@
+package toml
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStampAndReadMetadata(t *testing.T) {
+	doc := []byte("title = \"generated\"\n\n[server]\nport = 8080\n")
+	ts := time.Date(2021, 6, 14, 10, 0, 0, 0, time.UTC)
+	stamped, err := StampMetadata(doc, Metadata{
+		Generator:     "gen v1",
+		Timestamp:     ts,
+		SchemaVersion: "3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(stamped), "# @generator: gen v1\n") {
+		t.Errorf("unexpected header:\n%s", stamped)
+	}
+	if !strings.HasSuffix(string(stamped), string(doc)) {
+		t.Errorf("document body should be preserved:\n%s", stamped)
+	}
+
+	md, ok, err := ReadMetadata(stamped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected metadata to be found")
+	}
+	if md.Generator != "gen v1" || md.SchemaVersion != "3" || !md.Timestamp.Equal(ts) || md.Checksum == "" {
+		t.Errorf("unexpected metadata: %+v", md)
+	}
+
+	restamped, err := StampMetadata(stamped, Metadata{Generator: "gen v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(restamped), "# @generator") != 1 {
+		t.Errorf("existing metadata should be replaced:\n%s", restamped)
+	}
+}
+
+func TestReadMetadataMissing(t *testing.T) {
+	_, ok, err := ReadMetadata([]byte("# a regular comment\na = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("no metadata should be found")
+	}
+}
+
+func TestEditedSinceGeneration(t *testing.T) {
+	stamped, err := StampMetadata([]byte("a = 1\r\nb = \"x\"\r\n"), Metadata{Generator: "gen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited, err := EditedSinceGeneration(stamped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited {
+		t.Error("untouched document should not be reported as edited")
+	}
+
+	reformatted := strings.Replace(string(stamped), "b = \"x\"", "# note\nb   =   \"x\"", 1)
+	edited, err = EditedSinceGeneration([]byte(reformatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited {
+		t.Error("formatting changes should not be reported as edits")
+	}
+
+	tree, err := LoadBytes(stamped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := tree.SemanticHash()
+	tree, err = LoadBytes([]byte(strings.Replace(string(stamped), "b = \"x\"", "b = \"x\" # note", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.SetFormat(FormatAligned | FormatSorted)
+	if hash, _ := tree.SemanticHash(); hash != expected {
+		t.Error("comments and formats should not change the hash")
+	}
+
+	changed := strings.Replace(string(stamped), "a = 1", "a = 2", 1)
+	edited, err = EditedSinceGeneration([]byte(changed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !edited {
+		t.Error("value change should be reported as an edit")
+	}
+
+	if _, err := EditedSinceGeneration([]byte("a = 1")); err == nil {
+		t.Error("expected an error for a document without metadata")
+	}
+}
+
+func TestStampMetadataControlCharacters(t *testing.T) {
+	for _, md := range []Metadata{
+		{Generator: "gen\n[injected]\nkey = 1"},
+		{Generator: "gen", SchemaVersion: "3\r"},
+		{Generator: "gen\x00"},
+	} {
+		if stamped, err := StampMetadata([]byte("a = 1\n"), md); err == nil {
+			t.Errorf("%+v: expected an error, got:\n%s", md, stamped)
+		}
+	}
+	if _, err := StampMetadata([]byte("a = 1\n"), Metadata{Generator: "gen\tv1"}); err != nil {
+		t.Errorf("tabs should be accepted: %s", err)
+	}
+}
@@ -0,0 +1,112 @@
+// Migrations of documents between schema versions.
+
+package toml
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MigrationFunc upgrades a document from a schema version to a newer one,
+// typically by renaming keys and restructuring tables with the Tree methods.
+type MigrationFunc func(t *Tree) error
+
+type migration struct {
+	to int64
+	fn MigrationFunc
+}
+
+// Migrations is a registry of schema migrations. The zero value is an empty
+// registry ready to use.
+type Migrations struct {
+	mu   sync.RWMutex
+	from map[int64]migration
+}
+
+// Register adds a migration upgrading documents from fromVersion to
+// toVersion. It panics if toVersion is not greater than fromVersion, or if a
+// migration from fromVersion is already registered.
+func (m *Migrations) Register(fromVersion, toVersion int64, fn MigrationFunc) {
+	if toVersion <= fromVersion {
+		panic(fmt.Sprintf("toml: migration must upgrade the version, not go from %d to %d", fromVersion, toVersion))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.from == nil {
+		m.from = make(map[int64]migration)
+	}
+	if _, dup := m.from[fromVersion]; dup {
+		panic(fmt.Sprintf("toml: migration from version %d registered twice", fromVersion))
+	}
+	m.from[fromVersion] = migration{to: toVersion, fn: fn}
+}
+
+// Migrate upgrades t by applying registered migrations in sequence, starting
+// from the version stored as an integer at versionKey (0 when the key is
+// absent), until no migration applies anymore. The version key is updated
+// after each migration. Migrate returns the final version of the document.
+//
+// Comments are kept on the tables and values that migrations leave in
+// place. Note that trees loaded from a document only hold the comments
+// written after values.
+func (m *Migrations) Migrate(t *Tree, versionKey string) (int64, error) {
+	keys, err := parseKey(versionKey)
+	if err != nil {
+		return 0, err
+	}
+	version, err := documentVersion(t, versionKey)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		m.mu.RLock()
+		mig, ok := m.from[version]
+		m.mu.RUnlock()
+		if !ok {
+			return version, nil
+		}
+		if err := mig.fn(t); err != nil {
+			return version, fmt.Errorf("migration from version %d to %d: %s", version, mig.to, err)
+		}
+		version = mig.to
+		setDocumentVersion(t, keys, version)
+	}
+}
+
+// Update the version in place to keep the formatting options of the key.
+func setDocumentVersion(t *Tree, keys []string, version int64) {
+	if parent, ok := t.GetPath(keys[:len(keys)-1]).(*Tree); ok {
+		if tv, ok := parent.values[keys[len(keys)-1]].(*tomlValue); ok {
+			tv.value = version
+			tv.raw = ""
+			tv.number = ""
+			return
+		}
+	}
+	t.SetPath(keys, version)
+}
+
+func documentVersion(t *Tree, versionKey string) (int64, error) {
+	switch v := t.Get(versionKey).(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("version key %s must be an integer, not %T", versionKey, v)
+	}
+}
+
+var defaultMigrations Migrations
+
+// RegisterMigration adds a migration to the default registry.
+// See Migrations.Register.
+func RegisterMigration(fromVersion, toVersion int64, fn MigrationFunc) {
+	defaultMigrations.Register(fromVersion, toVersion, fn)
+}
+
+// Migrate upgrades t using the default registry.
+// See Migrations.Migrate.
+func Migrate(t *Tree, versionKey string) (int64, error) {
+	return defaultMigrations.Migrate(t, versionKey)
+}
@@ -0,0 +1,115 @@
+package toml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	var m Migrations
+	m.Register(0, 1, func(t *Tree) error {
+		t.Set("server.host", t.Get("host"))
+		return t.Delete("host")
+	})
+	m.Register(1, 3, func(t *Tree) error {
+		t.Set("server.listen", t.Get("server.port"))
+		return t.Delete("server.port")
+	})
+
+	tree, err := Load(`
+host = "localhost"
+
+[server]
+port = 80
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := m.Migrate(tree, "version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 || tree.Get("version") != int64(3) {
+		t.Errorf("expected version 3, got %d (%v)", version, tree.Get("version"))
+	}
+	if tree.Has("host") || tree.Has("server.port") {
+		t.Errorf("old keys should be removed:\n%s", tree)
+	}
+	if tree.Get("server.host") != "localhost" || tree.Get("server.listen") != int64(80) {
+		t.Errorf("unexpected migrated tree:\n%s", tree)
+	}
+
+	version, err = m.Migrate(tree, "version")
+	if err != nil || version != 3 {
+		t.Errorf("migrating an up-to-date tree should be a no-op, got %d, %v", version, err)
+	}
+}
+
+func TestMigrateKeepsVersionComment(t *testing.T) {
+	var m Migrations
+	m.Register(1, 2, func(t *Tree) error { return nil })
+
+	tree, _ := Load(`meta.version = 1`)
+	tree.SetWithComment("meta.version", "schema version", false, int64(1))
+	if _, err := m.Migrate(tree, "meta.version"); err != nil {
+		t.Fatal(err)
+	}
+	if s := tree.String(); !strings.Contains(s, "# schema version\n  version = 2") {
+		t.Errorf("comment should be kept:\n%s", s)
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	var m Migrations
+	m.Register(0, 1, func(t *Tree) error { return errors.New("boom") })
+
+	tree, _ := Load(`a = 1`)
+	if _, err := m.Migrate(tree, "version"); err == nil || err.Error() != "migration from version 0 to 1: boom" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tree, _ = Load(`version = "1"`)
+	if _, err := m.Migrate(tree, "version"); err == nil {
+		t.Error("expected an error for a non-integer version")
+	}
+
+	if _, err := m.Migrate(tree, "a..b"); err == nil {
+		t.Error("expected an error for an invalid version key")
+	}
+}
+
+func TestMigrateQuotedVersionKey(t *testing.T) {
+	var m Migrations
+	m.Register(0, 1, func(t *Tree) error { return nil })
+
+	tree, _ := Load(`"schema.v" = 0`)
+	version, err := m.Migrate(tree, `"schema.v"`)
+	if err != nil || version != 1 {
+		t.Fatalf("unexpected result %d, %v", version, err)
+	}
+	if v := tree.GetPath([]string{"schema.v"}); v != int64(1) {
+		t.Errorf("the quoted version key should be updated, got %v", v)
+	}
+	if tree.Has("schema") {
+		t.Error("the version key should not be split on the dot of its quotes")
+	}
+}
+
+func TestRegisterMigrationPanics(t *testing.T) {
+	var m Migrations
+	m.Register(0, 1, func(t *Tree) error { return nil })
+	for name, register := range map[string]func(){
+		"duplicate": func() { m.Register(0, 2, nil) },
+		"downgrade": func() { m.Register(2, 1, nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			register()
+		}()
+	}
+}
@@ -0,0 +1,78 @@
+// Mapping of Go field names to TOML keys.
+
+package toml
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FieldNameMapper returns the TOML key of a struct field from its Go name. It
+// is used for fields without a name in their toml tag. SnakeCase, KebabCase
+// and CamelCase cover the usual conventions.
+type FieldNameMapper func(goName string) string
+
+// SnakeCase maps Go names to lower case words separated by underscores:
+// HTTPServerPort becomes http_server_port.
+func SnakeCase(goName string) string {
+	return strings.Join(lowerWords(goName), "_")
+}
+
+// KebabCase maps Go names to lower case words separated by dashes:
+// HTTPServerPort becomes http-server-port.
+func KebabCase(goName string) string {
+	return strings.Join(lowerWords(goName), "-")
+}
+
+// CamelCase maps Go names to words starting with an upper case letter,
+// except the first one: HTTPServerPort becomes httpServerPort. It also maps
+// snake_case and kebab-case keys to camel case, for Decoder.WithKeyMapper:
+// http-server-port becomes httpServerPort.
+func CamelCase(goName string) string {
+	words := lowerWords(goName)
+	for i := 1; i < len(words); i++ {
+		r := []rune(words[i])
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, "")
+}
+
+func lowerWords(goName string) []string {
+	words := splitWords(goName)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+	return words
+}
+
+// splitWords splits a Go name into words at case changes, underscores and
+// dashes, keeping acronyms and digits together: HTTPServer2Port is split into
+// HTTP, Server2 and Port.
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if runes[i] == '_' || runes[i] == '-' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if !unicode.IsUpper(runes[i]) || i == start {
+			continue
+		}
+		prev := runes[i-1]
+		nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if !unicode.IsUpper(prev) || nextIsLower {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
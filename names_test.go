@@ -0,0 +1,96 @@
+package toml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFieldNameMappers(t *testing.T) {
+	tests := []struct {
+		goName, snake, kebab, camel string
+	}{
+		{"Name", "name", "name", "name"},
+		{"HTTPServerPort", "http_server_port", "http-server-port", "httpServerPort"},
+		{"MaxConnsPerIP", "max_conns_per_ip", "max-conns-per-ip", "maxConnsPerIp"},
+		{"Retry2Delay", "retry2_delay", "retry2-delay", "retry2Delay"},
+		{"Legacy_Field", "legacy_field", "legacy-field", "legacyField"},
+		{"ID", "id", "id", "id"},
+		{"listen-addr", "listen_addr", "listen-addr", "listenAddr"},
+	}
+	for _, test := range tests {
+		if got := SnakeCase(test.goName); got != test.snake {
+			t.Errorf("SnakeCase(%q) = %q, expected %q", test.goName, got, test.snake)
+		}
+		if got := KebabCase(test.goName); got != test.kebab {
+			t.Errorf("KebabCase(%q) = %q, expected %q", test.goName, got, test.kebab)
+		}
+		if got := CamelCase(test.goName); got != test.camel {
+			t.Errorf("CamelCase(%q) = %q, expected %q", test.goName, got, test.camel)
+		}
+	}
+}
+
+func TestFieldNameMapperRoundTrip(t *testing.T) {
+	type server struct {
+		ListenAddr  string
+		MaxBodySize int64 `toml:"body_limit"`
+	}
+	type config struct {
+		HTTPServer server
+		LogLevel   string
+	}
+	in := config{HTTPServer: server{ListenAddr: ":80", MaxBodySize: 1024}, LogLevel: "info"}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).WithFieldNameMapper(KebabCase).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	expected := `log-level = "info"
+
+[http-server]
+  body_limit = 1024
+  listen-addr = ":80"
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	var out config
+	if err := NewDecoder(&buf).WithFieldNameMapper(KebabCase).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v, expected %+v", out, in)
+	}
+}
+
+func TestDecoderKeyMapper(t *testing.T) {
+	type config struct {
+		HTTPServerPort int
+		LogLevel       string
+		Name           string `toml:"service_name"`
+	}
+	doc := `
+http-server-port = 8080
+log_level = "debug"
+service_name = "api"
+`
+	var c config
+	if err := NewDecoder(strings.NewReader(doc)).WithKeyMapper(CamelCase).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	expected := config{HTTPServerPort: 8080, LogLevel: "debug", Name: "api"}
+	if c != expected {
+		t.Errorf("got %+v, expected %+v", c, expected)
+	}
+
+	c = config{}
+	if err := NewDecoder(strings.NewReader(doc)).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.HTTPServerPort != 0 || c.LogLevel != "" {
+		t.Errorf("keys should not be mapped by default, got %+v", c)
+	}
+}
@@ -0,0 +1,86 @@
+// Numbers kept in TOML syntax.
+
+package toml
+
+import (
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Number is a TOML integer or float, represented in TOML syntax. The
+// Decoder produces Numbers instead of int64 and float64 values when UseNumber
+// is set, and for fields of type Number. The Numbers of keys are the literals
+// of the document, such as 0xff or 1_000, and the ones of array elements are
+// written in decimal. A Number is marshaled as is.
+type Number string
+
+var numberType = reflect.TypeOf(Number(""))
+
+// String returns the TOML representation of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// IsInteger reports whether the number is a TOML integer.
+func (n Number) IsInteger() bool {
+	_, err := n.Int64()
+	return err == nil
+}
+
+// Int64 returns the number as an integer. It fails for floats and for
+// integers that do not fit in an int64.
+func (n Number) Int64() (int64, error) {
+	s := strings.Replace(string(n), "_", "", -1)
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "0x"):
+		base, s = 16, s[2:]
+	case strings.HasPrefix(s, "0o"):
+		base, s = 8, s[2:]
+	case strings.HasPrefix(s, "0b"):
+		base, s = 2, s[2:]
+	}
+	return strconv.ParseInt(s, base, 64)
+}
+
+// Float64 returns the number as a float, converting integers.
+func (n Number) Float64() (float64, error) {
+	if i, err := n.Int64(); err == nil {
+		return float64(i), nil
+	}
+	return strconv.ParseFloat(strings.Replace(string(n), "_", "", -1), 64)
+}
+
+// MarshalTOML writes the number without quotes.
+func (n Number) MarshalTOML() ([]byte, error) {
+	return []byte(n), nil
+}
+
+// Return tval as a Number if it is a TOML integer or float.
+func toNumber(tval interface{}) (Number, bool) {
+	switch tval.(type) {
+	case Number:
+		return tval.(Number), true
+	case int64, uint64, float64, *big.Int, *big.Float:
+		s, err := tomlValueStringRepresentation(tval, "", "", OrderAlphabetical, false, "\n")
+		return Number(s), err == nil
+	}
+	return "", false
+}
+
+// withNumberLiteral returns the number at key of tval as the Number written
+// in the document, when it is decoded as a Number, and val otherwise.
+func (d *Decoder) withNumberLiteral(mtype reflect.Type, tval *Tree, key string, val interface{}) interface{} {
+	for mtype.Kind() == reflect.Ptr {
+		mtype = mtype.Elem()
+	}
+	if mtype != numberType && (mtype.Kind() != reflect.Interface || !d.useNumber) {
+		return val
+	}
+	if tv, ok := tval.values[key].(*tomlValue); ok && tv.number != "" {
+		return Number(tv.number)
+	}
+	return val
+}
@@ -0,0 +1,170 @@
+package toml
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNumber(t *testing.T) {
+	for _, test := range []struct {
+		n     Number
+		i     int64
+		isInt bool
+		f     float64
+	}{
+		{"42", 42, true, 42},
+		{"-1_000", -1000, true, -1000},
+		{"0xdead_beef", 0xdeadbeef, true, 0xdeadbeef},
+		{"0o755", 0755, true, 0755},
+		{"0b101", 5, true, 5},
+		{"3.5e2", 0, false, 350},
+		{"1_000.5", 0, false, 1000.5},
+		{"-inf", 0, false, math.Inf(-1)},
+	} {
+		i, err := test.n.Int64()
+		if test.isInt != (err == nil) || i != test.i || test.n.IsInteger() != test.isInt {
+			t.Errorf("%s: unexpected integer %d, %v", test.n, i, err)
+		}
+		if f, err := test.n.Float64(); err != nil || f != test.f {
+			t.Errorf("%s: unexpected float %v, %v", test.n, f, err)
+		}
+	}
+}
+
+func TestDecoderUseNumber(t *testing.T) {
+	input := `
+a = 9007199254740993
+b = 1.5
+c = "text"
+d = [1, 2.0]
+
+[e]
+f = 0xff
+`
+	var m map[string]interface{}
+	if err := NewDecoder(strings.NewReader(input)).UseNumber().Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"a": Number("9007199254740993"),
+		"b": Number("1.5"),
+		"c": "text",
+		"d": []interface{}{Number("1"), Number("2.0")},
+		"e": map[string]interface{}{"f": Number("0xff")},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Bad decode. Expected %#v, got %#v", expected, m)
+	}
+	if i, _ := m["a"].(Number).Int64(); i != 9007199254740993 {
+		t.Errorf("precision lost: %d", i)
+	}
+
+	if err := NewDecoder(strings.NewReader(input)).Decode(&m); err != nil || m["a"] != int64(9007199254740993) {
+		t.Errorf("numbers should not be used by default, got %#v, %v", m["a"], err)
+	}
+}
+
+func TestNumberField(t *testing.T) {
+	var config struct {
+		Size  Number
+		Ratio Number
+		Name  string
+	}
+	if err := Unmarshal([]byte("Size = 1_024\nRatio = 2.5e-1\nName = 'n'"), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Size != "1_024" || config.Ratio != "2.5e-1" {
+		t.Errorf("unexpected numbers: %+v", config)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Order(OrderPreserve).Encode(config); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Size = 1_024\nRatio = 2.5e-1\nName = \"n\"\n"
+	if buf.String() != expected {
+		t.Errorf("Bad marshal. Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+
+	for _, doc := range []string{"Size = 'abc'", "Size = true", "Size = [1]"} {
+		if err := Unmarshal([]byte(doc), &config); err == nil {
+			t.Errorf("%s: expected an error, got %q", doc, config.Size)
+		}
+	}
+}
+
+func TestDecoderBigNumbers(t *testing.T) {
+	input := `
+huge = 123456789012345678901234567890
+mask = 0xffff_ffff_ffff_ffff_ff
+small = 42
+far = 1e400
+`
+	var config struct {
+		Huge  *big.Int
+		Mask  big.Int
+		Small big.Int
+		Far   *big.Float
+	}
+	if err := NewDecoder(strings.NewReader(input)).BigNumbers().Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Huge.String() != "123456789012345678901234567890" {
+		t.Errorf("unexpected huge: %s", config.Huge)
+	}
+	if config.Mask.Text(16) != "ffffffffffffffffff" || config.Small.Int64() != 42 {
+		t.Errorf("unexpected integers: %s, %s", config.Mask.String(), config.Small.String())
+	}
+	if config.Far.Text('e', -1) != "1e+400" {
+		t.Errorf("unexpected float: %s", config.Far.Text('e', -1))
+	}
+
+	var m map[string]interface{}
+	err := NewDecoder(strings.NewReader(input)).Decode(&m)
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("big numbers should be rejected by default, got %v", err)
+	}
+	if err := NewDecoder(strings.NewReader(input)).BigNumbers().Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if i, ok := m["huge"].(*big.Int); !ok || i.Cmp(config.Huge) != 0 {
+		t.Errorf("unexpected huge: %#v", m["huge"])
+	}
+	if err := NewDecoder(strings.NewReader(input)).BigNumbers().UseNumber().Decode(&m); err != nil || m["huge"] != Number("123456789012345678901234567890") {
+		t.Errorf("unexpected number: %#v, %v", m["huge"], err)
+	}
+
+	var small struct {
+		Huge int64
+	}
+	err = NewDecoder(strings.NewReader(input)).BigNumbers().Decode(&small)
+	if err == nil || !strings.Contains(err.Error(), "Can't convert") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Load(input); err == nil {
+		t.Error("Load should still reject integers out of range")
+	}
+
+	// numbers out of range are rejected even when their key is not decoded
+	var unused struct{ B int }
+	err = NewDecoder(strings.NewReader("a = 99999999999999999999\nB = 1")).Decode(&unused)
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNumberLiteralOfReplacedValue(t *testing.T) {
+	tree, err := Load("n = 0x10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Values()["n"].(*PubTOMLValue).SetValue(int64(5))
+	var config struct{ N Number }
+	if err := tree.Unmarshal(&config); err != nil || config.N != "5" {
+		t.Errorf("the literal of a replaced number should be dropped, got %q, %v", config.N, err)
+	}
+}
@@ -6,18 +6,36 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// parseOptions are the options of the parser, set up by the Decoder.
+type parseOptions struct {
+	bigNumbers   bool // keep numbers out of the range of int64 and float64 as math/big values
+	finiteFloats bool // reject nan and inf
+	limits       Limits
+	duplicates   DuplicatePolicy
+}
+
 type tomlParser struct {
+	parseOptions
 	flowIdx       int
 	flow          []token
 	tree          *Tree
 	currentTable  []string
 	seenTableKeys []string
+	literal       string // literal of the last value, when it cannot be rebuilt from the value
+	number        string // literal of the last value, when it is a number
+	keys          int    // number of keys and table headers seen
+	depth         int    // depth of the value being parsed
+
+	notes lexerNotes
+	repr  StringRepresentation // of the last value, when it is a string
+	value interface{}          // of the last key/value Token, as in a Tree
 }
 
 type tomlParserStateFn func() tomlParserStateFn
@@ -96,6 +114,8 @@ func (p *tomlParser) parseGroupArray() tomlParserStateFn {
 	if err != nil {
 		p.raiseError(key, "invalid table array key: %s", err)
 	}
+	p.countKey(key)
+	p.checkDepth(key, len(keys))
 	p.tree.createSubTree(keys[:len(keys)-1], startToken.Position) // create parent entries
 	destTree := p.tree.GetPath(keys)
 	var array []*Tree
@@ -111,6 +131,7 @@ func (p *tomlParser) parseGroupArray() tomlParserStateFn {
 	// add a new tree to the end of the table array
 	newTree := newTree()
 	newTree.position = startToken.Position
+	newTree.format = p.notes.directives[startToken.Line-1]
 	array = append(array, newTree)
 	p.tree.SetPath(p.currentTable, array)
 
@@ -144,7 +165,7 @@ func (p *tomlParser) parseGroup() tomlParserStateFn {
 		p.raiseError(key, "unexpected token %s, was expecting a table key", key)
 	}
 	for _, item := range p.seenTableKeys {
-		if item == key.val {
+		if item == key.val && p.duplicates == DuplicateError {
 			p.raiseError(key, "duplicated tables")
 		}
 	}
@@ -154,6 +175,8 @@ func (p *tomlParser) parseGroup() tomlParserStateFn {
 	if err != nil {
 		p.raiseError(key, "invalid table array key: %s", err)
 	}
+	p.countKey(key)
+	p.checkDepth(key, len(keys))
 	if err := p.tree.createSubTree(keys, startToken.Position); err != nil {
 		p.raiseError(key, "%s", err)
 	}
@@ -162,6 +185,9 @@ func (p *tomlParser) parseGroup() tomlParserStateFn {
 		p.raiseError(key, "could not re-define exist inline table or its sub-table : %s",
 			strings.Join(keys, "."))
 	}
+	if format, ok := p.notes.directives[startToken.Line-1]; ok {
+		destTree.(*Tree).format = format
+	}
 	p.assume(tokenRightBracket)
 	p.currentTable = keys
 	return p.parseStart
@@ -175,7 +201,13 @@ func (p *tomlParser) parseAssign() tomlParserStateFn {
 	if err != nil {
 		p.raiseError(key, "invalid key: %s", err.Error())
 	}
+	p.countKey(key)
+	p.depth = len(p.currentTable) + len(parsedKey)
+	p.checkDepth(key, p.depth)
 
+	p.literal = ""
+	p.number = ""
+	p.repr = StringRepresentation{}
 	value := p.parseRvalue()
 	var tableKey []string
 	if len(p.currentTable) > 0 {
@@ -215,21 +247,87 @@ func (p *tomlParser) parseAssign() tomlParserStateFn {
 	localKey := []string{keyVal}
 	finalKey := append(tableKey, keyVal)
 	if targetNode.GetPath(localKey) != nil {
-		p.raiseError(key, "The following key was defined twice: %s",
-			strings.Join(finalKey, "."))
+		switch p.duplicates {
+		case DuplicateKeepFirst:
+			return p.parseStart
+		case DuplicateError:
+			p.raiseError(key, "The following key was defined twice: %s",
+				strings.Join(finalKey, "."))
+		}
 	}
 	var toInsert interface{}
 
-	switch value.(type) {
-	case *Tree, []*Tree:
+	// the comment written after the value, on its last line
+	trailing := p.notes.comments[p.flow[p.flowIdx-1].Line]
+	switch node := value.(type) {
+	case *Tree:
+		// inline tables start at their key, after their toml-fmt comment
+		node.position = key.Position
+		node.format = p.notes.directives[key.Position.Line-1]
+		node.trailing = trailing
+		toInsert = value
+	case []*Tree:
 		toInsert = value
 	default:
-		toInsert = &tomlValue{value: value, position: key.Position}
+		tv := &tomlValue{value: value, position: key.Position}
+		if _, isArray := value.([]interface{}); !isArray {
+			tv.raw = p.literal
+			tv.repr = p.repr
+			tv.number = p.number
+		}
+		tv.trailing = trailing
+		toInsert = tv
 	}
 	targetNode.values[keyVal] = toInsert
 	return p.parseStart
 }
 
+func isRangeError(err error) bool {
+	numErr, ok := err.(*strconv.NumError)
+	return ok && numErr.Err == strconv.ErrRange
+}
+
+// Parse an integer that does not fit in an int64, if enabled.
+func (p *tomlParser) bigInteger(cleanedVal string, err error) *big.Int {
+	if !p.bigNumbers || !isRangeError(err) {
+		return nil
+	}
+	digits, base := cleanedVal, 10
+	if len(digits) >= 3 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		digits = digits[2:]
+	}
+	i, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil
+	}
+	return i
+}
+
+// Parse a float that does not fit in a float64, if enabled.
+func (p *tomlParser) bigFloat(cleanedVal string, err error) *big.Float {
+	if !p.bigNumbers || !isRangeError(err) {
+		return nil
+	}
+	// at least log2(10) bits per digit
+	prec := uint(len(cleanedVal)) * 4
+	if prec < 64 {
+		prec = 64
+	}
+	f, _, err := big.ParseFloat(cleanedVal, 10, prec, big.ToNearestEven)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
 var errInvalidUnderscore = errors.New("invalid use of _ in number")
 
 func numberContainsInvalidUnderscore(value string) error {
@@ -279,17 +377,24 @@ func (p *tomlParser) parseRvalue() interface{} {
 
 	switch tok.typ {
 	case tokenString:
+		p.repr = p.notes.reprs[tok.Position]
 		return tok.val
 	case tokenTrue:
 		return true
 	case tokenFalse:
 		return false
 	case tokenInf:
+		if p.finiteFloats {
+			p.raiseError(tok, "%s is not allowed, floats must be finite", tok.val)
+		}
 		if tok.val[0] == '-' {
 			return math.Inf(-1)
 		}
 		return math.Inf(1)
 	case tokenNan:
+		if p.finiteFloats {
+			p.raiseError(tok, "%s is not allowed, floats must be finite", tok.val)
+		}
 		return math.NaN()
 	case tokenInteger:
 		cleanedVal := cleanupNumberToken(tok.val)
@@ -326,8 +431,13 @@ func (p *tomlParser) parseRvalue() interface{} {
 			val, err = strconv.ParseInt(cleanedVal, 10, 64)
 		}
 		if err != nil {
+			if i := p.bigInteger(cleanedVal, err); i != nil {
+				p.keepNumberLiteral(tok.val)
+				return i
+			}
 			p.raiseError(tok, "%s", err)
 		}
+		p.keepNumberLiteral(tok.val)
 		return val
 	case tokenFloat:
 		err := numberContainsInvalidUnderscore(tok.val)
@@ -337,14 +447,20 @@ func (p *tomlParser) parseRvalue() interface{} {
 		cleanedVal := cleanupNumberToken(tok.val)
 		val, err := strconv.ParseFloat(cleanedVal, 64)
 		if err != nil {
+			if f := p.bigFloat(cleanedVal, err); f != nil {
+				p.keepNumberLiteral(tok.val)
+				return f
+			}
 			p.raiseError(tok, "%s", err)
 		}
+		p.keepNumberLiteral(tok.val)
 		return val
 	case tokenLocalTime:
 		val, err := ParseLocalTime(tok.val)
 		if err != nil {
 			p.raiseError(tok, "%s", err)
 		}
+		p.keepLiteral(tok.val, tok.val, 9)
 		return val
 	case tokenLocalDate:
 		// a local date may be followed by:
@@ -370,6 +486,7 @@ func (p *tomlParser) parseRvalue() interface{} {
 			if err != nil {
 				p.raiseError(tok, "%s", err)
 			}
+			p.keepLiteral(localTime.val, v, 9)
 			return val
 		}
 
@@ -381,6 +498,8 @@ func (p *tomlParser) parseRvalue() interface{} {
 		if err != nil {
 			p.raiseError(tok, "%s", err)
 		}
+		// time.Time values are written without fractional seconds
+		p.keepLiteral(localTime.val, v, 0)
 		return val
 	case tokenLeftBracket:
 		return p.parseArray()
@@ -397,6 +516,33 @@ func (p *tomlParser) parseRvalue() interface{} {
 	return nil
 }
 
+// keepLiteral records the literal of a date-time whose fractional seconds
+// are not written back from its value, with more than digits digits, so that
+// it can be written back as is.
+func (p *tomlParser) keepLiteral(localTime, literal string, digits int) {
+	idx := strings.IndexByte(localTime, '.')
+	if idx >= 0 && len(localTime)-idx-1 > digits {
+		p.literal = literal
+	}
+}
+
+// keepNumberLiteral records the literal of a number, and keeps it to be
+// written back when it uses underscores to separate groups of digits.
+func (p *tomlParser) keepNumberLiteral(literal string) {
+	p.number = literal
+	if strings.Contains(literal, "_") {
+		p.literal = literal
+	}
+}
+
+func isDateTimeValue(v interface{}) bool {
+	switch v.(type) {
+	case time.Time, LocalDateTime, LocalTime:
+		return true
+	}
+	return false
+}
+
 func tokenIsComma(t *token) bool {
 	return t != nil && t.typ == tokenComma
 }
@@ -425,8 +571,13 @@ Loop:
 			if err != nil {
 				p.raiseError(key, "invalid key: %s", err)
 			}
+			p.countKey(key)
+			depth := p.depth
+			p.depth += len(parsedKey)
+			p.checkDepth(key, p.depth)
 
 			value := p.parseRvalue()
+			p.depth = depth
 			tree.SetPath(parsedKey, value)
 		case tokenComma:
 			if tokenIsComma(previous) {
@@ -448,6 +599,8 @@ Loop:
 func (p *tomlParser) parseArray() interface{} {
 	var array []interface{}
 	arrayType := reflect.TypeOf(newTree())
+	p.depth++
+	p.checkDepth(p.peek(), p.depth)
 	for {
 		follow := p.peek()
 		if follow == nil || follow.typ == tokenEOF {
@@ -473,6 +626,7 @@ func (p *tomlParser) parseArray() interface{} {
 			p.getToken()
 		}
 	}
+	p.depth--
 
 	// if the array is a mixed-type array or its length is 0,
 	// don't convert it to a table array
@@ -493,12 +647,14 @@ func (p *tomlParser) parseArray() interface{} {
 	return array
 }
 
-func parseToml(flow []token) *Tree {
+func parseToml(flow []token, notes lexerNotes, opts parseOptions) *Tree {
 	result := newTree()
 	result.position = Position{1, 1}
 	parser := &tomlParser{
+		parseOptions:  opts,
 		flowIdx:       0,
 		flow:          flow,
+		notes:         notes,
 		tree:          result,
 		currentTable:  make([]string, 0),
 		seenTableKeys: make([]string, 0),
@@ -948,7 +948,7 @@ func TestTomlValueStringRepresentation(t *testing.T) {
 		{[]interface{}{"gamma", "delta"}, "[\"gamma\", \"delta\"]"},
 		{nil, ""},
 	} {
-		result, err := tomlValueStringRepresentation(item.Value, "", "", OrderAlphabetical, false)
+		result, err := tomlValueStringRepresentation(item.Value, "", "", OrderAlphabetical, false, "\n")
 		if err != nil {
 			t.Errorf("Test %d - unexpected error: %s", idx, err)
 		}
@@ -1162,3 +1162,22 @@ str3 = """\
 		t.Errorf("expected '%s', got '%s'", expected, got)
 	}
 }
+
+func TestTrailingComments(t *testing.T) {
+	tree, err := Load(`# header
+a = 1 # one
+b = [
+  2, # ignored
+] # two
+c = 3
+d = { x = 5 } # three
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Set("a", int64(4))
+	expected := "a = 4 # one\nb = [2] # two\nc = 3\n\n[d] # three\n  x = 5\n"
+	if got := tree.String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -63,6 +63,10 @@
 //                    Named filter expression - the function 'filter' is
 //                    used to filter children at this node.
 //
+//   [?(@.key op value)]
+//                    Predicate - selects children whose 'key' compares to
+//                    a string, number or boolean 'value'.
+//
 // Query Indexes And Slices
 //
 // Index expressions perform no bounds checking, and will contribute no
@@ -122,6 +126,26 @@
 //   bool
 //          Allows nodes of type bool.
 //
+// Query Predicates
+//
+// Filter expressions starting with '@' are predicates on the children of the
+// node. '@' designates the child, and may be followed by keys to test a
+// value of the child. Without an operator, the predicate checks that the key
+// exists:
+//
+//   // select the hosts of the servers that have a role
+//   query.CompileAndExecute("$.servers[?(@.role)].host", tree)
+//
+// The operators ==, !=, <, <=, > and >= compare the value to a string, a
+// number or a boolean. Integers and floats compare with each other. Values of
+// other types never match, except with !=.
+//
+//   // select the hosts of the database servers
+//   query.CompileAndExecute("$.servers[?(@.role == 'db')].host", tree)
+//
+//   // select the ports above 1024
+//   query.CompileAndExecute("$.ports[?(@ > 1024)]", tree)
+//
 // Query Results
 //
 // An executed query returns a Result object. This contains the nodes
@@ -134,6 +158,12 @@
 //       fmt.Println("%v: %v", results.Positions()[idx], value)
 //   }
 //
+// The values of a Result can be replaced in the queried tree with Set, for
+// example to rewrite a setting wherever it appears:
+//
+//   results := query.CompileAndExecute("$..timeout", tree)
+//   results.Set(int64(30))
+//
 // Compiled Queries
 //
 // Queries may be executed directly on a Tree object, or compiled ahead
@@ -166,6 +166,24 @@ func (l *queryLexer) lexVoid() queryLexStateFn {
 			l.pos++
 			l.emit(tokenColon)
 			continue
+		case '@':
+			l.pos++
+			l.emit(tokenAt)
+			continue
+		case '=', '!', '<', '>':
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+				if l.follow(candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return l.errorf("unexpected char: '%v'", next)
+			}
+			l.pos += len(op)
+			l.emit(tokenComparison)
+			continue
 		case '\'':
 			l.ignore()
 			l.stringTerm = string(next)
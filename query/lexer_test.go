@@ -177,3 +177,16 @@ func TestLexUnknown(t *testing.T) {
 		{toml.Position{1, 1}, tokenError, "unexpected char: '94'"},
 	})
 }
+
+func TestLexPredicate(t *testing.T) {
+	testQLFlow(t, "@.a<=1 != ==", []token{
+		{toml.Position{1, 1}, tokenAt, "@"},
+		{toml.Position{1, 2}, tokenDot, "."},
+		{toml.Position{1, 3}, tokenKey, "a"},
+		{toml.Position{1, 4}, tokenComparison, "<="},
+		{toml.Position{1, 6}, tokenInteger, "1"},
+		{toml.Position{1, 8}, tokenComparison, "!="},
+		{toml.Position{1, 11}, tokenComparison, "=="},
+		{toml.Position{1, 13}, tokenEOF, ""},
+	})
+}
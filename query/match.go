@@ -2,7 +2,9 @@ package query
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"strings"
 
 	"github.com/pelletier/go-toml"
 )
@@ -30,7 +32,7 @@ func (f *terminatingFn) setNext(next pathFn) {
 }
 
 func (f *terminatingFn) call(node interface{}, ctx *queryContext) {
-	ctx.result.appendResult(node, ctx.lastPosition)
+	ctx.result.appendResult(node, ctx.lastPosition, ctx.lastSet)
 }
 
 // match single key
@@ -49,6 +51,7 @@ func (f *matchKeyFn) call(node interface{}, ctx *queryContext) {
 			item := tree.GetPath([]string{f.Name})
 			if item != nil {
 				ctx.lastPosition = tree.GetPositionPath([]string{f.Name})
+				ctx.lastSet = treeSetter(tree, f.Name)
 				f.next.call(item, ctx)
 			}
 		}
@@ -56,6 +59,7 @@ func (f *matchKeyFn) call(node interface{}, ctx *queryContext) {
 		item := tree.GetPath([]string{f.Name})
 		if item != nil {
 			ctx.lastPosition = tree.GetPositionPath([]string{f.Name})
+			ctx.lastSet = treeSetter(tree, f.Name)
 			f.next.call(item, ctx)
 		}
 	}
@@ -84,16 +88,47 @@ func (f *matchIndexFn) call(node interface{}, ctx *queryContext) {
 			idx += v.Len()
 		}
 		if 0 <= idx && idx < v.Len() {
-			callNextIndexSlice(f.next, node, ctx, v.Index(idx).Interface())
+			callNextIndexSlice(f.next, node, ctx, idx)
 		}
 	}
 }
 
-func callNextIndexSlice(next pathFn, node interface{}, ctx *queryContext, value interface{}) {
+func callNextIndexSlice(next pathFn, node interface{}, ctx *queryContext, idx int) {
 	if treesArray, ok := node.([]*toml.Tree); ok {
 		ctx.lastPosition = treesArray[0].Position()
 	}
-	next.call(value, ctx)
+	ctx.lastSet = arraySetter(node, idx)
+	next.call(reflect.ValueOf(node).Index(idx).Interface(), ctx)
+}
+
+// returns a function replacing the value of key in tree, keeping its position
+// and, for values replaced by values, its comments and formatting
+func treeSetter(tree *toml.Tree, key string) func(value interface{}) {
+	return func(value interface{}) {
+		if tv, ok := tree.Values()[key].(*toml.PubTOMLValue); ok {
+			switch value.(type) {
+			case *toml.Tree, []*toml.Tree:
+			default:
+				tv.SetValue(value)
+				return
+			}
+		}
+		pos := tree.GetPositionPath([]string{key})
+		tree.SetPath([]string{key}, value)
+		tree.SetPositionPath([]string{key}, pos)
+	}
+}
+
+// returns a function replacing the element idx of array, or nil if the
+// elements of array cannot be replaced
+func arraySetter(array interface{}, idx int) func(value interface{}) {
+	values, ok := array.([]interface{})
+	if !ok {
+		return nil
+	}
+	return func(value interface{}) {
+		values[idx] = value
+	}
 }
 
 // filter by slicing
@@ -172,11 +207,11 @@ func (f *matchSliceFn) call(node interface{}, ctx *queryContext) {
 		// Loop on values
 		if step > 0 {
 			for idx := start; idx < end; idx += step {
-				callNextIndexSlice(f.next, node, ctx, v.Index(idx).Interface())
+				callNextIndexSlice(f.next, node, ctx, idx)
 			}
 		} else {
 			for idx := start; idx > end; idx += step {
-				callNextIndexSlice(f.next, node, ctx, v.Index(idx).Interface())
+				callNextIndexSlice(f.next, node, ctx, idx)
 			}
 		}
 	}
@@ -210,6 +245,7 @@ func (f *matchAnyFn) call(node interface{}, ctx *queryContext) {
 		for _, k := range tree.Keys() {
 			v := tree.GetPath([]string{k})
 			ctx.lastPosition = tree.GetPositionPath([]string{k})
+			ctx.lastSet = treeSetter(tree, k)
 			f.next.call(v, ctx)
 		}
 	}
@@ -243,12 +279,14 @@ func newMatchRecursiveFn() *matchRecursiveFn {
 
 func (f *matchRecursiveFn) call(node interface{}, ctx *queryContext) {
 	originalPosition := ctx.lastPosition
+	originalSet := ctx.lastSet
 	if tree, ok := node.(*toml.Tree); ok {
 		var visit func(tree *toml.Tree)
 		visit = func(tree *toml.Tree) {
 			for _, k := range tree.Keys() {
 				v := tree.GetPath([]string{k})
 				ctx.lastPosition = tree.GetPositionPath([]string{k})
+				ctx.lastSet = treeSetter(tree, k)
 				f.next.call(v, ctx)
 				switch node := v.(type) {
 				case *toml.Tree:
@@ -261,27 +299,39 @@ func (f *matchRecursiveFn) call(node interface{}, ctx *queryContext) {
 			}
 		}
 		ctx.lastPosition = originalPosition
+		ctx.lastSet = originalSet
 		f.next.call(tree, ctx)
 		visit(tree)
 	}
 }
 
-// match based on an externally provided functional filter
+// match based on an externally provided functional filter, or on a predicate
 type matchFilterFn struct {
 	matchBase
 	Pos  toml.Position
 	Name string
+	fn   NodeFilterFn
 }
 
 func newMatchFilterFn(name string, pos toml.Position) *matchFilterFn {
 	return &matchFilterFn{Name: name, Pos: pos}
 }
 
+func newMatchPredicateFn(path []string, op string, value interface{}) *matchFilterFn {
+	return &matchFilterFn{fn: func(node interface{}) bool {
+		return evalPredicate(node, path, op, value)
+	}}
+}
+
 func (f *matchFilterFn) call(node interface{}, ctx *queryContext) {
-	fn, ok := (*ctx.filters)[f.Name]
-	if !ok {
-		panic(fmt.Sprintf("%s: query context does not have filter '%s'",
-			f.Pos.String(), f.Name))
+	fn := f.fn
+	if fn == nil {
+		var ok bool
+		fn, ok = (*ctx.filters)[f.Name]
+		if !ok {
+			panic(fmt.Sprintf("%s: query context does not have filter '%s'",
+				f.Pos.String(), f.Name))
+		}
 	}
 	switch castNode := node.(type) {
 	case *toml.Tree:
@@ -289,6 +339,7 @@ func (f *matchFilterFn) call(node interface{}, ctx *queryContext) {
 			v := castNode.GetPath([]string{k})
 			if fn(v) {
 				ctx.lastPosition = castNode.GetPositionPath([]string{k})
+				ctx.lastSet = treeSetter(castNode, k)
 				f.next.call(v, ctx)
 			}
 		}
@@ -298,14 +349,110 @@ func (f *matchFilterFn) call(node interface{}, ctx *queryContext) {
 				if len(castNode) > 0 {
 					ctx.lastPosition = castNode[0].Position()
 				}
+				ctx.lastSet = nil
 				f.next.call(v, ctx)
 			}
 		}
 	case []interface{}:
-		for _, v := range castNode {
+		for idx, v := range castNode {
 			if fn(v) {
+				ctx.lastSet = arraySetter(castNode, idx)
 				f.next.call(v, ctx)
 			}
 		}
 	}
 }
+
+// evalPredicate reports whether the value at path in node exists and, when op
+// is set, compares to value as required by op.
+func evalPredicate(node interface{}, path []string, op string, value interface{}) bool {
+	if len(path) > 0 {
+		tree, ok := node.(*toml.Tree)
+		if !ok {
+			return false
+		}
+		node = tree.GetPath(path)
+	}
+	if node == nil {
+		return false
+	}
+	if op == "" {
+		return true
+	}
+	cmp, ok := compareValues(node, value)
+	switch op {
+	case "==":
+		return ok && cmp == 0
+	case "!=":
+		return !ok || cmp != 0
+	case "<":
+		return ok && cmp < 0
+	case "<=":
+		return ok && cmp <= 0
+	case ">":
+		return ok && cmp > 0
+	case ">=":
+		return ok && cmp >= 0
+	}
+	return false
+}
+
+// compareValues compares a value of the tree to a literal of a query. The
+// boolean is false if they cannot be compared.
+func compareValues(node interface{}, value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case string:
+		s, ok := node.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(s, v), true
+	case bool:
+		b, ok := node.(bool)
+		if !ok {
+			return 0, false
+		}
+		if b == v {
+			return 0, true
+		}
+		if v {
+			return -1, true
+		}
+		return 1, true
+	case int64:
+		if i, ok := node.(int64); ok {
+			switch {
+			case i < v:
+				return -1, true
+			case i > v:
+				return 1, true
+			}
+			return 0, true
+		}
+		if f, ok := node.(float64); ok {
+			return compareFloats(f, float64(v))
+		}
+	case float64:
+		if i, ok := node.(int64); ok {
+			return compareFloats(float64(i), v)
+		}
+		if f, ok := node.(float64); ok {
+			return compareFloats(f, v)
+		}
+	}
+	return 0, false
+}
+
+// compareFloats compares two floats, which cannot be compared when one of
+// them is NaN.
+func compareFloats(a, b float64) (int, bool) {
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		return 0, false
+	case a < b:
+		return -1, true
+	case a > b:
+		return 1, true
+	}
+	return 0, true
+}
@@ -9,6 +9,7 @@ package query
 
 import (
 	"fmt"
+	"strconv"
 )
 
 const maxInt = int(^uint(0) >> 1)
@@ -255,6 +256,9 @@ func (p *queryParser) parseFilterExpr() queryParserStateFn {
 		return p.parseError(tok, "expected left-parenthesis for filter expression")
 	}
 	tok = p.getToken()
+	if tok.typ == tokenAt {
+		return p.parsePredicateExpr()
+	}
 	if tok.typ != tokenKey && tok.typ != tokenString {
 		return p.parseError(tok, "expected key or string for filter function name")
 	}
@@ -267,6 +271,50 @@ func (p *queryParser) parseFilterExpr() queryParserStateFn {
 	return p.parseUnionExpr
 }
 
+// handle '@.key op value' in filter expressions, after the '@'
+func (p *queryParser) parsePredicateExpr() queryParserStateFn {
+	var path []string
+	tok := p.getToken()
+	for tok.typ == tokenDot {
+		tok = p.getToken()
+		if tok.typ != tokenKey && tok.typ != tokenString {
+			return p.parseError(tok, "expected key after '.' in filter expression")
+		}
+		path = append(path, tok.val)
+		tok = p.getToken()
+	}
+
+	var op string
+	var value interface{}
+	if tok.typ == tokenComparison {
+		op = tok.val
+		tok = p.getToken()
+		switch {
+		case tok.typ == tokenString:
+			value = tok.val
+		case tok.typ == tokenInteger:
+			value = int64(tok.Int())
+		case tok.typ == tokenFloat:
+			f, err := strconv.ParseFloat(tok.val, 64)
+			if err != nil {
+				return p.parseError(tok, "invalid float: %s", err)
+			}
+			value = f
+		case tok.typ == tokenKey && (tok.val == "true" || tok.val == "false"):
+			value = tok.val == "true"
+		default:
+			return p.parseError(tok, "expected string, number or boolean to compare to, not '%s'", tok.val)
+		}
+		tok = p.getToken()
+	}
+
+	if tok.typ != tokenRightParen {
+		return p.parseError(tok, "expected right-parenthesis for filter expression")
+	}
+	p.union = append(p.union, newMatchPredicateFn(path, op, value))
+	return p.parseUnionExpr
+}
+
 func parseQuery(flow chan token) (*Query, error) {
 	parser := &queryParser{
 		flow:         flow,
@@ -611,3 +611,81 @@ func TestQueryFilterFn(t *testing.T) {
 			queryTestNode{true, toml.Position{15, 1}},
 		})
 }
+
+func TestQueryPredicate(t *testing.T) {
+	doc := `
+ports = [80, 8080, 443]
+
+[[servers]]
+host = "alpha"
+role = "db"
+weight = 1.5
+
+[[servers]]
+host = "beta"
+role = "web"
+weight = 3
+enabled = true
+`
+	assertQueryPositions(t, doc,
+		"$.servers[?(@.role == 'db')].host",
+		[]interface{}{
+			queryTestNode{"alpha", toml.Position{5, 1}},
+		})
+	assertQueryPositions(t, doc,
+		"$.servers[?(@.role != 'db')].host",
+		[]interface{}{
+			queryTestNode{"beta", toml.Position{10, 1}},
+		})
+	assertQueryPositions(t, doc,
+		"$.servers[?(@.weight > 2)].host",
+		[]interface{}{
+			queryTestNode{"beta", toml.Position{10, 1}},
+		})
+	assertQueryPositions(t, doc,
+		"$.servers[?(@.weight <= 1.5)].host",
+		[]interface{}{
+			queryTestNode{"alpha", toml.Position{5, 1}},
+		})
+	assertQueryPositions(t, doc,
+		"$.servers[?(@.enabled)].host",
+		[]interface{}{
+			queryTestNode{"beta", toml.Position{10, 1}},
+		})
+	assertQueryPositions(t, doc,
+		"$.servers[?(@.enabled == false)].host",
+		[]interface{}{})
+	assertQueryPositions(t, doc,
+		"$.servers[?(@.role > 1)].host",
+		[]interface{}{})
+	assertQueryPositions(t, doc,
+		"$.ports[?(@ >= 443)]",
+		[]interface{}{
+			queryTestNode{int64(8080), toml.Position{2, 1}},
+			queryTestNode{int64(443), toml.Position{2, 1}},
+		})
+	assertQueryPositions(t, "ratios = [0.5, nan, 2.0]",
+		"$.ratios[?(@ <= 1)]",
+		[]interface{}{
+			queryTestNode{0.5, toml.Position{1, 1}},
+		})
+	assertQueryPositions(t, "ratios = [0.5, nan, 2.0]",
+		"$.ratios[?(@ >= 0.5)]",
+		[]interface{}{
+			queryTestNode{0.5, toml.Position{1, 1}},
+			queryTestNode{2.0, toml.Position{1, 1}},
+		})
+}
+
+func TestQueryPredicateErrors(t *testing.T) {
+	for _, q := range []string{
+		"$[?(@.)]",
+		"$[?(@.a ==)]",
+		"$[?(@.a == b)]",
+		"$[?(@.a 'b')]",
+	} {
+		if _, err := Compile(q); err == nil {
+			t.Errorf("%s: expected an error", q)
+		}
+	}
+}
@@ -21,12 +21,15 @@ type NodeFilterFn func(node interface{}) bool
 type Result struct {
 	items     []interface{}
 	positions []toml.Position
+	setters   []func(value interface{})
 }
 
-// appends a value/position pair to the result set.
-func (r *Result) appendResult(node interface{}, pos toml.Position) {
+// appends a value/position pair to the result set, with the function
+// replacing the value in the tree, if any.
+func (r *Result) appendResult(node interface{}, pos toml.Position, set func(value interface{})) {
 	r.items = append(r.items, node)
 	r.positions = append(r.positions, pos)
+	r.setters = append(r.setters, set)
 }
 
 // Values is a set of values within a Result.  The order of values is not
@@ -42,11 +45,36 @@ func (r Result) Positions() []toml.Position {
 	return r.positions
 }
 
+// Set replaces each value of the result by value in the queried tree, where
+// the value keeps its position, comments and formatting. value must be of a
+// type accepted by Tree.Set. This allows rewriting all the matches of a query
+// at once:
+//
+//   results, _ := query.CompileAndExecute("$..timeout", tree)
+//   results.Set(int64(30))
+//
+// Only the values of tables and the elements of arrays of values can be
+// replaced: the root of the tree and the tables of arrays of tables are left
+// untouched. Set returns the number of replaced values.
+func (r *Result) Set(value interface{}) int {
+	count := 0
+	for i, set := range r.setters {
+		if set == nil {
+			continue
+		}
+		set(value)
+		r.items[i] = value
+		count++
+	}
+	return count
+}
+
 // runtime context for executing query paths
 type queryContext struct {
 	result       *Result
 	filters      *map[string]NodeFilterFn
 	lastPosition toml.Position
+	lastSet      func(value interface{})
 }
 
 // generic path functor interface
@@ -96,7 +124,7 @@ func (q *Query) Execute(tree *toml.Tree) *Result {
 		positions: []toml.Position{},
 	}
 	if q.root == nil {
-		result.appendResult(tree, tree.GetPosition(""))
+		result.appendResult(tree, tree.GetPosition(""), nil)
 	} else {
 		ctx := &queryContext{
 			result:  result,
@@ -149,3 +149,59 @@ func TestTomlQuery(t *testing.T) {
 		t.Errorf("Expected 'b' with a value 2: %v", tt.Get("b"))
 	}
 }
+
+func TestQueryResultSet(t *testing.T) {
+	tree, err := toml.Load(`
+timeout = 10
+ports = [80, 8080]
+
+[server]
+timeout = 5
+name = "alpha"
+
+[[workers]]
+timeout = 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := CompileAndExecute("$..timeout", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := results.Set(int64(30)); count != 3 {
+		t.Errorf("expected 3 replaced values, got %d", count)
+	}
+	for _, key := range []string{"timeout", "server.timeout"} {
+		if v := tree.Get(key); v != int64(30) {
+			t.Errorf("%s: expected 30, got %v", key, v)
+		}
+	}
+	if v := tree.GetPath([]string{"workers"}).([]*toml.Tree)[0].Get("timeout"); v != int64(30) {
+		t.Errorf("workers.timeout: expected 30, got %v", v)
+	}
+	if pos := tree.GetPosition("server.timeout"); pos.Line != 6 {
+		t.Errorf("expected the position to be kept, got %s", pos)
+	}
+	assertArrayContainsInOrder(t, results.Values(), int64(30), int64(30), int64(30))
+
+	results, _ = CompileAndExecute("$.ports[-1]", tree)
+	results.Set(int64(443))
+	if ports := tree.Get("ports").([]interface{}); ports[1] != int64(443) {
+		t.Errorf("expected port 443, got %v", ports[1])
+	}
+
+	results, _ = CompileAndExecute("$", tree)
+	if count := results.Set(int64(1)); count != 0 {
+		t.Errorf("the root cannot be replaced, got %d replaced values", count)
+	}
+
+	tree.SetPathWithOptions([]string{"server", "name"}, toml.SetOptions{Comment: "name of the server", Commented: true, Multiline: true}, "alpha")
+	results, _ = CompileAndExecute("$.server.name", tree)
+	results.Set("beta")
+	tv := tree.Get("server").(*toml.Tree).Values()["name"].(*toml.PubTOMLValue)
+	if tv.Value() != "beta" || tv.Comment() != "name of the server" || !tv.Commented() || !tv.Multiline() {
+		t.Errorf("expected the comments and formatting to be kept, got %#v", tv)
+	}
+}
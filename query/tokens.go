@@ -32,6 +32,8 @@ const (
 	tokenQuestion
 	tokenDot
 	tokenDotDot
+	tokenAt
+	tokenComparison
 )
 
 var tokenTypeNames = []string{
@@ -52,6 +54,8 @@ var tokenTypeNames = []string{
 	"?",
 	".",
 	"..",
+	"@",
+	"Comparison",
 }
 
 type token struct {
@@ -0,0 +1,401 @@
+// Cross-file references.
+
+package toml
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const refKey = "ref"
+
+// LoadFileWithRefs creates a Tree from a file, resolving references to other
+// files. A reference is a table whose only key is "ref", holding an inline
+// table with a "file" and an optional "key":
+//
+//   [database]
+//   ref = { file = "db.toml", key = "primary" }
+//
+// The table is replaced by the value found at key in the referenced file, or
+// by the whole referenced document when key is omitted. Each file remains a
+// valid TOML document on its own. Relative file names are resolved from the
+// directory of the referencing file. References are resolved recursively, and
+// an error is returned when files reference each other in a cycle.
+//
+// readFile is used to read every file, so that references can be confined to
+// a given file system. When nil, ioutil.ReadFile is used. This package
+// supports Go 1.12, so LoadFileWithRefs takes a function rather than an
+// io/fs.FS, which appeared in Go 1.16; LoadFSWithRefs takes an fs.FS when
+// built with Go 1.16 or later.
+func LoadFileWithRefs(path string, readFile func(name string) ([]byte, error)) (*Tree, error) {
+	if readFile == nil {
+		readFile = ioutil.ReadFile
+	}
+	r := &refResolver{readFile: readFile}
+	return r.load(path)
+}
+
+type refResolver struct {
+	readFile func(name string) ([]byte, error)
+	parse    func(b []byte) (*Tree, error) // LoadBytes when nil
+	stack    []string                      // files being loaded
+}
+
+func (r *refResolver) load(name string) (*Tree, error) {
+	for _, loading := range r.stack {
+		if loading == name {
+			return nil, fmt.Errorf("reference cycle: %s -> %s", strings.Join(r.stack, " -> "), name)
+		}
+	}
+	r.stack = append(r.stack, name)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	b, err := r.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	parse := r.parse
+	if parse == nil {
+		parse = LoadBytes
+	}
+	tree, err := parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if err := r.resolve(name, tree); err != nil {
+		return nil, err
+	}
+	setSource(tree, name)
+	return tree, nil
+}
+
+// setSource records that the values of t come from the file name, except for
+// those of referenced files.
+func setSource(t *Tree, name string) {
+	if t.source != "" {
+		return
+	}
+	t.source = name
+	for _, v := range t.values {
+		switch node := v.(type) {
+		case *Tree:
+			setSource(node, name)
+		case []*Tree:
+			for _, item := range node {
+				setSource(item, name)
+			}
+		case *tomlValue:
+			if node.source == "" {
+				node.source = name
+			}
+		}
+	}
+}
+
+// GetSourceFile returns the name of the file defining the value at the given
+// dot-separated key, for trees loaded with LoadFileWithRefs or decoded with
+// an include resolver. It returns an empty string when the value is not
+// found, or when it is defined in a document that was not read from a file.
+// Positions of values, such as those of GetPosition, are relative to this
+// file.
+func (t *Tree) GetSourceFile(key string) string {
+	keys, err := parseKey(key)
+	if err != nil {
+		return ""
+	}
+	switch node := t.GetPath(keys).(type) {
+	case *Tree:
+		return node.source
+	case []*Tree:
+		if len(node) == 0 {
+			return ""
+		}
+		return node[len(node)-1].source
+	case nil:
+		return ""
+	}
+	parent, ok := t.GetPath(keys[:len(keys)-1]).(*Tree)
+	if !ok {
+		return ""
+	}
+	if tv, ok := parent.values[keys[len(keys)-1]].(*tomlValue); ok && tv.source != "" {
+		return tv.source
+	}
+	return parent.source
+}
+
+func (r *refResolver) resolve(name string, t *Tree) error {
+	for k, v := range t.values {
+		switch node := v.(type) {
+		case *Tree:
+			val, ok, err := r.follow(name, node)
+			if err != nil {
+				return err
+			}
+			if ok {
+				t.values[k] = val
+				continue
+			}
+			if err := r.resolve(name, node); err != nil {
+				return err
+			}
+		case []*Tree:
+			for i, item := range node {
+				val, ok, err := r.follow(name, item)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					if err := r.resolve(name, item); err != nil {
+						return err
+					}
+					continue
+				}
+				tree, isTree := val.(*Tree)
+				if !isTree {
+					return fmt.Errorf("%s: %s: reference in array of tables must point to a table", name, item.position)
+				}
+				node[i] = tree
+			}
+		}
+	}
+	return nil
+}
+
+// WithIncludeResolver makes the decoder include other documents, read with
+// readFile, where the decoded document references them. References are
+// tables whose only key is "ref", as for LoadFileWithRefs:
+//
+//   [database]
+//   ref = { file = "conf.d/database.toml" }
+//
+// Relative paths of the decoded document are given as is to readFile, and
+// those of included documents are resolved from the directory of the
+// including file. An error is returned when documents include each other in a
+// cycle. Use Tree.GetSourceFile to know which file defines a value. Includes
+// are not resolved by Token.
+func (d *Decoder) WithIncludeResolver(readFile func(path string) ([]byte, error)) *Decoder {
+	d.readInclude = readFile
+	return d
+}
+
+func isReference(t *Tree) bool {
+	if len(t.values) != 1 {
+		return false
+	}
+	_, ok := t.values[refKey].(*Tree)
+	return ok
+}
+
+// follow returns the value targeted by t if t is a reference table.
+func (r *refResolver) follow(name string, t *Tree) (interface{}, bool, error) {
+	if !isReference(t) {
+		return nil, false, nil
+	}
+	ref := t.values[refKey].(*Tree)
+	file, ok := ref.Get("file").(string)
+	if !ok || file == "" {
+		return nil, false, fmt.Errorf("%s: %s: reference needs a file", name, ref.position)
+	}
+
+	target := file
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(name), target)
+	}
+	tree, err := r.load(target)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key, _ := ref.Get("key").(string)
+	if key == "" {
+		return tree, true, nil
+	}
+	keys, err := parseKey(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %s: invalid reference key: %s", name, ref.position, err)
+	}
+	switch val := tree.GetPath(keys).(type) {
+	case nil:
+		return nil, false, fmt.Errorf("%s: %s: key %s not found in %s", name, ref.position, key, target)
+	case *Tree, []*Tree:
+		return val, true, nil
+	default:
+		return &tomlValue{value: val, position: tree.GetPositionPath(keys), source: target}, true, nil
+	}
+}
+
+// ExtractTable removes the table at the given dot-separated key from t and
+// returns it, so that it can be written to a file of its own. When refFile is
+// not empty, the table is replaced by a reference to refFile, which
+// LoadFileWithRefs resolves back to the extracted table:
+//
+//   [database]
+//   ref = { file = "db.toml" }
+func (t *Tree) ExtractTable(key string, refFile string) (*Tree, error) {
+	return t.extractTable(key, refFile, "")
+}
+
+// extractTable implements ExtractTable, with the reference pointing to
+// targetKey in refFile when targetKey is not empty.
+func (t *Tree) extractTable(key, refFile, targetKey string) (*Tree, error) {
+	keys, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	parent, ok := t.GetPath(keys[:len(keys)-1]).(*Tree)
+	if !ok {
+		return nil, fmt.Errorf("key %s not found", key)
+	}
+	name := keys[len(keys)-1]
+	value, exists := parent.values[name]
+	if !exists {
+		return nil, fmt.Errorf("key %s not found", key)
+	}
+	table, ok := value.(*Tree)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not a table", key)
+	}
+	if refFile == "" {
+		delete(parent.values, name)
+		return table, nil
+	}
+	ref := newTreeWithPosition(table.position)
+	ref.inline = true
+	ref.values["file"] = &tomlValue{value: refFile, position: table.position}
+	if targetKey != "" {
+		ref.values["key"] = &tomlValue{value: targetKey, position: table.position}
+	}
+	stub := newTreeWithPosition(table.position)
+	stub.values[refKey] = ref
+	parent.values[name] = stub
+	return table, nil
+}
+
+// DuplicateTables returns the groups of tables of t that have the same
+// content, such as copy-pasted settings. Each group lists the dot-separated
+// keys of its tables, in order. Groups are sorted by their first key. Tables
+// nested in duplicate tables are not reported on their own. Empty tables,
+// references and tables of arrays of tables are ignored.
+func (t *Tree) DuplicateTables() [][]string {
+	var tables []keyedTable
+	collectTables(nil, t, &tables)
+
+	var groups [][]keyedTable
+	for _, table := range tables {
+		found := false
+		for i, group := range groups {
+			if reflect.DeepEqual(group[0].content, table.content) {
+				groups[i] = append(group, table)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []keyedTable{table})
+		}
+	}
+
+	duplicated := make(map[string]bool)
+	for _, group := range groups {
+		if len(group) > 1 {
+			for _, table := range group {
+				duplicated[table.key()] = true
+			}
+		}
+	}
+
+	var result [][]string
+	for _, group := range groups {
+		if len(group) < 2 || nestedInDuplicates(group, duplicated) {
+			continue
+		}
+		keys := make([]string, len(group))
+		for i, table := range group {
+			keys[i] = table.key()
+		}
+		sort.Strings(keys)
+		result = append(result, keys)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i][0] < result[j][0]
+	})
+	return result
+}
+
+// ShareDuplicateTables replaces the tables of each group reported by
+// DuplicateTables by references to a single copy, and returns the copies. The
+// copy of a group is stored under the first key of the group. The returned
+// tree is meant to be written to refFile, so that LoadFileWithRefs resolves
+// the references back to the original content:
+//
+//   [service_a.retry]
+//   ref = { file = "shared.toml", key = "service_a.retry" }
+func (t *Tree) ShareDuplicateTables(refFile string) (*Tree, error) {
+	shared := newTree()
+	for {
+		// sharing a group may remove the tables of the next ones
+		groups := t.DuplicateTables()
+		if len(groups) == 0 {
+			return shared, nil
+		}
+		group := groups[0]
+		for i, key := range group {
+			table, err := t.extractTable(key, refFile, group[0])
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				continue
+			}
+			keys, err := parseKey(key)
+			if err != nil {
+				return nil, err
+			}
+			shared.SetPath(keys, table)
+		}
+	}
+}
+
+type keyedTable struct {
+	path    []string
+	content map[string]interface{}
+}
+
+func (k keyedTable) key() string {
+	return Change{Path: k.path}.Key()
+}
+
+func collectTables(path []string, t *Tree, tables *[]keyedTable) {
+	for k, v := range t.values {
+		node, ok := v.(*Tree)
+		if !ok || len(node.values) == 0 || isReference(node) {
+			continue
+		}
+		keyPath := append(append([]string{}, path...), k)
+		*tables = append(*tables, keyedTable{path: keyPath, content: node.ToMap()})
+		collectTables(keyPath, node, tables)
+	}
+}
+
+// nestedInDuplicates reports whether all the tables of group are nested in
+// duplicated tables.
+func nestedInDuplicates(group []keyedTable, duplicated map[string]bool) bool {
+	for _, table := range group {
+		nested := false
+		for i := 1; i < len(table.path); i++ {
+			if duplicated[Change{Path: table.path[:i]}.Key()] {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,20 @@
+// +build go1.16
+
+// Cross-file references within an fs.FS.
+
+package toml
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// LoadFSWithRefs creates a Tree from the file name of fsys, such as an
+// os.DirFS or an embed.FS, resolving references as LoadFileWithRefs does.
+// References are confined to fsys: names are slash-separated paths of fsys,
+// and the ones leaving it, such as ../other.toml from its root, fail.
+func LoadFSWithRefs(fsys fs.FS, name string) (*Tree, error) {
+	return LoadFileWithRefs(name, func(name string) ([]byte, error) {
+		return fs.ReadFile(fsys, filepath.ToSlash(name))
+	})
+}
@@ -0,0 +1,27 @@
+// +build go1.16
+
+package toml
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadFSWithRefs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf/main.toml":  {Data: []byte("[database]\nref = { file = \"db.toml\", key = \"primary\" }\n")},
+		"conf/db.toml":    {Data: []byte("[primary]\nhost = \"db1\"\n")},
+		"conf/other.toml": {Data: []byte("[database]\nref = { file = \"../../secret.toml\" }\n")},
+	}
+	tree, err := LoadFSWithRefs(fsys, "conf/main.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := tree.Get("database.host"); host != "db1" {
+		t.Errorf("unexpected host: %v", host)
+	}
+
+	if _, err := LoadFSWithRefs(fsys, "conf/other.toml"); err == nil {
+		t.Error("references outside of the file system should fail")
+	}
+}
@@ -0,0 +1,288 @@
+package toml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mapReadFile(files map[string]string) func(string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		content, ok := files[filepath.ToSlash(name)]
+		if !ok {
+			return nil, fmt.Errorf("open %s: %s", name, os.ErrNotExist)
+		}
+		return []byte(content), nil
+	}
+}
+
+func TestLoadFileWithRefs(t *testing.T) {
+	files := map[string]string{
+		"conf/main.toml": `
+title = "main"
+
+[database]
+ref = { file = "db.toml", key = "primary" }
+
+[port]
+ref = { file = "db.toml", key = "primary.port" }
+
+[[servers]]
+ref = { file = "servers/a.toml" }
+
+[[servers]]
+name = "b"
+`,
+		"conf/db.toml": `
+[primary]
+host = "db1"
+port = 5432
+`,
+		"conf/servers/a.toml": `name = "a"`,
+	}
+
+	tree, err := LoadFileWithRefs("conf/main.toml", mapReadFile(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Get("database.host") != "db1" {
+		t.Errorf("database should be resolved, got %v", tree.Get("database"))
+	}
+	if tree.Get("port") != int64(5432) {
+		t.Errorf("port should be resolved to a value, got %v", tree.Get("port"))
+	}
+	servers := tree.Get("servers").([]*Tree)
+	if len(servers) != 2 || servers[0].Get("name") != "a" || servers[1].Get("name") != "b" {
+		t.Errorf("unexpected servers: %v", servers)
+	}
+}
+
+func TestLoadFileWithRefsCycle(t *testing.T) {
+	files := map[string]string{
+		"a.toml": `x = { ref = { file = "b.toml" } }`,
+		"b.toml": `y = { ref = { file = "a.toml" } }`,
+	}
+	_, err := LoadFileWithRefs("a.toml", mapReadFile(files))
+	if err == nil {
+		t.Fatal("expected a cycle error")
+	}
+	if !strings.Contains(err.Error(), "reference cycle: a.toml -> b.toml -> a.toml") {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestLoadFileWithRefsErrors(t *testing.T) {
+	for name, doc := range map[string]string{
+		"missing key":  `x = { ref = { file = "b.toml", key = "nope" } }`,
+		"missing file": `x = { ref = { file = "c.toml" } }`,
+		"no file":      `x = { ref = { key = "y" } }`,
+		"array":        "[[x]]\nref = { file = \"b.toml\", key = \"y\" }",
+	} {
+		files := map[string]string{"a.toml": doc, "b.toml": "y = 1"}
+		if _, err := LoadFileWithRefs("a.toml", mapReadFile(files)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestTreeExtractTable(t *testing.T) {
+	tree, _ := Load(`
+title = "main"
+
+[database.primary]
+host = "db1"
+port = 5432
+`)
+	table, err := tree.ExtractTable("database.primary", "db.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Get("host") != "db1" {
+		t.Errorf("unexpected extracted table: %v", table)
+	}
+
+	files := map[string]string{
+		"conf/main.toml": tree.String(),
+		"conf/db.toml":   table.String(),
+	}
+	if !strings.Contains(files["conf/main.toml"], "ref") || strings.Contains(files["conf/main.toml"], "db1") {
+		t.Errorf("table should be replaced by a reference:\n%s", files["conf/main.toml"])
+	}
+	loaded, err := LoadFileWithRefs("conf/main.toml", mapReadFile(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Get("database.primary.port") != int64(5432) || loaded.Get("title") != "main" {
+		t.Errorf("unexpected document:\n%s", loaded)
+	}
+
+	if _, err := tree.ExtractTable("title", ""); err == nil || err.Error() != "key title is not a table" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := tree.ExtractTable("database", ""); err != nil || tree.Has("database") {
+		t.Errorf("table should be removed, got %v", err)
+	}
+}
+
+func TestTreeDuplicateTables(t *testing.T) {
+	doc := `
+[service_a.retry]
+attempts = 3
+backoff = "1s"
+
+[service_b.retry]
+attempts = 3
+backoff = "1s"
+
+[service_b.endpoint]
+url = "http://b"
+
+[service_c.retry]
+attempts = 3
+backoff = "1s"
+
+[x]
+y = 1
+[x.z]
+k = [1, 2]
+
+[w]
+y = 1
+[w.z]
+k = [1, 2]
+`
+	tree, err := Load(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := tree.ToMap()
+
+	groups := tree.DuplicateTables()
+	expected := [][]string{
+		{"service_a", "service_c"},
+		{"service_a.retry", "service_b.retry", "service_c.retry"},
+		{"w", "x"},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Fatalf("got %v, expected %v", groups, expected)
+	}
+
+	shared, err := tree.ShareDuplicateTables("shared.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.DuplicateTables()) != 0 {
+		t.Errorf("duplicates remain: %v", tree.DuplicateTables())
+	}
+	if shared.Get("service_a.retry.attempts") != int64(3) || shared.Has("service_b") || shared.Get("w.y") != int64(1) {
+		t.Errorf("unexpected shared tables:\n%s", shared)
+	}
+
+	files := map[string]string{
+		"main.toml":   tree.String(),
+		"shared.toml": shared.String(),
+	}
+	resolved, err := LoadFileWithRefs("main.toml", mapReadFile(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resolved.ToMap(), original) {
+		t.Errorf("got %v, expected %v", resolved.ToMap(), original)
+	}
+}
+
+func TestDecoderWithIncludeResolver(t *testing.T) {
+	files := map[string]string{
+		"conf.d/database.toml": `
+host = "db1"
+[credentials]
+ref = { file = "secrets.toml", key = "db" }
+`,
+		"conf.d/secrets.toml": `
+[db]
+user = "admin"
+`,
+	}
+	doc := `
+name = "app"
+
+[database]
+ref = { file = "conf.d/database.toml" }
+`
+	type config struct {
+		Name     string
+		Database struct {
+			Host        string
+			Credentials struct {
+				User string
+			}
+		}
+	}
+
+	var c config
+	d := NewDecoder(strings.NewReader(doc)).WithIncludeResolver(mapReadFile(files))
+	if err := d.Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "app" || c.Database.Host != "db1" || c.Database.Credentials.User != "admin" {
+		t.Errorf("bad config: %+v", c)
+	}
+
+	tree, err := NewDecoder(strings.NewReader(doc)).WithIncludeResolver(mapReadFile(files)).load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]string{
+		"name":                      "",
+		"database":                  "conf.d/database.toml",
+		"database.host":             "conf.d/database.toml",
+		"database.credentials":      "conf.d/secrets.toml",
+		"database.credentials.user": "conf.d/secrets.toml",
+		"missing":                   "",
+	}
+	for key, expected := range sources {
+		if got := tree.GetSourceFile(key); got != expected {
+			t.Errorf("source of %s: got %q, expected %q", key, got, expected)
+		}
+	}
+	if pos := tree.GetPosition("database.host"); pos.Line != 2 {
+		t.Errorf("positions should be relative to the included file, got %s", pos)
+	}
+}
+
+func TestDecoderWithIncludeResolverErrors(t *testing.T) {
+	files := map[string]string{
+		"a.toml":   "[b]\nref = { file = \"b.toml\" }",
+		"b.toml":   "[a]\nref = { file = \"a.toml\" }",
+		"bad.toml": "key = ",
+	}
+	var v map[string]interface{}
+	err := NewDecoder(strings.NewReader("[a]\nref = { file = \"a.toml\" }")).WithIncludeResolver(mapReadFile(files)).Decode(&v)
+	if err == nil || !strings.Contains(err.Error(), "reference cycle: a.toml -> b.toml -> a.toml") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+	err = NewDecoder(strings.NewReader("[a]\nref = { file = \"bad.toml\" }")).WithIncludeResolver(mapReadFile(files)).Decode(&v)
+	if err == nil || !strings.HasPrefix(err.Error(), "bad.toml: (1, ") {
+		t.Errorf("expected an error in bad.toml, got %v", err)
+	}
+}
+
+func TestLoadFileWithRefsSourceFile(t *testing.T) {
+	files := map[string]string{
+		"main.toml": "title = \"main\"\n[port]\nref = { file = \"db.toml\", key = \"port\" }",
+		"db.toml":   "port = 5432",
+	}
+	tree, err := LoadFileWithRefs("main.toml", mapReadFile(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src := tree.GetSourceFile("title"); src != "main.toml" {
+		t.Errorf("bad source of title: %q", src)
+	}
+	if src := tree.GetSourceFile("port"); src != "db.toml" {
+		t.Errorf("bad source of port: %q", src)
+	}
+}
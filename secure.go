@@ -0,0 +1,99 @@
+// Values holding secrets.
+
+package toml
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+const redacted = "<redacted>"
+
+// SecureString holds a secret, such as a password or an API token, decoded
+// from a TOML string.
+//
+// The secret is copied into a dedicated buffer which is locked in memory when
+// the platform allows it, and which can be zeroed with Wipe once the secret is
+// no longer needed. Note that the decoded document itself still holds a copy
+// of the string, which cannot be wiped.
+//
+// A SecureString never reveals its content when formatted or marshaled: both
+// String and MarshalTOML produce a redacted placeholder.
+type SecureString struct {
+	b []byte
+}
+
+// NewSecureString returns a SecureString holding a copy of b.
+func NewSecureString(b []byte) *SecureString {
+	s := &SecureString{}
+	s.set(b)
+	return s
+}
+
+func (s *SecureString) set(b []byte) {
+	s.Wipe()
+	if len(b) == 0 {
+		return
+	}
+	s.b = make([]byte, len(b))
+	copy(s.b, b)
+	mlock(s.b)
+}
+
+// Bytes returns the secret. The returned slice is the secret buffer itself:
+// it must not be retained after calling Wipe.
+func (s *SecureString) Bytes() []byte {
+	return s.b
+}
+
+// Len returns the length of the secret in bytes.
+func (s *SecureString) Len() int {
+	return len(s.b)
+}
+
+// Equal reports whether the secret is equal to b. The comparison takes a time
+// independent of the content of the secret.
+func (s *SecureString) Equal(b []byte) bool {
+	return subtle.ConstantTimeCompare(s.b, b) == 1
+}
+
+// Wipe overwrites the secret with zeroes and releases its buffer.
+func (s *SecureString) Wipe() {
+	if s.b == nil {
+		return
+	}
+	for i := range s.b {
+		s.b[i] = 0
+	}
+	munlock(s.b)
+	s.b = nil
+}
+
+// String returns a redacted placeholder.
+func (s SecureString) String() string {
+	return redacted
+}
+
+// GoString returns a redacted placeholder.
+func (s SecureString) GoString() string {
+	return "toml.SecureString(" + redacted + ")"
+}
+
+// MarshalTOML encodes the secret as a redacted placeholder string.
+func (s SecureString) MarshalTOML() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
+
+// UnmarshalTOML stores the given TOML string as the secret.
+func (s *SecureString) UnmarshalTOML(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		if v == nil {
+			return errors.New("secure string cannot be nil")
+		}
+		return fmt.Errorf("secure string must be a string, not %T", v)
+	}
+	s.set([]byte(str))
+	return nil
+}
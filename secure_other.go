@@ -0,0 +1,9 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+// Memory locking of secrets, unsupported on other systems.
+
+package toml
+
+func mlock(b []byte) {}
+
+func munlock(b []byte) {}
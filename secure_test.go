@@ -0,0 +1,83 @@
+package toml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type secureConfig struct {
+	User     string
+	Password SecureString
+	Token    *SecureString
+}
+
+func TestSecureStringUnmarshal(t *testing.T) {
+	var cfg secureConfig
+	err := Unmarshal([]byte(`
+User = "admin"
+Password = "hunter2"
+Token = "abc"
+`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cfg.Password.Bytes()) != "hunter2" {
+		t.Errorf("unexpected password: %q", cfg.Password.Bytes())
+	}
+	if cfg.Token == nil || !cfg.Token.Equal([]byte("abc")) {
+		t.Errorf("unexpected token: %v", cfg.Token)
+	}
+	if cfg.Password.Equal([]byte("hunter3")) || cfg.Password.Equal([]byte("hunter")) {
+		t.Error("Equal should not match a different secret")
+	}
+
+	cfg.Password.Wipe()
+	if cfg.Password.Len() != 0 || cfg.Password.Bytes() != nil {
+		t.Error("Wipe should release the secret")
+	}
+}
+
+func TestSecureStringUnmarshalError(t *testing.T) {
+	var cfg secureConfig
+	err := Unmarshal([]byte(`Password = 42`), &cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestSecureStringWipeZeroes(t *testing.T) {
+	s := NewSecureString([]byte("secret"))
+	b := s.Bytes()
+	s.Wipe()
+	for _, c := range b {
+		if c != 0 {
+			t.Fatalf("buffer not zeroed: %q", b)
+		}
+	}
+}
+
+func TestSecureStringRedacted(t *testing.T) {
+	cfg := secureConfig{
+		User:     "admin",
+		Password: *NewSecureString([]byte("hunter2")),
+		Token:    NewSecureString([]byte("abc")),
+	}
+
+	out, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "hunter2") || strings.Contains(string(out), "abc") {
+		t.Errorf("secrets leaked in marshaled output:\n%s", out)
+	}
+	if !strings.Contains(string(out), `Password = "<redacted>"`) {
+		t.Errorf("expected redacted password:\n%s", out)
+	}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		if s := fmt.Sprintf(format, cfg); strings.Contains(s, "hunter2") {
+			t.Errorf("%s leaked the secret: %s", format, s)
+		}
+	}
+}
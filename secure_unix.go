@@ -0,0 +1,17 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+// Memory locking of secrets on Unix systems.
+
+package toml
+
+import "syscall"
+
+// mlock prevents b from being swapped to disk. Failures are ignored: locking
+// is best-effort, and commonly restricted by RLIMIT_MEMLOCK.
+func mlock(b []byte) {
+	_ = syscall.Mlock(b)
+}
+
+func munlock(b []byte) {
+	_ = syscall.Munlock(b)
+}
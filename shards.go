@@ -0,0 +1,63 @@
+// Encoding of documents split in several files.
+
+package toml
+
+import (
+	"io"
+	"sort"
+)
+
+// ShardFunc returns where the Encoder writes the top-level table of the given
+// key: the name of the file, as referenced by the index, and the writer of
+// this file.
+type ShardFunc func(table string) (file string, w io.Writer, err error)
+
+// Shards makes the encoder write each top-level table as a document of its
+// own, to the writer returned by fn for the table. The writer of the encoder
+// receives the index: the keys outside of tables and the arrays of tables,
+// followed by a reference to the file of each table, which LoadFileWithRefs
+// resolves back to the whole document:
+//
+//   title = "main"
+//
+//   [database]
+//
+//     [database.ref]
+//       file = "conf.d/database.toml"
+//
+// This suits programs reading configuration fragments from a directory.
+func (e *Encoder) Shards(fn ShardFunc) *Encoder {
+	e.shards = fn
+	return e
+}
+
+// writeShards writes the top-level tables of t with the shard function of
+// the encoder, and replaces them by references.
+func (e *Encoder) writeShards(t *Tree) error {
+	var keys []string
+	for k, v := range t.values {
+		if _, ok := v.(*Tree); ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		file, w, err := e.shards(k)
+		if err != nil {
+			return err
+		}
+		table, err := t.extractTable(quoteKeyIfNeeded(k), file, "")
+		if err != nil {
+			return err
+		}
+		b, err := e.writeTree(table)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package toml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type shardedConfig struct {
+	Title    string        `toml:"title"`
+	Database shardedDB     `toml:"database"`
+	Server   shardedServer `toml:"server"`
+	Users    []shardedUser `toml:"users"`
+}
+
+type shardedDB struct {
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+}
+
+type shardedServer struct {
+	Name string            `toml:"name"`
+	TLS  map[string]string `toml:"tls"`
+}
+
+type shardedUser struct {
+	Name string `toml:"name"`
+}
+
+func TestEncoderShards(t *testing.T) {
+	config := shardedConfig{
+		Title:    "main",
+		Database: shardedDB{Host: "db1", Port: 5432},
+		Server:   shardedServer{Name: "web", TLS: map[string]string{"cert": "web.pem"}},
+		Users:    []shardedUser{{Name: "a"}, {Name: "b"}},
+	}
+
+	shards := map[string]*bytes.Buffer{}
+	var index bytes.Buffer
+	err := NewEncoder(&index).Shards(func(table string) (string, io.Writer, error) {
+		file := "conf.d/" + table + ".toml"
+		shards[file] = &bytes.Buffer{}
+		return file, shards[file], nil
+	}).Encode(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `title = "main"
+
+[database]
+
+  [database.ref]
+    file = "conf.d/database.toml"
+
+[server]
+
+  [server.ref]
+    file = "conf.d/server.toml"
+
+[[users]]
+  name = "a"
+
+[[users]]
+  name = "b"
+`
+	if index.String() != expected {
+		t.Errorf("bad index:\n%s\nexpected:\n%s", index.String(), expected)
+	}
+	if len(shards) != 2 {
+		t.Fatalf("expected 2 shards, got %v", shards)
+	}
+	expectedServer := `name = "web"
+
+[tls]
+  cert = "web.pem"
+`
+	if got := shards["conf.d/server.toml"].String(); got != expectedServer {
+		t.Errorf("bad server shard:\n%s\nexpected:\n%s", got, expectedServer)
+	}
+
+	files := map[string]string{"main.toml": index.String()}
+	for file, buf := range shards {
+		files[file] = buf.String()
+	}
+	tree, err := LoadFileWithRefs("main.toml", mapReadFile(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded shardedConfig
+	if err := tree.Unmarshal(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, config) {
+		t.Errorf("bad round trip: %v, expected %v", decoded, config)
+	}
+}
+
+func TestEncoderShardsError(t *testing.T) {
+	failure := errors.New("no space left")
+	err := NewEncoder(&bytes.Buffer{}).Shards(func(table string) (string, io.Writer, error) {
+		return "", nil, failure
+	}).Encode(shardedConfig{})
+	if err != failure {
+		t.Errorf("expected %v, got %v", failure, err)
+	}
+}
+
+func TestEncoderShardsTree(t *testing.T) {
+	tree, err := Load("title = \"main\"\n\n[db]\nhost = \"db1\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := tree.String()
+	err = NewEncoder(&bytes.Buffer{}).Shards(func(table string) (string, io.Writer, error) {
+		return table + ".toml", &bytes.Buffer{}, nil
+	}).Encode(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := tree.String(); after != before {
+		t.Errorf("the encoded tree should be unchanged:\n%s\nexpected:\n%s", after, before)
+	}
+}
@@ -0,0 +1,219 @@
+// Streaming of the elements of a document.
+
+package toml
+
+import (
+	"errors"
+	"io"
+	"reflect"
+)
+
+// TokenKind is the kind of a Token.
+type TokenKind int
+
+// Kinds of tokens returned by Decoder.Token.
+const (
+	// Table header, such as [a.b].
+	TokenTable TokenKind = iota + 1
+	// Array of tables header, such as [[a.b]].
+	TokenArrayTable
+	// Key/value pair, such as a.b = 42.
+	TokenKeyValue
+)
+
+// Token is an element of a TOML document returned by Decoder.Token.
+type Token struct {
+	Kind TokenKind
+	// Key of the table, or of the value relative to the current table.
+	Key []string
+	// Value of a key/value pair, with the types documented for Tree.ToMap.
+	// Arrays and inline tables are returned as a whole.
+	Value    interface{}
+	Position Position
+}
+
+// Token returns the next table header or key/value pair of the input, in
+// document order. At the end of the input, it returns io.EOF.
+//
+// No Tree is built: the syntax of each element is checked, but not the
+// consistency of the document as a whole, such as duplicated keys. Token
+// must not be mixed with calls to Decode.
+func (d *Decoder) Token() (Token, error) {
+	if d.tokenErr != nil {
+		return Token{}, d.tokenErr
+	}
+	if d.tokens == nil {
+		b, err := d.readAll()
+		doc := stripBOM(b)
+		if err == nil {
+			err = validateUTF8(doc, len(b)-len(doc))
+		}
+		if err != nil {
+			d.tokenErr = err
+			return Token{}, err
+		}
+		d.tokens = &tomlParser{parseOptions: d.parseOptions(), flow: lexToml(doc)}
+	}
+	tok, err := d.tokens.nextToken()
+	if err != nil {
+		d.tokenErr = err
+	}
+	return tok, err
+}
+
+func (p *tomlParser) nextToken() (tok Token, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = parserError(r)
+		}
+	}()
+
+	start := p.getToken()
+	if start == nil || start.typ == tokenEOF {
+		return Token{}, io.EOF
+	}
+	switch start.typ {
+	case tokenDoubleLeftBracket:
+		key := p.getToken()
+		if key.typ != tokenKeyGroupArray {
+			p.raiseError(key, "unexpected token %s, was expecting a table array key", key)
+		}
+		keys, err := parseKey(key.val)
+		if err != nil {
+			p.raiseError(key, "invalid table array key: %s", err)
+		}
+		p.countKey(key)
+		p.checkDepth(key, len(keys))
+		p.currentTable = keys
+		p.assume(tokenDoubleRightBracket)
+		return Token{Kind: TokenArrayTable, Key: keys, Position: start.Position}, nil
+	case tokenLeftBracket:
+		key := p.getToken()
+		if key.typ != tokenKeyGroup {
+			p.raiseError(key, "unexpected token %s, was expecting a table key", key)
+		}
+		keys, err := parseKey(key.val)
+		if err != nil {
+			p.raiseError(key, "invalid table array key: %s", err)
+		}
+		p.countKey(key)
+		p.checkDepth(key, len(keys))
+		p.currentTable = keys
+		p.assume(tokenRightBracket)
+		return Token{Kind: TokenTable, Key: keys, Position: start.Position}, nil
+	case tokenKey:
+		p.assume(tokenEqual)
+		keys, err := parseKey(start.val)
+		if err != nil {
+			p.raiseError(start, "invalid key: %s", err.Error())
+		}
+		p.countKey(start)
+		p.depth = len(p.currentTable) + len(keys)
+		p.checkDepth(start, p.depth)
+		value := p.parseRvalue()
+		p.value = value
+		return Token{Kind: TokenKeyValue, Key: keys, Value: tomlValueToGo(value), Position: start.Position}, nil
+	case tokenError:
+		p.raiseError(start, "parsing error: %s", start.String())
+	default:
+		p.raiseError(start, "unexpected token %s", start.typ)
+	}
+	return Token{}, nil
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// DecodeEach decodes the tables of the array of tables at the given
+// dot-separated key one at a time, and calls fn with each of them as soon as
+// it is read, without building the slice of all the tables. Like Token, it
+// still reads and tokenizes the whole document first. fn must be a function
+// such as:
+//
+//   func(r Record) error
+//
+// where Record is a struct, a pointer to a struct or a map. DecodeEach stops
+// at the first error returned by fn, and returns it. The rest of the document
+// is ignored. Like Token, DecodeEach must not be mixed with calls to Decode.
+func (d *Decoder) DecodeEach(key string, fn interface{}) error {
+	keys, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.Type().NumIn() != 1 || fv.Type().NumOut() != 1 || fv.Type().Out(0) != errorType {
+		return errors.New("DecodeEach needs a function such as func(v T) error")
+	}
+	elemType := fv.Type().In(0)
+
+	var element *Tree  // table being read
+	var table []string // current table, relative to element
+	deliver := func() error {
+		if element == nil {
+			return nil
+		}
+		d.tval = element
+		element = nil
+		v := reflect.New(elemType)
+		if elemType.Kind() == reflect.Ptr {
+			v.Elem().Set(reflect.New(elemType.Elem()))
+			if err := d.unmarshal(v.Elem().Interface()); err != nil {
+				return err
+			}
+		} else if err := d.unmarshal(v.Interface()); err != nil {
+			return err
+		}
+		if err, _ := fv.Call([]reflect.Value{v.Elem()})[0].Interface().(error); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return deliver()
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case tok.Kind == TokenKeyValue:
+			if element != nil {
+				path := append(table[:len(table):len(table)], tok.Key...)
+				element.SetPath(path, d.tokens.value)
+				element.SetPositionPath(path, tok.Position)
+			}
+		case tok.Kind == TokenArrayTable && equalKeys(tok.Key, keys):
+			if err := deliver(); err != nil {
+				return err
+			}
+			element = newTreeWithPosition(tok.Position)
+			table = nil
+		case element != nil && len(tok.Key) > len(keys) && equalKeys(tok.Key[:len(keys)], keys):
+			// sub-table of the element
+			table = tok.Key[len(keys):]
+			if tok.Kind == TokenArrayTable {
+				array, _ := element.GetPath(table).([]*Tree)
+				element.SetPath(table, append(array, newTreeWithPosition(tok.Position)))
+			} else if !element.HasPath(table) {
+				element.SetPath(table, newTreeWithPosition(tok.Position))
+			}
+		default:
+			if err := deliver(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
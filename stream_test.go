@@ -0,0 +1,124 @@
+package toml
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoderToken(t *testing.T) {
+	input := `
+title = "doc" # comment
+[server]
+  host.name = "localhost"
+  ports = [80, 443]
+[[clients]]
+  point = { x = 1, y = 2 }
+`
+	d := NewDecoder(strings.NewReader(input))
+	var tokens []Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, tok)
+	}
+
+	expected := []Token{
+		{Kind: TokenKeyValue, Key: []string{"title"}, Value: "doc", Position: Position{2, 1}},
+		{Kind: TokenTable, Key: []string{"server"}, Position: Position{3, 1}},
+		{Kind: TokenKeyValue, Key: []string{"host", "name"}, Value: "localhost", Position: Position{4, 3}},
+		{Kind: TokenKeyValue, Key: []string{"ports"}, Value: []interface{}{int64(80), int64(443)}, Position: Position{5, 3}},
+		{Kind: TokenArrayTable, Key: []string{"clients"}, Position: Position{6, 1}},
+		{Kind: TokenKeyValue, Key: []string{"point"}, Value: map[string]interface{}{"x": int64(1), "y": int64(2)}, Position: Position{7, 3}},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Bad tokens.\nExpected: %+v\nGot:      %+v", expected, tokens)
+	}
+}
+
+func TestDecoderTokenError(t *testing.T) {
+	d := NewDecoder(strings.NewReader("a = 1\nb = \n"))
+	if tok, err := d.Token(); err != nil || tok.Value != int64(1) {
+		t.Fatalf("unexpected first token: %+v, %v", tok, err)
+	}
+	_, err := d.Token()
+	if err == nil || err == io.EOF {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+	if _, again := d.Token(); again != err {
+		t.Errorf("error should be returned again, got %v", again)
+	}
+}
+
+func TestDecoderDecodeEach(t *testing.T) {
+	input := `
+title = "log"
+
+[[records]]
+id = 1
+origin = { x = 1, y = 2 }
+
+[records.meta]
+tags = ["a"]
+
+[[records.events]]
+name = "start"
+
+[[records.events]]
+name = "stop"
+
+[[records]]
+id = 2
+
+[other]
+id = 3
+`
+	type record struct {
+		ID     int
+		Origin struct{ X, Y int }
+		Meta   struct{ Tags []string }
+		Events []struct{ Name string }
+	}
+	var records []record
+	err := NewDecoder(strings.NewReader(input)).DecodeEach("records", func(r record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := record{ID: 1}
+	first.Origin.X, first.Origin.Y = 1, 2
+	first.Meta.Tags = []string{"a"}
+	first.Events = []struct{ Name string }{{"start"}, {"stop"}}
+	expected := []record{first, {ID: 2}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("got %+v, expected %+v", records, expected)
+	}
+
+	calls := 0
+	stop := errors.New("stop")
+	err = NewDecoder(strings.NewReader(input)).DecodeEach("records", func(r *record) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("expected the error of the callback after one call, got %v after %d", err, calls)
+	}
+
+	err = NewDecoder(strings.NewReader(input)).DecodeEach("records", func(r record) {})
+	if err == nil {
+		t.Error("expected an error for a function without error result")
+	}
+	err = NewDecoder(strings.NewReader("[[records]]\nid = \"one\"\n")).DecodeEach("records", func(r record) error { return nil })
+	if err == nil {
+		t.Error("expected a type error")
+	}
+}
@@ -0,0 +1,59 @@
+// Representation of string values in documents.
+
+package toml
+
+// StringStyle is the kind of quotes of a string value in a document.
+type StringStyle int
+
+// Kinds of quotes of string values.
+const (
+	StringBasic            StringStyle = iota // "basic"
+	StringLiteral                             // 'literal'
+	StringMultilineBasic                      // """multi-line basic"""
+	StringMultilineLiteral                    // '''multi-line literal'''
+)
+
+func (s StringStyle) String() string {
+	switch s {
+	case StringBasic:
+		return "basic"
+	case StringLiteral:
+		return "literal"
+	case StringMultilineBasic:
+		return "multi-line basic"
+	case StringMultilineLiteral:
+		return "multi-line literal"
+	default:
+		return "unknown"
+	}
+}
+
+// StringRepresentation describes how a string value is written in a
+// document, so that tools can tell 'C:\path' from "C:\\path" apart.
+type StringRepresentation struct {
+	Style   StringStyle
+	Escaped bool // the string contains escape sequences or line ending backslashes
+}
+
+// GetStringRepresentation returns how the string value at key is written in
+// the document it was loaded from. It returns false when there is no string
+// value at key. Strings set programmatically, and strings in arrays and
+// inline tables, are reported as basic strings without escapes.
+func (t *Tree) GetStringRepresentation(key string) (StringRepresentation, bool) {
+	keys, err := parseKey(key)
+	if err != nil {
+		return StringRepresentation{}, false
+	}
+	parent, ok := t.GetPath(keys[:len(keys)-1]).(*Tree)
+	if !ok {
+		return StringRepresentation{}, false
+	}
+	tv, ok := parent.values[keys[len(keys)-1]].(*tomlValue)
+	if !ok {
+		return StringRepresentation{}, false
+	}
+	if _, isString := tv.value.(string); !isString {
+		return StringRepresentation{}, false
+	}
+	return tv.repr, true
+}
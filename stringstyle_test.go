@@ -0,0 +1,57 @@
+package toml
+
+import (
+	"testing"
+)
+
+func TestGetStringRepresentation(t *testing.T) {
+	tree, err := Load(`
+basic = "C:\\path"
+plain = "no escapes"
+literal = 'C:\path'
+multi = """
+line \
+  continued"""
+multiliteral = '''
+raw\n'''
+number = 42
+[table]
+key = 'value'
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key      string
+		expected StringRepresentation
+	}{
+		{"basic", StringRepresentation{Style: StringBasic, Escaped: true}},
+		{"plain", StringRepresentation{Style: StringBasic}},
+		{"literal", StringRepresentation{Style: StringLiteral}},
+		{"multi", StringRepresentation{Style: StringMultilineBasic, Escaped: true}},
+		{"multiliteral", StringRepresentation{Style: StringMultilineLiteral}},
+		{"table.key", StringRepresentation{Style: StringLiteral}},
+	}
+	for _, test := range tests {
+		repr, ok := tree.GetStringRepresentation(test.key)
+		if !ok || repr != test.expected {
+			t.Errorf("%s: got %+v, %v, expected %+v", test.key, repr, ok, test.expected)
+		}
+	}
+	if tree.Get("basic") != tree.Get("literal") {
+		t.Errorf("both strings should have the same value")
+	}
+
+	for _, key := range []string{"number", "table", "missing", "table.missing", ""} {
+		if repr, ok := tree.GetStringRepresentation(key); ok {
+			t.Errorf("%s: expected no string, got %+v", key, repr)
+		}
+	}
+}
+
+func TestStringStyleString(t *testing.T) {
+	if s := StringMultilineLiteral.String(); s != "multi-line literal" {
+		t.Errorf("got %q", s)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 )
 
@@ -17,6 +18,13 @@ type tomlValue struct {
 	multiline bool
 	literal   bool
 	position  Position
+	raw       string // literal to write back as long as value is unchanged
+	trailing  string // comment written after the value, on the same line
+
+	repr        StringRepresentation // of a string value in the document it was loaded from
+	number      string               // literal of a number value in the document it was loaded from
+	source      string               // file the value was loaded from, see GetSourceFile
+	annotations map[interface{}]interface{}
 }
 
 // Tree is the result of the parsing of a TOML file.
@@ -26,6 +34,11 @@ type Tree struct {
 	commented bool
 	inline    bool
 	position  Position
+	trailing  string // comment written after an inline table, on the same line
+	source    string // file the table was loaded from, see GetSourceFile
+	format    TableFormat
+
+	annotations map[interface{}]interface{}
 }
 
 func newTree() *Tree {
@@ -53,6 +66,23 @@ func (t *Tree) Position() Position {
 	return t.position
 }
 
+// Annotation returns the value attached to the tree under key by
+// SetAnnotation, or nil.
+func (t *Tree) Annotation(key interface{}) interface{} {
+	return t.annotations[key]
+}
+
+// SetAnnotation attaches a value to the tree under key. Annotations are never
+// encoded: they let tools carry computed information (resolved types, schema
+// nodes...) between passes over a document. Like context keys, keys should be
+// of an unexported type to avoid collisions between packages.
+func (t *Tree) SetAnnotation(key, value interface{}) {
+	if t.annotations == nil {
+		t.annotations = make(map[interface{}]interface{})
+	}
+	t.annotations[key] = value
+}
+
 // Has returns a boolean indicating if the given key exists.
 func (t *Tree) Has(key string) bool {
 	if key == "" {
@@ -367,12 +397,18 @@ func (t *Tree) SetPathWithOptions(keys []string, opts SetOptions, value interfac
 		v.literal = opts.Literal
 		toInsert = v
 	default:
-		toInsert = &tomlValue{value: value,
+		tv := &tomlValue{value: value,
 			comment:   opts.Comment,
 			commented: opts.Commented,
 			multiline: opts.Multiline,
 			literal:   opts.Literal,
 			position:  Position{Line: subtree.position.Line + len(subtree.values) + 1, Col: subtree.position.Col}}
+		// the comment after a value read from a document describes its key,
+		// and is kept when the value is replaced
+		if previous, ok := subtree.values[keys[len(keys)-1]].(*tomlValue); ok {
+			tv.trailing = previous.trailing
+		}
+		toInsert = tv
 	}
 
 	subtree.values[keys[len(keys)-1]] = toInsert
@@ -432,6 +468,31 @@ func (t *Tree) DeletePath(keys []string) error {
 	return errors.New("no such key to delete")
 }
 
+// SortArrayTables reorders the tables of the array of tables at key.
+// Key is a dot-separated path (e.g. a.b.c).
+// Each table is moved as a whole, including its sub-tables and comments.
+// The sort is stable.
+func (t *Tree) SortArrayTables(key string, less func(a, b *Tree) bool) error {
+	keys, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	return t.SortArrayTablesPath(keys, less)
+}
+
+// SortArrayTablesPath is the same as SortArrayTables, but takes an array of
+// path elements (e.g. {"a","b","c"}).
+func (t *Tree) SortArrayTablesPath(keys []string, less func(a, b *Tree) bool) error {
+	array, ok := t.GetPath(keys).([]*Tree)
+	if !ok {
+		return fmt.Errorf("%s is not an array of tables", strings.Join(keys, "."))
+	}
+	sort.SliceStable(array, func(i, j int) bool {
+		return less(array[i], array[j])
+	})
+	return nil
+}
+
 // createSubTree takes a tree and a key and create the necessary intermediate
 // subtrees to create a subtree at that point. In-place.
 //
@@ -464,27 +525,76 @@ func (t *Tree) createSubTree(keys []string, pos Position) error {
 	return nil
 }
 
-// LoadBytes creates a Tree from a []byte.
+// deepCopy returns a copy of t whose tables and values can be changed without
+// changing t. The elements of arrays of values are shared.
+func (t *Tree) deepCopy() *Tree {
+	c := *t
+	c.values = make(map[string]interface{}, len(t.values))
+	for k, v := range t.values {
+		switch node := v.(type) {
+		case *Tree:
+			c.values[k] = node.deepCopy()
+		case []*Tree:
+			array := make([]*Tree, len(node))
+			for i, item := range node {
+				array[i] = item.deepCopy()
+			}
+			c.values[k] = array
+		case *tomlValue:
+			tv := *node
+			c.values[k] = &tv
+		default:
+			c.values[k] = v
+		}
+	}
+	return &c
+}
+
+// LoadBytes creates a Tree from a []byte. The document is not limited, see
+// LoadBytesWithLimits for untrusted inputs.
 func LoadBytes(b []byte) (tree *Tree, err error) {
+	return LoadBytesWithLimits(b, NoLimits())
+}
+
+// loadBytes creates a Tree from a []byte, parsed with the given options.
+func loadBytes(b []byte, opts parseOptions) (tree *Tree, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			if _, ok := r.(runtime.Error); ok {
-				panic(r)
-			}
-			err = errors.New(r.(string))
+			err = parserError(r)
 		}
 	}()
 
+	doc := stripBOM(b)
+	if err := validateUTF8(doc, len(b)-len(doc)); err != nil {
+		return nil, err
+	}
+	flow, notes := lexTomlWithNotes(doc)
+	tree = parseToml(flow, notes, opts)
+	return
+}
+
+// parserError converts a value recovered from a panic of the parser to an
+// error. Panics of the runtime are propagated.
+func parserError(r interface{}) error {
+	switch e := r.(type) {
+	case runtime.Error:
+		panic(r)
+	case error:
+		return e
+	default:
+		return errors.New(r.(string))
+	}
+}
+
+func stripBOM(b []byte) []byte {
 	if len(b) >= 4 && (hasUTF32BigEndianBOM4(b) || hasUTF32LittleEndianBOM4(b)) {
-		b = b[4:]
+		return b[4:]
 	} else if len(b) >= 3 && hasUTF8BOM3(b) {
-		b = b[3:]
+		return b[3:]
 	} else if len(b) >= 2 && (hasUTF16BigEndianBOM2(b) || hasUTF16LittleEndianBOM2(b)) {
-		b = b[2:]
+		return b[2:]
 	}
-
-	tree = parseToml(lexToml(b))
-	return
+	return b
 }
 
 func hasUTF16BigEndianBOM2(b []byte) bool {
@@ -259,3 +259,86 @@ func TestLoadBytesBOM(t *testing.T) {
 		}
 	}
 }
+
+func TestTomlSortArrayTables(t *testing.T) {
+	tree, err := Load(`
+[[rules]]
+name = "b"
+priority = 2
+
+[[rules]]
+name = "a"
+priority = 1
+  [rules.match]
+  path = "/a"
+
+[[rules]]
+name = "c"
+priority = 2
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.GetPath([]string{"rules"}).([]*Tree)[0].SetComment("first rule")
+
+	err = tree.SortArrayTables("rules", func(a, b *Tree) bool {
+		return a.Get("priority").(int64) < b.Get("priority").(int64)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := tree.Get("rules").([]*Tree)
+	var names []string
+	for _, rule := range rules {
+		names = append(names, rule.Get("name").(string))
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected order: %v", names)
+	}
+	if rules[0].Get("match.path") != "/a" {
+		t.Error("sub-tables should move along with their table")
+	}
+	if rules[1].Comment() != "first rule" {
+		t.Error("comments should move along with their table")
+	}
+
+	if err := tree.SortArrayTables("rules.name", nil); err == nil {
+		t.Error("expected an error when the key is not an array of tables")
+	}
+}
+
+func TestTomlAnnotations(t *testing.T) {
+	type schemaKey struct{}
+
+	tree, err := Load(`
+[server]
+port = 8080
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := tree.Get("server").(*Tree)
+	if server.Annotation(schemaKey{}) != nil {
+		t.Error("annotation should be nil before being set")
+	}
+	server.SetAnnotation(schemaKey{}, "ServerConfig")
+	if server.Annotation(schemaKey{}) != "ServerConfig" {
+		t.Errorf("unexpected annotation: %v", server.Annotation(schemaKey{}))
+	}
+
+	port := server.Values()["port"].(*PubTOMLValue)
+	port.SetAnnotation(schemaKey{}, "uint16")
+	if port.Annotation(schemaKey{}) != "uint16" {
+		t.Errorf("unexpected annotation: %v", port.Annotation(schemaKey{}))
+	}
+
+	s, err := tree.ToTomlString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "\n[server]\n  port = 8080\n" {
+		t.Errorf("annotations should not be encoded, got %q", s)
+	}
+}
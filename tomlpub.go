@@ -15,12 +15,20 @@ func (ptv *PubTOMLValue) Commented() bool {
 func (ptv *PubTOMLValue) Multiline() bool {
 	return ptv.multiline
 }
+func (ptv *PubTOMLValue) StringRepresentation() StringRepresentation {
+	return ptv.repr
+}
 func (ptv *PubTOMLValue) Position() Position {
 	return ptv.position
 }
+func (ptv *PubTOMLValue) Annotation(key interface{}) interface{} {
+	return ptv.annotations[key]
+}
 
 func (ptv *PubTOMLValue) SetValue(v interface{}) {
 	ptv.value = v
+	ptv.raw = ""
+	ptv.number = ""
 }
 func (ptv *PubTOMLValue) SetComment(s string) {
 	ptv.comment = s
@@ -34,6 +42,12 @@ func (ptv *PubTOMLValue) SetMultiline(m bool) {
 func (ptv *PubTOMLValue) SetPosition(p Position) {
 	ptv.position = p
 }
+func (ptv *PubTOMLValue) SetAnnotation(key, value interface{}) {
+	if ptv.annotations == nil {
+		ptv.annotations = make(map[interface{}]interface{})
+	}
+	ptv.annotations[key] = value
+}
 
 // PubTree wrapping Tree in order to access all properties from outside.
 type PubTree = Tree
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type valueComplexity int
@@ -25,85 +26,13 @@ type sortNode struct {
 	complexity valueComplexity
 }
 
-// Encodes a string to a TOML-compliant multi-line string value
-// This function is a clone of the existing encodeTomlString function, except that whitespace characters
-// are preserved. Quotation marks and backslashes are also not escaped.
+// Encodes a string to a TOML-compliant multi-line string value, prefixing
+// each line with commented.
 func encodeMultilineTomlString(value string, commented string) string {
-	var b bytes.Buffer
-	adjacentQuoteCount := 0
-
-	b.WriteString(commented)
-	for i, rr := range value {
-		if rr != '"' {
-			adjacentQuoteCount = 0
-		} else {
-			adjacentQuoteCount++
-		}
-		switch rr {
-		case '\b':
-			b.WriteString(`\b`)
-		case '\t':
-			b.WriteString("\t")
-		case '\n':
-			b.WriteString("\n" + commented)
-		case '\f':
-			b.WriteString(`\f`)
-		case '\r':
-			b.WriteString("\r")
-		case '"':
-			if adjacentQuoteCount >= 3 || i == len(value)-1 {
-				adjacentQuoteCount = 0
-				b.WriteString(`\"`)
-			} else {
-				b.WriteString(`"`)
-			}
-		case '\\':
-			b.WriteString(`\`)
-		default:
-			intRr := uint16(rr)
-			if intRr < 0x001F {
-				b.WriteString(fmt.Sprintf("\\u%0.4X", intRr))
-			} else {
-				b.WriteRune(rr)
-			}
-		}
-	}
-	return b.String()
+	return commented + strings.Replace(EscapeMultilineBasicString(value), "\n", "\n"+commented, -1)
 }
 
-// Encodes a string to a TOML-compliant string value
-func encodeTomlString(value string) string {
-	var b bytes.Buffer
-
-	for _, rr := range value {
-		switch rr {
-		case '\b':
-			b.WriteString(`\b`)
-		case '\t':
-			b.WriteString(`\t`)
-		case '\n':
-			b.WriteString(`\n`)
-		case '\f':
-			b.WriteString(`\f`)
-		case '\r':
-			b.WriteString(`\r`)
-		case '"':
-			b.WriteString(`\"`)
-		case '\\':
-			b.WriteString(`\\`)
-		default:
-			intRr := uint16(rr)
-			if intRr < 0x001F {
-				b.WriteString(fmt.Sprintf("\\u%0.4X", intRr))
-			} else {
-				b.WriteRune(rr)
-			}
-		}
-	}
-	return b.String()
-}
-
-func tomlTreeStringRepresentation(t *Tree, ord MarshalOrder) (string, error) {
+func tomlTreeStringRepresentation(t *Tree, ord MarshalOrder, newline string) (string, error) {
 	var orderedVals []sortNode
 	switch ord {
 	case OrderPreserve:
@@ -117,7 +46,7 @@ func tomlTreeStringRepresentation(t *Tree, ord MarshalOrder) (string, error) {
 		k := node.key
 		v := t.values[k]
 
-		repr, err := tomlValueStringRepresentation(v, "", "", ord, false)
+		repr, err := tomlValueStringRepresentation(v, "", "", ord, false, newline)
 		if err != nil {
 			return "", err
 		}
@@ -126,11 +55,17 @@ func tomlTreeStringRepresentation(t *Tree, ord MarshalOrder) (string, error) {
 	return "{ " + strings.Join(values, ", ") + " }", nil
 }
 
-func tomlValueStringRepresentation(v interface{}, commented string, indent string, ord MarshalOrder, arraysOneElementPerLine bool) (string, error) {
+// tomlValueStringRepresentation returns the representation of v, its lines
+// ending with newline. The line breaks of the content of multi-line strings
+// are always "\n".
+func tomlValueStringRepresentation(v interface{}, commented string, indent string, ord MarshalOrder, arraysOneElementPerLine bool, newline string) (string, error) {
 	// this interface check is added to dereference the change made in the writeTo function.
 	// That change was made to allow this function to see formatting options.
 	tv, ok := v.(*tomlValue)
 	if ok {
+		if tv.raw != "" {
+			return tv.raw, nil
+		}
 		v = tv.value
 	} else {
 		tv = &tomlValue{}
@@ -156,19 +91,23 @@ func tomlValueStringRepresentation(v interface{}, commented string, indent strin
 			return strings.ToLower(strconv.FormatFloat(value, 'f', 1, bits)), nil
 		}
 		return strings.ToLower(strconv.FormatFloat(value, 'f', -1, bits)), nil
+	case *big.Int:
+		return value.String(), nil
+	case *big.Float:
+		return value.Text('e', -1), nil
 	case string:
 		if tv.multiline {
 			if tv.literal {
 				b := strings.Builder{}
-				b.WriteString("'''\n")
+				b.WriteString("'''" + newline)
 				b.Write([]byte(value))
 				b.WriteString("\n'''")
 				return b.String(), nil
 			} else {
-				return "\"\"\"\n" + encodeMultilineTomlString(value, commented) + "\"\"\"", nil
+				return "\"\"\"" + newline + encodeMultilineTomlString(value, commented) + "\"\"\"", nil
 			}
 		}
-		return "\"" + encodeTomlString(value) + "\"", nil
+		return "\"" + EscapeBasicString(value) + "\"", nil
 	case []byte:
 		b, _ := v.([]byte)
 		return string(b), nil
@@ -186,7 +125,7 @@ func tomlValueStringRepresentation(v interface{}, commented string, indent strin
 	case LocalTime:
 		return value.String(), nil
 	case *Tree:
-		return tomlTreeStringRepresentation(value, ord)
+		return tomlTreeStringRepresentation(value, ord, newline)
 	case nil:
 		return "", nil
 	}
@@ -197,7 +136,7 @@ func tomlValueStringRepresentation(v interface{}, commented string, indent strin
 		var values []string
 		for i := 0; i < rv.Len(); i++ {
 			item := rv.Index(i).Interface()
-			itemRepr, err := tomlValueStringRepresentation(item, commented, indent, ord, arraysOneElementPerLine)
+			itemRepr, err := tomlValueStringRepresentation(item, commented, indent, ord, arraysOneElementPerLine, newline)
 			if err != nil {
 				return "", err
 			}
@@ -207,13 +146,13 @@ func tomlValueStringRepresentation(v interface{}, commented string, indent strin
 			stringBuffer := bytes.Buffer{}
 			valueIndent := indent + `  ` // TODO: move that to a shared encoder state
 
-			stringBuffer.WriteString("[\n")
+			stringBuffer.WriteString("[" + newline)
 
 			for _, value := range values {
 				stringBuffer.WriteString(valueIndent)
 				stringBuffer.WriteString(commented + value)
 				stringBuffer.WriteString(`,`)
-				stringBuffer.WriteString("\n")
+				stringBuffer.WriteString(newline)
 			}
 
 			stringBuffer.WriteString(indent + commented + "]")
@@ -239,14 +178,13 @@ func getTreeArrayLine(trees []*Tree) (line int) {
 
 func sortByLines(t *Tree) (vals []sortNode) {
 	var (
-		line  int
-		lines []int
-		tv    *Tree
-		tom   *tomlValue
-		node  sortNode
+		line int
+		tv   *Tree
+		tom  *tomlValue
+		node sortNode
 	)
 	vals = make([]sortNode, 0)
-	m := make(map[int]sortNode)
+	lines := make(map[string]int)
 
 	for k := range t.values {
 		v := t.values[k]
@@ -255,6 +193,9 @@ func sortByLines(t *Tree) (vals []sortNode) {
 			tv = v.(*Tree)
 			line = tv.position.Line
 			node = sortNode{key: k, complexity: valueComplex}
+			if isInlineTable(tv) {
+				node.complexity = valueSimple
+			}
 		case []*Tree:
 			line = getTreeArrayLine(v.([]*Tree))
 			node = sortNode{key: k, complexity: valueComplex}
@@ -263,15 +204,24 @@ func sortByLines(t *Tree) (vals []sortNode) {
 			line = tom.position.Line
 			node = sortNode{key: k, complexity: valueSimple}
 		}
-		lines = append(lines, line)
+		lines[k] = line
 		vals = append(vals, node)
-		m[line] = node
 	}
-	sort.Ints(lines)
+	// values without a position, such as the ones set with Set, share line
+	// 0 and are sorted by key
+	sort.Slice(vals, func(i, j int) bool {
+		li, lj := lines[vals[i].key], lines[vals[j].key]
+		if li != lj {
+			return li < lj
+		}
+		return vals[i].key < vals[j].key
+	})
 
-	for i, line := range lines {
-		vals[i] = m[line]
-	}
+	// inline tables are written with the simple values, which have to come
+	// before the sub-tables
+	sort.SliceStable(vals, func(i, j int) bool {
+		return vals[i].complexity == valueSimple && vals[j].complexity == valueComplex
+	})
 
 	return vals
 }
@@ -289,6 +239,11 @@ func sortAlphabetical(t *Tree) (vals []sortNode) {
 		v := t.values[k]
 		switch v.(type) {
 		case *Tree, []*Tree:
+			if isInlineTable(v) {
+				node = sortNode{key: k, complexity: valueSimple}
+				simpVals = append(simpVals, node.key)
+				break
+			}
 			node = sortNode{key: k, complexity: valueComplex}
 			compVals = append(compVals, node.key)
 		default:
@@ -317,19 +272,28 @@ func sortAlphabetical(t *Tree) (vals []sortNode) {
 }
 
 func (t *Tree) writeTo(w io.Writer, indent, keyspace string, bytesCount int64, arraysOneElementPerLine bool) (int64, error) {
-	return t.writeToOrdered(w, indent, keyspace, bytesCount, arraysOneElementPerLine, OrderAlphabetical, "  ", false, false)
+	return t.writeToOrdered(w, indent, keyspace, bytesCount, arraysOneElementPerLine, OrderAlphabetical, "  ", false, false, "\n")
 }
 
-func (t *Tree) writeToOrdered(w io.Writer, indent, keyspace string, bytesCount int64, arraysOneElementPerLine bool, ord MarshalOrder, indentString string, compactComments, parentCommented bool) (int64, error) {
+func (t *Tree) writeToOrdered(w io.Writer, indent, keyspace string, bytesCount int64, arraysOneElementPerLine bool, ord MarshalOrder, indentString string, compactComments, parentCommented bool, newline string) (int64, error) {
 	var orderedVals []sortNode
 
-	switch ord {
-	case OrderPreserve:
+	switch {
+	case ord == OrderPreserve && t.format&FormatSorted == 0:
 		orderedVals = sortByLines(t)
 	default:
 		orderedVals = sortAlphabetical(t)
 	}
 
+	keyWidth := 0
+	if t.format&FormatAligned != 0 {
+		for _, node := range orderedVals {
+			if width := utf8.RuneCountInString(quoteKeyIfNeeded(node.key)); node.complexity == valueSimple && width > keyWidth {
+				keyWidth = width
+			}
+		}
+	}
+
 	for _, node := range orderedVals {
 		switch node.complexity {
 		case valueComplex:
@@ -349,12 +313,12 @@ func (t *Tree) writeToOrdered(w io.Writer, indent, keyspace string, bytesCount i
 					return bytesCount, fmt.Errorf("invalid value type at %s: %T", k, t.values[k])
 				}
 				if tv.comment != "" {
-					comment := strings.Replace(tv.comment, "\n", "\n"+indent+"#", -1)
+					comment := strings.Replace(tv.comment, "\n", newline+indent+"#", -1)
 					start := "# "
 					if strings.HasPrefix(comment, "#") {
 						start = ""
 					}
-					writtenBytesCountComment, errc := writeStrings(w, "\n", indent, start, comment)
+					writtenBytesCountComment, errc := writeStrings(w, newline, indent, start, comment)
 					bytesCount += int64(writtenBytesCountComment)
 					if errc != nil {
 						return bytesCount, errc
@@ -365,12 +329,23 @@ func (t *Tree) writeToOrdered(w io.Writer, indent, keyspace string, bytesCount i
 				if parentCommented || t.commented || tv.commented {
 					commented = "# "
 				}
-				writtenBytesCount, err := writeStrings(w, "\n", indent, commented, "[", combinedKey, "]\n")
+				if tv.format != 0 {
+					writtenBytesCountDirective, errd := writeStrings(w, newline, indent, commented, "# toml-fmt: ", tv.format.String())
+					bytesCount += int64(writtenBytesCountDirective)
+					if errd != nil {
+						return bytesCount, errd
+					}
+				}
+				var trailing string
+				if tv.trailing != "" {
+					trailing = " # " + tv.trailing
+				}
+				writtenBytesCount, err := writeStrings(w, newline, indent, commented, "[", combinedKey, "]", trailing, newline)
 				bytesCount += int64(writtenBytesCount)
 				if err != nil {
 					return bytesCount, err
 				}
-				bytesCount, err = node.writeToOrdered(w, indent+indentString, combinedKey, bytesCount, arraysOneElementPerLine, ord, indentString, compactComments, parentCommented || t.commented || tv.commented)
+				bytesCount, err = node.writeToOrdered(w, indent+indentString, combinedKey, bytesCount, arraysOneElementPerLine, ord, indentString, compactComments, parentCommented || t.commented || tv.commented, newline)
 				if err != nil {
 					return bytesCount, err
 				}
@@ -380,13 +355,20 @@ func (t *Tree) writeToOrdered(w io.Writer, indent, keyspace string, bytesCount i
 					if parentCommented || t.commented || subTree.commented {
 						commented = "# "
 					}
-					writtenBytesCount, err := writeStrings(w, "\n", indent, commented, "[[", combinedKey, "]]\n")
+					if subTree.format != 0 {
+						writtenBytesCountDirective, errd := writeStrings(w, newline, indent, commented, "# toml-fmt: ", subTree.format.String())
+						bytesCount += int64(writtenBytesCountDirective)
+						if errd != nil {
+							return bytesCount, errd
+						}
+					}
+					writtenBytesCount, err := writeStrings(w, newline, indent, commented, "[[", combinedKey, "]]", newline)
 					bytesCount += int64(writtenBytesCount)
 					if err != nil {
 						return bytesCount, err
 					}
 
-					bytesCount, err = subTree.writeToOrdered(w, indent+indentString, combinedKey, bytesCount, arraysOneElementPerLine, ord, indentString, compactComments, parentCommented || t.commented || subTree.commented)
+					bytesCount, err = subTree.writeToOrdered(w, indent+indentString, combinedKey, bytesCount, arraysOneElementPerLine, ord, indentString, compactComments, parentCommented || t.commented || subTree.commented, newline)
 					if err != nil {
 						return bytesCount, err
 					}
@@ -395,6 +377,11 @@ func (t *Tree) writeToOrdered(w io.Writer, indent, keyspace string, bytesCount i
 		default: // Simple
 			k := node.key
 			v, ok := t.values[k].(*tomlValue)
+			var format TableFormat
+			if inline, isInline := t.values[k].(*Tree); isInline && isInlineTable(inline) {
+				v, ok = &tomlValue{value: inline, comment: inline.comment, commented: inline.commented, trailing: inline.trailing}, true
+				format = inline.format
+			}
 			if !ok {
 				return bytesCount, fmt.Errorf("invalid value type at %s: %T", k, t.values[k])
 			}
@@ -403,33 +390,50 @@ func (t *Tree) writeToOrdered(w io.Writer, indent, keyspace string, bytesCount i
 			if parentCommented || t.commented || v.commented {
 				commented = "# "
 			}
-			repr, err := tomlValueStringRepresentation(v, commented, indent, ord, arraysOneElementPerLine)
+			repr, err := tomlValueStringRepresentation(v, commented, indent, ord, arraysOneElementPerLine, newline)
 			if err != nil {
 				return bytesCount, err
 			}
 
 			if v.comment != "" {
-				comment := strings.Replace(v.comment, "\n", "\n"+indent+"#", -1)
+				comment := strings.Replace(v.comment, "\n", newline+indent+"#", -1)
 				start := "# "
 				if strings.HasPrefix(comment, "#") {
 					start = ""
 				}
 				if !compactComments {
-					writtenBytesCountComment, errc := writeStrings(w, "\n")
+					writtenBytesCountComment, errc := writeStrings(w, newline)
 					bytesCount += int64(writtenBytesCountComment)
 					if errc != nil {
 						return bytesCount, errc
 					}
 				}
-				writtenBytesCountComment, errc := writeStrings(w, indent, start, comment, "\n")
+				writtenBytesCountComment, errc := writeStrings(w, indent, start, comment, newline)
 				bytesCount += int64(writtenBytesCountComment)
 				if errc != nil {
 					return bytesCount, errc
 				}
 			}
 
+			// the directive keeps the table inline when the document is loaded
+			if format != 0 {
+				writtenBytesCountDirective, errd := writeStrings(w, indent, commented, "# toml-fmt: ", format.String(), newline)
+				bytesCount += int64(writtenBytesCountDirective)
+				if errd != nil {
+					return bytesCount, errd
+				}
+			}
+
+			var trailing string
+			if v.trailing != "" {
+				trailing = " # " + v.trailing
+			}
+
 			quotedKey := quoteKeyIfNeeded(k)
-			writtenBytesCount, err := writeStrings(w, indent, commented, quotedKey, " = ", repr, "\n")
+			if padding := keyWidth - utf8.RuneCountInString(quotedKey); padding > 0 {
+				quotedKey += strings.Repeat(" ", padding)
+			}
+			writtenBytesCount, err := writeStrings(w, indent, commented, quotedKey, " = ", repr, trailing, newline)
 			bytesCount += int64(writtenBytesCount)
 			if err != nil {
 				return bytesCount, err
@@ -463,7 +467,7 @@ func quoteKeyIfNeeded(k string) string {
 }
 
 func quoteKey(k string) string {
-	return "\"" + encodeTomlString(k) + "\""
+	return "\"" + EscapeBasicString(k) + "\""
 }
 
 func writeStrings(w io.Writer, s ...string) (int, error) {
@@ -484,3 +484,34 @@ test_string = "You'll hate me after this - #"          # " Annoying, isn't it?
 #         and here"
 #         ]     End of array comment, forgot the #
 #number = 3.14  pi <--again forgot the #         `
+
+func TestTreeWriteToNumberUnderscores(t *testing.T) {
+	input := `a = 1_000_000
+b = 0xdead_beef
+c = 3.141_592
+d = 1000
+e = [1_000, 2_000]
+`
+	tree, err := Load(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Get("a") != int64(1000000) {
+		t.Errorf("unexpected value: %v", tree.Get("a"))
+	}
+
+	expected := `a = 1_000_000
+b = 0xdead_beef
+c = 3.141_592
+d = 1000
+e = [1000, 2000]
+`
+	if s := tree.String(); s != expected {
+		t.Errorf("Bad output.\nExpected:\n%s\nGot:\n%s", expected, s)
+	}
+
+	tree.Values()["a"].(*PubTOMLValue).SetValue(int64(42))
+	if s := tree.String(); !strings.HasPrefix(s, "a = 42\n") {
+		t.Errorf("changed value should be encoded, got:\n%s", s)
+	}
+}
@@ -0,0 +1,108 @@
+// Tracing of decoding type conversions.
+
+package toml
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Conversions reported in TypeTraceEvent.
+const (
+	ConversionConvert         = "convert"         // Go conversion of the TOML value
+	ConversionInterface       = "interface"       // TOML value stored as is in an interface
+	ConversionDuration        = "duration"        // string parsed by time.ParseDuration
+	ConversionLocalTime       = "local time"      // local date or date-time converted to time.Time
+	ConversionTimeLayout      = "time layout"     // string or integer parsed with Decoder.TimeLayouts
+	ConversionLocalType       = "local type"      // local value converted by a function of RegisterLocalType
+	ConversionHook            = "decode hook"     // value returned by a hook of Decoder.WithDecodeHook
+	ConversionDecoder         = "decoder"         // function of Decoder.RegisterDecoder
+	ConversionUnmarshaler     = "Unmarshaler"     // UnmarshalTOML method of the target
+	ConversionTextUnmarshaler = "TextUnmarshaler" // UnmarshalText method of the target
+	ConversionDefault         = "default tag"     // value of the default tag, key absent
+)
+
+// TypeTraceEvent describes an attempt of the Decoder to assign a value to a
+// Go value.
+type TypeTraceEvent struct {
+	Key        string       // dot-separated key of the value
+	TOMLType   string       // TOML type of the value, empty for defaults
+	GoType     reflect.Type // type of the target
+	Conversion string       // one of the Conversion constants
+	Err        error        // why the assignment failed, if it did
+}
+
+func (e TypeTraceEvent) String() string {
+	s := fmt.Sprintf("%s: %s -> %s (%s)", e.Key, e.TOMLType, e.GoType, e.Conversion)
+	if e.TOMLType == "" {
+		s = fmt.Sprintf("%s: %s (%s)", e.Key, e.GoType, e.Conversion)
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// WithTypeTrace makes the decoder record each assignment of a value to a Go
+// value, successful or not. Events are returned by TypeTrace. This helps
+// understanding why a field ends up with an unexpected value.
+func (d *Decoder) WithTypeTrace() *Decoder {
+	d.typeTrace = true
+	return d
+}
+
+// TypeTrace returns the events recorded during the last call to Decode, in
+// the order of the assignments. It is empty unless WithTypeTrace was called.
+func (d *Decoder) TypeTrace() []TypeTraceEvent {
+	return d.trace
+}
+
+func (d *Decoder) traceType(key []string, tval interface{}, mtype reflect.Type, conversion string, err error) {
+	if !d.typeTrace || conversion == "" {
+		return
+	}
+	event := TypeTraceEvent{
+		Key:        strings.Join(key, "."),
+		GoType:     mtype,
+		Conversion: conversion,
+		Err:        err,
+	}
+	if conversion != ConversionDefault {
+		event.TOMLType = tomlTypeName(tval)
+	}
+	d.trace = append(d.trace, event)
+}
+
+// tomlTypeName returns the name the TOML specification gives to the type of
+// a value of a tree.
+func tomlTypeName(tval interface{}) string {
+	switch tval.(type) {
+	case string:
+		return "string"
+	case int64, uint64, *big.Int:
+		return "integer"
+	case float64, *big.Float:
+		return "float"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "offset date-time"
+	case LocalDateTime:
+		return "local date-time"
+	case LocalDate:
+		return "local date"
+	case LocalTime:
+		return "local time"
+	case []interface{}:
+		return "array"
+	case *Tree:
+		return "table"
+	case []*Tree:
+		return "array of tables"
+	default:
+		return fmt.Sprintf("%T", tval)
+	}
+}
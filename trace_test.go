@@ -0,0 +1,81 @@
+package toml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecoderTypeTrace(t *testing.T) {
+	type Config struct {
+		Name    string
+		Port    uint16
+		Ratio   float64
+		Timeout time.Duration
+		Retries int `default:"3"`
+		Extra   interface{}
+		Server  struct {
+			Start time.Time
+		}
+	}
+	doc := `
+Name = "api"
+Port = 80000
+Ratio = 1
+Timeout = "5s"
+Extra = true
+
+[Server]
+Start = 2021-06-14T10:00:00
+`
+	var config Config
+	d := NewDecoder(strings.NewReader(doc)).WithTypeTrace()
+	if err := d.Decode(&config); err == nil {
+		t.Fatal("expected an overflow error")
+	}
+	events := d.TypeTrace()
+	if len(events) != 2 || events[0].Key != "Name" {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	if s := events[1].String(); s != "Port: integer -> uint16 (convert): 80000(int64) would overflow uint16" {
+		t.Errorf("unexpected event: %s", s)
+	}
+
+	doc = strings.Replace(doc, "80000", "8000", 1)
+	d = NewDecoder(strings.NewReader(doc)).WithTypeTrace()
+	if err := d.Decode(&config); err == nil {
+		t.Fatal("expected a float conversion error")
+	}
+	events = d.TypeTrace()
+	if e := events[len(events)-1]; e.Key != "Ratio" || e.TOMLType != "integer" || e.Err == nil {
+		t.Errorf("unexpected event: %v", e)
+	}
+
+	doc = strings.Replace(doc, "Ratio = 1", "Ratio = 1.0", 1)
+	d = NewDecoder(strings.NewReader(doc)).WithTypeTrace()
+	if err := d.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range d.TypeTrace() {
+		got = append(got, e.String())
+	}
+	expected := []string{
+		"Name: string -> string (convert)",
+		"Port: integer -> uint16 (convert)",
+		"Ratio: float -> float64 (convert)",
+		"Timeout: string -> time.Duration (duration)",
+		"Retries: int (default tag)",
+		"Extra: boolean -> interface {} (interface)",
+		"Server.Start: local date-time -> time.Time (local time)",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Bad trace.\nExpected: %q\nGot:      %q", expected, got)
+	}
+
+	d = NewDecoder(strings.NewReader(doc))
+	if err := d.Decode(&config); err != nil || d.TypeTrace() != nil {
+		t.Errorf("trace should be empty when disabled, got %v, %v", d.TypeTrace(), err)
+	}
+}
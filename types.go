@@ -0,0 +1,124 @@
+// Registered concrete types of interface fields.
+
+package toml
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// TypeKey is the key holding the registered name of the concrete type of a
+// value stored in an interface field (see RegisterType).
+const TypeKey = "type"
+
+var registeredTypes = struct {
+	sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}{
+	byName: make(map[string]reflect.Type),
+	byType: make(map[reflect.Type]string),
+}
+
+// RegisterType registers the type of v under name, so that values of this
+// type can be stored in fields of interface types with methods. v must be a
+// struct, a map or a pointer to a struct.
+//
+// Such values are marshaled as tables with their name at TypeKey, and these
+// tables are unmarshaled back to values of the registered type. This allows
+// configuring plugins:
+//
+//   type Storage interface { Open() error }
+//   type S3 struct { Bucket string }
+//   toml.RegisterType("s3", &S3{})
+//
+//   type Config struct { Storage Storage }
+//
+//   [storage]
+//   type = "s3"
+//   bucket = "backups"
+//
+// RegisterType panics if name or the type of v is already registered.
+func RegisterType(name string, v interface{}) {
+	typ := reflect.TypeOf(v)
+	if typ == nil || !isTree(typ) {
+		panic(fmt.Sprintf("toml: registered type %s must be a struct, a map or a pointer to a struct, not %v", name, typ))
+	}
+	registeredTypes.Lock()
+	defer registeredTypes.Unlock()
+	if _, dup := registeredTypes.byName[name]; dup {
+		panic(fmt.Sprintf("toml: type name %s registered twice", name))
+	}
+	if _, dup := registeredTypes.byType[typ]; dup {
+		panic(fmt.Sprintf("toml: type %s registered twice", typ))
+	}
+	registeredTypes.byName[name] = typ
+	registeredTypes.byType[typ] = name
+}
+
+// registeredTypeName returns the name of typ if it is registered and stored
+// in an interface with methods.
+func registeredTypeName(iface, typ reflect.Type) (string, bool) {
+	if iface.NumMethod() == 0 {
+		return "", false
+	}
+	registeredTypes.RLock()
+	defer registeredTypes.RUnlock()
+	name, ok := registeredTypes.byType[typ]
+	return name, ok
+}
+
+func (e *Encoder) valueToTypedTree(name string, mval reflect.Value) (interface{}, error) {
+	// reserve a line so that the type comes first with OrderPreserve
+	line := e.line
+	e.line++
+	val, err := e.valueToToml(mval.Type(), mval)
+	if err != nil {
+		return nil, err
+	}
+	tree, ok := val.(*Tree)
+	if !ok {
+		return nil, fmt.Errorf("registered type %s must marshal to a table, not %T", name, val)
+	}
+	if _, exists := tree.values[TypeKey]; exists {
+		return nil, fmt.Errorf("registered type %s already has a %s key", name, TypeKey)
+	}
+	tree.values[TypeKey] = &tomlValue{value: name, position: Position{Line: line, Col: tree.position.Col}}
+	return tree, nil
+}
+
+// valueFromTypedTree decodes a table holding a TypeKey into the registered
+// type it names, which must implement mtype.
+func (d *Decoder) valueFromTypedTree(mtype reflect.Type, tval *Tree, name string) (reflect.Value, error) {
+	registeredTypes.RLock()
+	typ, ok := registeredTypes.byName[name]
+	registeredTypes.RUnlock()
+	if !ok {
+		return reflect.ValueOf(nil), fmt.Errorf("unknown type %q", name)
+	}
+	if !typ.Implements(mtype) {
+		return reflect.ValueOf(nil), fmt.Errorf("type %q (%v) does not implement %v", name, typ, mtype)
+	}
+
+	d.visitor.push(TypeKey)
+	d.visitor.visit()
+	d.visitor.pop()
+	content := newTreeWithPosition(tval.position)
+	for k, v := range tval.values {
+		if k != TypeKey {
+			content.values[k] = v
+		}
+	}
+
+	if typ.Kind() != reflect.Ptr {
+		return d.valueFromTree(typ, content, nil)
+	}
+	val, err := d.valueFromTree(typ.Elem(), content, nil)
+	if err != nil {
+		return val, err
+	}
+	ptr := reflect.New(typ.Elem())
+	ptr.Elem().Set(val)
+	return ptr, nil
+}
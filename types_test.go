@@ -0,0 +1,94 @@
+package toml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type testStorage interface {
+	Location() string
+}
+
+type testS3Storage struct {
+	Bucket string `toml:"bucket"`
+	Region string `toml:"region"`
+}
+
+func (s *testS3Storage) Location() string { return "s3://" + s.Bucket }
+
+type testDiskStorage struct {
+	Path string `toml:"path"`
+}
+
+func (s testDiskStorage) Location() string { return s.Path }
+
+type testStorageConfig struct {
+	Name    string      `toml:"name"`
+	Primary testStorage `toml:"primary"`
+	Backup  testStorage `toml:"backup"`
+}
+
+func init() {
+	RegisterType("s3", &testS3Storage{})
+	RegisterType("disk", testDiskStorage{})
+}
+
+func TestRegisteredTypesRoundTrip(t *testing.T) {
+	in := testStorageConfig{
+		Name:    "archive",
+		Primary: &testS3Storage{Bucket: "backups", Region: "eu-west-1"},
+		Backup:  testDiskStorage{Path: "/var/backups"},
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Order(OrderPreserve).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	expected := `name = "archive"
+
+[primary]
+  type = "s3"
+  bucket = "backups"
+  region = "eu-west-1"
+
+[backup]
+  type = "disk"
+  path = "/var/backups"
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	var out testStorageConfig
+	if err := NewDecoder(&buf).Strict(true).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Primary.Location() != "s3://backups" || out.Primary.(*testS3Storage).Region != "eu-west-1" {
+		t.Errorf("unexpected primary storage: %#v", out.Primary)
+	}
+	if out.Backup != (testDiskStorage{Path: "/var/backups"}) {
+		t.Errorf("unexpected backup storage: %#v", out.Backup)
+	}
+}
+
+func TestRegisteredTypesErrors(t *testing.T) {
+	var out testStorageConfig
+	err := Unmarshal([]byte("[primary]\ntype = \"ftp\"\n"), &out)
+	if err == nil || !strings.Contains(err.Error(), `unknown type "ftp"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	type notStorage struct{ Path string }
+	RegisterType("not-storage", notStorage{})
+	err = Unmarshal([]byte("[primary]\ntype = \"not-storage\"\n"), &out)
+	if err == nil || !strings.Contains(err.Error(), "does not implement") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice should panic")
+		}
+	}()
+	RegisterType("s3", testDiskStorage{})
+}
@@ -0,0 +1,90 @@
+// Validation of the encoding of documents.
+
+package toml
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// UTF8Error is returned when a document is not valid UTF-8, as TOML
+// requires.
+type UTF8Error struct {
+	Offset   int      // offset of the invalid sequence in the document, in bytes
+	Position Position // line and column of the invalid sequence
+	Reason   string   // what was expected instead of the invalid sequence
+}
+
+func (e *UTF8Error) Error() string {
+	return fmt.Sprintf("%s: invalid UTF-8 at byte offset %d: %s", e.Position, e.Offset, e.Reason)
+}
+
+// LenientUTF8 makes the decoder replace the invalid UTF-8 sequences of the
+// document with U+FFFD instead of returning a UTF8Error.
+func (d *Decoder) LenientUTF8(lenient bool) *Decoder {
+	d.lenientUTF8 = lenient
+	return d
+}
+
+// validateUTF8 returns a UTF8Error for the first invalid sequence of b, a
+// document found at offset in the input, after its byte order mark.
+func validateUTF8(b []byte, offset int) error {
+	if utf8.Valid(b) {
+		return nil
+	}
+	pos := Position{Line: 1, Col: 1}
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size == 1 {
+			return &UTF8Error{Offset: offset + i, Position: pos, Reason: invalidUTF8Reason(b[i:])}
+		}
+		if r == '\n' {
+			pos.Line++
+			pos.Col = 1
+		} else {
+			pos.Col++
+		}
+		i += size
+	}
+	return nil
+}
+
+// invalidUTF8Reason describes why the sequence at the start of b is invalid.
+func invalidUTF8Reason(b []byte) string {
+	lead := b[0]
+	var length int
+	switch {
+	case lead >= 0x80 && lead <= 0xBF:
+		return fmt.Sprintf("unexpected continuation byte 0x%02X", lead)
+	case lead >= 0xC2 && lead <= 0xDF:
+		length = 2
+	case lead >= 0xE0 && lead <= 0xEF:
+		length = 3
+	case lead >= 0xF0 && lead <= 0xF4:
+		length = 4
+	default:
+		return fmt.Sprintf("byte 0x%02X never appears in UTF-8", lead)
+	}
+	continuations := 0
+	for continuations < length-1 && 1+continuations < len(b) && b[1+continuations]&0xC0 == 0x80 {
+		continuations++
+	}
+	if continuations < length-1 {
+		return fmt.Sprintf("lead byte 0x%02X expects %d continuation bytes, found %d", lead, length-1, continuations)
+	}
+	return fmt.Sprintf("sequence % X is an overlong encoding, a surrogate or out of the range of Unicode", b[:length])
+}
+
+// replaceInvalidUTF8 replaces the invalid UTF-8 sequences of b with U+FFFD.
+func replaceInvalidUTF8(b []byte) []byte {
+	if utf8.Valid(b) {
+		return b
+	}
+	valid := make([]byte, 0, len(b))
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		valid = append(valid, string(r)...)
+		i += size
+	}
+	return valid
+}
@@ -0,0 +1,66 @@
+package toml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLoadBytesInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		doc      string
+		offset   int
+		position Position
+		reason   string
+	}{
+		{"a = \"\x80\"", 5, Position{1, 6}, "unexpected continuation byte 0x80"},
+		{"a = 1\nb = \"é\xFF\"", 13, Position{2, 7}, "byte 0xFF never appears in UTF-8"},
+		{"# comment \xE2\x82", 10, Position{1, 11}, "lead byte 0xE2 expects 2 continuation bytes, found 1"},
+		{"k\xC3 = 1", 1, Position{1, 2}, "lead byte 0xC3 expects 1 continuation bytes, found 0"},
+		{"a = \"\xE0\x80\xAF\"", 5, Position{1, 6}, "sequence E0 80 AF is an overlong encoding, a surrogate or out of the range of Unicode"},
+		{"a = \"\xED\xA0\x80\"", 5, Position{1, 6}, "sequence ED A0 80 is an overlong encoding, a surrogate or out of the range of Unicode"},
+		{"\xEF\xBB\xBFa = \"\xC0\"", 8, Position{1, 6}, "byte 0xC0 never appears in UTF-8"},
+	}
+	for _, test := range tests {
+		_, err := LoadBytes([]byte(test.doc))
+		utf8Err, ok := err.(*UTF8Error)
+		if !ok {
+			t.Errorf("%q: expected a UTF8Error, got %v", test.doc, err)
+			continue
+		}
+		if utf8Err.Offset != test.offset || utf8Err.Position != test.position || utf8Err.Reason != test.reason {
+			t.Errorf("%q: got %+v, expected offset %d at %s: %s", test.doc, utf8Err, test.offset, test.position, test.reason)
+		}
+	}
+}
+
+func TestUTF8ErrorMessage(t *testing.T) {
+	_, err := LoadBytes([]byte("a = \"\x80\""))
+	expected := "(1, 6): invalid UTF-8 at byte offset 5: unexpected continuation byte 0x80"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestDecoderLenientUTF8(t *testing.T) {
+	doc := []byte("\xEF\xBB\xBFname = \"caf\xE9\" # \xFF\n")
+	var v struct{ Name string }
+	if err := NewDecoder(bytes.NewReader(doc)).Decode(&v); err == nil {
+		t.Error("invalid UTF-8 should be rejected by default")
+	}
+	if err := NewDecoder(bytes.NewReader(doc)).LenientUTF8(true).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "caf�" {
+		t.Errorf("invalid sequences should be replaced, got %q", v.Name)
+	}
+}
+
+func TestDecoderTokenInvalidUTF8(t *testing.T) {
+	d := NewDecoder(strings.NewReader("a = \"\x80\""))
+	if _, err := d.Token(); err == nil {
+		t.Fatal("expected an error")
+	} else if _, ok := err.(*UTF8Error); !ok {
+		t.Errorf("expected a UTF8Error, got %v", err)
+	}
+}
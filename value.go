@@ -0,0 +1,90 @@
+// Parsing and formatting of standalone values.
+
+package toml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// ParseValue parses a standalone TOML value, such as the right-hand side of a
+// key/value pair: 42, "text", [1, 2] or { a = 1 }. The value has one of the
+// types documented for Tree.ToMap. This is useful to read values given
+// outside of documents, such as the ones of --set key=value command line
+// flags.
+func ParseValue(b []byte) (value interface{}, err error) {
+	if err := validateUTF8(b, 0); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, parserError(r)
+		}
+	}()
+
+	flow, notes := lexValue(b)
+	p := &tomlParser{flow: flow, notes: notes}
+	value = p.parseRvalue()
+	if tok := p.getToken(); tok != nil && tok.typ != tokenEOF {
+		p.raiseError(tok, "unexpected %s after the value", tok)
+	}
+	return tomlValueToGo(value), nil
+}
+
+// FormatOptions are the options of FormatValue. The zero value writes strings
+// as basic strings and the keys of tables in alphabetical order.
+type FormatOptions struct {
+	Order MarshalOrder
+	// Quotes of a string value. Strings that cannot be written in the style,
+	// such as literal strings with single quotes, are written as basic
+	// strings. Strings in arrays and tables are always basic strings.
+	StringStyle             StringStyle
+	ArraysOneElementPerLine bool
+}
+
+// FormatValue returns the TOML representation of v as a standalone value,
+// such as the right-hand side of a key/value pair. v is converted as by
+// Marshal; structs and maps are written as inline tables. This is the
+// counterpart of ParseValue.
+func FormatValue(v interface{}, opts FormatOptions) (string, error) {
+	if v == nil {
+		return "", errors.New("nil cannot be formatted as a TOML value")
+	}
+	e := NewEncoder(nil).Order(opts.Order)
+	val, err := e.valueToToml(reflect.TypeOf(v), reflect.ValueOf(v))
+	if err != nil {
+		return "", err
+	}
+	if s, ok := val.(string); ok {
+		return formatString(s, opts.StringStyle), nil
+	}
+	return tomlValueStringRepresentation(val, "", "", opts.Order, opts.ArraysOneElementPerLine, "\n")
+}
+
+// formatString returns s quoted in the given style, or as a basic string when
+// the style cannot represent it.
+func formatString(s string, style StringStyle) string {
+	switch style {
+	case StringLiteral:
+		if !strings.ContainsAny(s, "'\n\r") && !hasControlCharacter(s) {
+			return "'" + s + "'"
+		}
+	case StringMultilineBasic:
+		return "\"\"\"\n" + EscapeMultilineBasicString(s) + "\"\"\""
+	case StringMultilineLiteral:
+		if !strings.Contains(s, "'''") && !strings.HasSuffix(s, "'") && !hasControlCharacter(strings.NewReplacer("\t", "", "\r\n", "", "\n", "").Replace(s)) {
+			return "'''\n" + s + "'''"
+		}
+	}
+	return "\"" + EscapeBasicString(s) + "\""
+}
+
+func hasControlCharacter(s string) bool {
+	for _, r := range s {
+		if r <= 0x1F && r != '\t' || r == 0x7F {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package toml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	for input, expected := range map[string]interface{}{
+		`42`:                   int64(42),
+		`-0x1`:                 nil,
+		`"a\tb"`:               "a\tb",
+		`'C:\dir'`:             `C:\dir`,
+		` 1.5 # comment`:       1.5,
+		`true`:                 true,
+		`1979-05-27`:           LocalDate{1979, 5, 27},
+		`[1, "two"]`:           []interface{}{int64(1), "two"},
+		"[\n  1,\n  2,\n]\n":   []interface{}{int64(1), int64(2)},
+		`{ a = 1, b.c = "d" }`: map[string]interface{}{"a": int64(1), "b": map[string]interface{}{"c": "d"}},
+	} {
+		value, err := ParseValue([]byte(input))
+		if expected == nil {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", input, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", input, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, expected) {
+			t.Errorf("%q: expected %#v, got %#v", input, expected, value)
+		}
+	}
+
+	for _, input := range []string{``, `1 2`, "1\nkey = 2", `"unterminated`, `[1,`, "\xff"} {
+		if value, err := ParseValue([]byte(input)); err == nil || value != nil {
+			t.Errorf("%q: expected an error and no value, got %v, %v", input, value, err)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	type point struct {
+		X int `toml:"x"`
+		Y int `toml:"y"`
+	}
+	for _, test := range []struct {
+		value    interface{}
+		opts     FormatOptions
+		expected string
+	}{
+		{42, FormatOptions{}, `42`},
+		{uint8(7), FormatOptions{}, `7`},
+		{2.5, FormatOptions{}, `2.5`},
+		{true, FormatOptions{}, `true`},
+		{"a \"b\"\n", FormatOptions{}, `"a \"b\"\n"`},
+		{`C:\dir`, FormatOptions{StringStyle: StringLiteral}, `'C:\dir'`},
+		{`it's`, FormatOptions{StringStyle: StringLiteral}, `"it's"`},
+		{"a\nb", FormatOptions{StringStyle: StringMultilineBasic}, "\"\"\"\na\nb\"\"\""},
+		{"a\\\nb", FormatOptions{StringStyle: StringMultilineLiteral}, "'''\na\\\nb'''"},
+		{[]string{"a", "b"}, FormatOptions{}, `["a", "b"]`},
+		{[]int{1, 2}, FormatOptions{ArraysOneElementPerLine: true}, "[\n  1,\n  2,\n]"},
+		{point{1, 2}, FormatOptions{}, `{ x = 1, y = 2 }`},
+		{map[string]interface{}{"b": 1, "a": "x"}, FormatOptions{}, `{ a = "x", b = 1 }`},
+		{[]point{{1, 2}}, FormatOptions{}, `[{ x = 1, y = 2 }]`},
+		{LocalDate{1979, 5, 27}, FormatOptions{}, `1979-05-27`},
+	} {
+		repr, err := FormatValue(test.value, test.opts)
+		if err != nil {
+			t.Errorf("%#v: %s", test.value, err)
+			continue
+		}
+		if repr != test.expected {
+			t.Errorf("%#v: expected %q, got %q", test.value, test.expected, repr)
+		}
+		if parsed, err := ParseValue([]byte(repr)); err != nil || parsed == nil {
+			t.Errorf("%#v: %q does not parse back: %v", test.value, repr, err)
+		}
+	}
+
+	if _, err := FormatValue(nil, FormatOptions{}); err == nil {
+		t.Error("expected an error for nil")
+	}
+}
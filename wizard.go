@@ -0,0 +1,147 @@
+// Interactive configuration wizard.
+
+package toml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const tagChoices = "choices"
+
+// WizardField describes a struct field the wizard asks a value for.
+type WizardField struct {
+	Path    []string     // TOML key of the field, including its parent tables
+	Type    reflect.Type // Go type of the field
+	Comment string       // content of the comment tag
+	Default string       // content of the default tag
+	Choices []string     // comma-separated content of the choices tag
+	Err     error        // why the previous answer was rejected, if any
+}
+
+// Key returns the path of the field as a dot-separated TOML key.
+func (f WizardField) Key() string {
+	return Change{Path: f.Path}.Key()
+}
+
+// PromptFunc asks the user for the value of a field and returns the answer as
+// typed. An empty answer selects the default value of the field, or keeps its
+// current value when it has no default. Returning an error aborts the wizard.
+type PromptFunc func(f WizardField) (string, error)
+
+// Wizard fills the struct pointed to by v by calling prompt for each of its
+// fields holding a string, a boolean, a number or a time.Duration, walking
+// nested structs as tables. It then returns v marshaled in field order, with
+// the comments given by the comment tags. This is meant to be used by
+// commands generating an initial configuration file:
+//
+//   type Config struct {
+//     Name  string `comment:"Name of the service"`
+//     Level string `comment:"Log level" default:"info" choices:"debug,info,error"`
+//   }
+//
+//   var config Config
+//   doc, err := toml.Wizard(&config, func(f toml.WizardField) (string, error) {
+//     ...
+//   })
+//
+// Answers that cannot be converted to the type of the field, or that are not
+// one of its choices, are rejected: prompt is called again for the same field
+// with Err set.
+func Wizard(v interface{}, prompt PromptFunc) ([]byte, error) {
+	mval := reflect.ValueOf(v)
+	if mval.Kind() != reflect.Ptr || mval.IsNil() || mval.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("only a non-nil pointer to struct can be filled by the wizard")
+	}
+	if err := wizardStruct(nil, mval.Elem(), prompt); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Order(OrderPreserve).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func wizardStruct(path []string, mval reflect.Value, prompt PromptFunc) error {
+	mtype := mval.Type()
+	for i := 0; i < mtype.NumField(); i++ {
+		mtypef := mtype.Field(i)
+		opts := tomlOptions(mtypef, annotationDefault)
+		if !opts.include {
+			continue
+		}
+		keyPath := append(append([]string{}, path...), opts.name)
+		fval := mval.Field(i)
+		switch {
+		case mtypef.Type.Kind() == reflect.Struct && !isPrimitive(mtypef.Type) &&
+			!isCustomMarshaler(mtypef.Type) && !isTextMarshaler(mtypef.Type):
+			if err := wizardStruct(keyPath, fval, prompt); err != nil {
+				return err
+			}
+		case isWizardKind(mtypef.Type.Kind()):
+			f := WizardField{
+				Path:    keyPath,
+				Type:    mtypef.Type,
+				Comment: opts.comment,
+				Default: opts.defaultValue,
+			}
+			if choices := mtypef.Tag.Get(tagChoices); choices != "" {
+				f.Choices = strings.Split(choices, ",")
+			}
+			if err := wizardField(f, fval, prompt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func wizardField(f WizardField, fval reflect.Value, prompt PromptFunc) error {
+	for {
+		answer, err := prompt(f)
+		if err != nil {
+			return err
+		}
+		if answer == "" {
+			answer = f.Default
+		}
+		if answer == "" {
+			return nil
+		}
+		if len(f.Choices) > 0 && !containsString(f.Choices, answer) {
+			f.Err = fmt.Errorf("%q is not one of %s", answer, strings.Join(f.Choices, ", "))
+			continue
+		}
+		val, err := parseDefaultValue(f.Type, answer)
+		if err != nil {
+			f.Err = fmt.Errorf("%q is not a valid %s", answer, f.Type)
+			continue
+		}
+		fval.Set(reflect.ValueOf(val).Convert(f.Type))
+		return nil
+	}
+}
+
+func isWizardKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
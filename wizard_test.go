@@ -0,0 +1,91 @@
+package toml
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type wizardConfig struct {
+	Name    string `comment:"Name of the service"`
+	Level   string `comment:"Log level" default:"info" choices:"debug,info,error"`
+	Workers int    `default:"4"`
+	Tags    []string
+	Server  struct {
+		Port    uint16        `comment:"Port to listen on"`
+		Timeout time.Duration `default:"30s"`
+	}
+}
+
+func TestWizard(t *testing.T) {
+	answers := map[string][]string{
+		"Name":           {"api"},
+		"Level":          {"trace", "debug"},
+		"Workers":        {""},
+		"Server.Port":    {"http", "8080"},
+		"Server.Timeout": {"1m"},
+	}
+	var rejected []string
+	var fields []WizardField
+	prompt := func(f WizardField) (string, error) {
+		if f.Err != nil {
+			rejected = append(rejected, f.Err.Error())
+		} else {
+			fields = append(fields, f)
+		}
+		answer := answers[f.Key()][0]
+		answers[f.Key()] = answers[f.Key()][1:]
+		return answer, nil
+	}
+
+	var config wizardConfig
+	doc, err := Wizard(&config, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedRejected := []string{
+		`"trace" is not one of debug, info, error`,
+		`"http" is not a valid uint16`,
+	}
+	if !reflect.DeepEqual(rejected, expectedRejected) {
+		t.Errorf("Bad rejections. Expected %q, got %q", expectedRejected, rejected)
+	}
+	if len(fields) != 5 || fields[1].Default != "info" || !reflect.DeepEqual(fields[1].Choices, []string{"debug", "info", "error"}) {
+		t.Errorf("Bad fields: %+v", fields)
+	}
+
+	expected := `
+# Name of the service
+Name = "api"
+
+# Log level
+Level = "debug"
+Workers = 4
+Tags = []
+
+[Server]
+
+  # Port to listen on
+  Port = 8080
+  Timeout = "1m0s"
+`
+	if string(doc) != expected {
+		t.Errorf("Bad output. Expected:\n%s\nGot:\n%s", expected, doc)
+	}
+}
+
+func TestWizardAbort(t *testing.T) {
+	abort := errors.New("abort")
+	var config wizardConfig
+	_, err := Wizard(&config, func(f WizardField) (string, error) {
+		return "", abort
+	})
+	if err != abort {
+		t.Errorf("expected the prompt error, got %v", err)
+	}
+	if _, err := Wizard(config, nil); err == nil {
+		t.Error("expected an error for a non-pointer value")
+	}
+}